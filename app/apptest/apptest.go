@@ -0,0 +1,357 @@
+// Package apptest runs the complete REST service inside the test process,
+// backed by in-memory repositories, so handlers can be tested end to end
+// without a database or a running server.
+package apptest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/kluddizz/maintenance-rest-service/app"
+	"github.com/kluddizz/maintenance-rest-service/auth"
+	"github.com/kluddizz/maintenance-rest-service/controllers"
+	"github.com/kluddizz/maintenance-rest-service/mail"
+	"github.com/kluddizz/maintenance-rest-service/models"
+	"github.com/kluddizz/maintenance-rest-service/repositories"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/oauth2"
+)
+
+type (
+	Harness struct {
+		Server        *httptest.Server
+		Users         *repositories.MemoryUserRepository
+		Masters       *repositories.MemoryMasterRepository
+		RefreshTokens *repositories.MemoryRefreshTokenRepository
+		Revocations   *repositories.MemoryRevocationRepository
+		ApiKeys       *repositories.MemoryApiKeyRepository
+		LoginAttempts *repositories.MemoryLoginAttemptRepository
+		Audit         *repositories.MemoryAuditRepository
+		Totp          *repositories.MemoryTotpRepository
+		Identities    *repositories.MemoryIdentityRepository
+		Keys          *auth.KeyManager
+		Tokens        *auth.TokenService
+		Throttle      *auth.LoginThrottle
+
+		// Login at a stub provider, if enabled by WithOidc.
+		Oidc *auth.OidcService
+
+		// Receives all mails instead of sending them.
+		Mailbox *Mailbox
+
+		// Readiness checks, which may be added after the harness is created.
+		Checks map[string]controllers.HealthCheck
+
+		t testing.TB
+	}
+
+	// Records sent mails.
+	Mailbox struct {
+		mutex    sync.Mutex
+		Messages []mail.Message
+	}
+)
+
+// Starts the full router on a local test server, which is closed together
+// with the test. Tokens are signed with a key pair generated for the test.
+// Options may change the dependencies before the router is created.
+func New(t testing.TB, options ...func(*app.Deps)) *Harness {
+	h := &Harness{
+		Users:         repositories.NewMemoryUserRepository(),
+		Masters:       repositories.NewMemoryMasterRepository(),
+		RefreshTokens: repositories.NewMemoryRefreshTokenRepository(),
+		Revocations:   repositories.NewMemoryRevocationRepository(),
+		ApiKeys:       repositories.NewMemoryApiKeyRepository(),
+		LoginAttempts: repositories.NewMemoryLoginAttemptRepository(),
+		Audit:         repositories.NewMemoryAuditRepository(),
+		Totp:          repositories.NewMemoryTotpRepository(),
+		Identities:    repositories.NewMemoryIdentityRepository(),
+		Keys:          NewKeyManager(t),
+		Checks:        make(map[string]controllers.HealthCheck),
+		t:             t,
+	}
+
+	store := &repositories.Store{
+		Users:         h.Users,
+		Masters:       h.Masters,
+		Shares:        h.Masters,
+		RefreshTokens: h.RefreshTokens,
+		Revocations:   h.Revocations,
+		ApiKeys:       h.ApiKeys,
+		LoginAttempts: h.LoginAttempts,
+		Audit:         h.Audit,
+		Totp:          h.Totp,
+		Identities:    h.Identities,
+	}
+
+	// Revocations are not cached, so changes to the repository apply at once.
+	revocations := auth.NewRevocationList(h.Revocations, 30*time.Minute, 0)
+
+	// Mints tokens for Token like the login does.
+	h.Tokens = auth.NewTokenService(h.Keys, h.Users, h.RefreshTokens, 30*time.Minute, time.Hour)
+	h.Tokens.Revocations = revocations
+
+	// Failed logins are locked out, but not delayed, so tests can retry.
+	h.Throttle = auth.NewLoginThrottle(h.LoginAttempts, h.Audit)
+	h.Throttle.BaseDelay = 0
+	h.Throttle.MaxDelay = 0
+
+	h.Mailbox = &Mailbox{}
+	accounts := auth.NewAccountService(h.Users, h.Mailbox, h.Keys)
+	accounts.Audit = h.Audit
+	accounts.Identities = h.Identities
+
+	deps := app.Deps{
+		Store:                store,
+		Revocations:          revocations,
+		Keys:                 h.Keys,
+		TokenLifetime:        30 * time.Minute,
+		RefreshTokenLifetime: time.Hour,
+		Throttle:             h.Throttle,
+		Accounts:             accounts,
+		ReadinessChecks:      h.Checks,
+	}
+
+	for _, option := range options {
+		option(&deps)
+	}
+
+	h.Server = httptest.NewServer(app.NewRouter(deps))
+
+	if h.Oidc = deps.Oidc; h.Oidc != nil {
+		h.Oidc.OAuth.RedirectURL = h.Server.URL + "/oidc/callback"
+	}
+
+	t.Cleanup(h.Server.Close)
+	return h
+}
+
+// Enables the login at the given provider, to which the harness is
+// registered with the redirect URL of its callback route.
+func WithOidc(idp *StubIdp, configure func(*auth.OidcService)) func(*app.Deps) {
+	return func(deps *app.Deps) {
+		// The redirect URL is set once the test server has been started.
+		oauth := oauth2.Config{ClientID: idp.ClientId, ClientSecret: idp.ClientSecret}
+
+		deps.Oidc = auth.NewOidcService(idp.Issuer(), oauth, deps.Store.Users, deps.Store.Identities, deps.Keys)
+		deps.Oidc.Audit = deps.Store.Audit
+
+		if configure != nil {
+			configure(deps.Oidc)
+		}
+	}
+}
+
+// Checks passwords at the given directory first and falls back to the users
+// table.
+func WithLdap(directory *StubLdap, baseDn string, configure func(*auth.LdapAuthenticator)) func(*app.Deps) {
+	return func(deps *app.Deps) {
+		deps.Ldap = auth.NewLdapAuthenticator(directory.Url(), baseDn, deps.Store.Users, deps.Store.Identities)
+		deps.Ldap.Audit = deps.Store.Audit
+		deps.Ldap.Timeout = 5 * time.Second
+		deps.Authenticators = []string{"ldap", "local"}
+		deps.AuthenticatorFallback = true
+
+		if configure != nil {
+			configure(deps.Ldap)
+		}
+	}
+}
+
+// Creates a key manager holding a single generated key pair.
+func NewKeyManager(t testing.TB) *auth.KeyManager {
+	keys, err := auth.NewKeyManager([]auth.ManagedKey{{Pair: NewKeyPair(t)}}, 0)
+	if err != nil {
+		t.Fatalf("Could not create key manager: %s", err.Error())
+	}
+
+	return keys
+}
+
+// Generates a fresh ES256 key pair for signing test tokens.
+func NewKeyPair(t testing.TB) *auth.KeyPair {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Could not generate key: %s", err.Error())
+	}
+
+	return &auth.KeyPair{
+		Method:     jwt.SigningMethodES256,
+		PrivateKey: key,
+		PublicKey:  &key.PublicKey,
+	}
+}
+
+// Stores a viewer with the given credentials directly in the repository.
+func (h *Harness) CreateUser(username, password string) models.User {
+	h.t.Helper()
+	return h.CreateUserWithRole(username, password, models.RoleViewer)
+}
+
+// Stores a user with the given credentials and role directly in the repository.
+func (h *Harness) CreateUserWithRole(username, password string, role models.Role) models.User {
+	h.t.Helper()
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		h.t.Fatalf("Could not hash password: %s", err.Error())
+	}
+
+	user := models.User{UserName: username, Role: role}
+	if err := h.Users.Create(&user, hashedPassword); err != nil {
+		h.t.Fatalf("Could not create user: %s", err.Error())
+	}
+
+	return user
+}
+
+// Mints a valid token for the given username using the test key. Unknown
+// users receive a token carrying only their name.
+func (h *Harness) Token(username string) string {
+	h.t.Helper()
+
+	user, err := h.Users.FindByUsername(username)
+	if err != nil {
+		user = models.UserDb{UserName: username}
+	}
+
+	token, err := h.Tokens.AccessToken(user)
+	if err != nil {
+		h.t.Fatalf("Could not sign token: %s", err.Error())
+	}
+
+	return token
+}
+
+// Sends a request to the test server. The body is encoded as JSON unless it
+// is nil, and the token is sent as bearer token unless it is empty. The
+// decoded response envelope is returned together with the raw response.
+func (h *Harness) Do(method, path string, body interface{}, token string) (*http.Response, models.JsonResponse) {
+	h.t.Helper()
+
+	header := make(http.Header)
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+
+	return h.DoWithHeader(method, path, body, header)
+}
+
+// Sends a request with the given additional header fields to the test server.
+func (h *Harness) DoWithHeader(method, path string, body interface{}, header http.Header) (*http.Response, models.JsonResponse) {
+	h.t.Helper()
+
+	var reader io.Reader
+	if body != nil {
+		jsonBody, err := json.Marshal(body)
+		if err != nil {
+			h.t.Fatalf("Json marshalling failed: %s", err.Error())
+		}
+
+		reader = bytes.NewBuffer(jsonBody)
+	}
+
+	req, err := http.NewRequest(method, h.Server.URL+path, reader)
+	if err != nil {
+		h.t.Fatalf("Cannot create HTTP request: %s", err.Error())
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	res, err := h.Server.Client().Do(req)
+	if err != nil {
+		h.t.Fatalf("Error while sending request: %s", err.Error())
+	}
+
+	defer res.Body.Close()
+
+	var response models.JsonResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		h.t.Fatalf("Could not parse json: %s", err.Error())
+	}
+
+	return res, response
+}
+
+// Sends a request authenticated as the given user.
+func (h *Harness) DoAs(username, method, path string, body interface{}) (*http.Response, models.JsonResponse) {
+	h.t.Helper()
+	return h.Do(method, path, body, h.Token(username))
+}
+
+// Logs in with the given credentials and returns the issued token pair.
+func (h *Harness) Login(username, password string) models.TokenPair {
+	h.t.Helper()
+
+	credentials := models.LoginCredentials{UserName: username, Password: password}
+	res, response := h.Do("POST", "/login", credentials, "")
+	if res.StatusCode != 200 {
+		h.t.Fatalf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	var tokens models.TokenPair
+	h.Decode(response.Content, &tokens)
+
+	return tokens
+}
+
+// Converts the decoded content of a response envelope into the given value.
+func (h *Harness) Decode(content interface{}, v interface{}) {
+	h.t.Helper()
+
+	jsonContent, err := json.Marshal(content)
+	if err != nil {
+		h.t.Fatalf("Json marshalling failed: %s", err.Error())
+	}
+
+	if err := json.Unmarshal(jsonContent, v); err != nil {
+		h.t.Fatalf("Could not parse json: %s", err.Error())
+	}
+}
+
+// Records the message instead of sending it.
+func (m *Mailbox) Send(message mail.Message) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.Messages = append(m.Messages, message)
+	return nil
+}
+
+// Returns the token of the latest mail sent to the given address, which is
+// the line of the body looking like a JWT.
+func (h *Harness) MailToken(to string) string {
+	h.t.Helper()
+
+	h.Mailbox.mutex.Lock()
+	defer h.Mailbox.mutex.Unlock()
+
+	for i := len(h.Mailbox.Messages) - 1; i >= 0; i-- {
+		if h.Mailbox.Messages[i].To != to {
+			continue
+		}
+
+		for _, line := range strings.Split(h.Mailbox.Messages[i].Body, "\n") {
+			if strings.HasPrefix(line, "eyJ") {
+				return line
+			}
+		}
+	}
+
+	h.t.Fatalf("No mail with token sent to %s", to)
+	return ""
+}
@@ -0,0 +1,230 @@
+package apptest
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+type (
+	// A minimal LDAP directory supporting simple binds and searches with
+	// equality, presence, and, or and not filters.
+	StubLdap struct {
+		Listener net.Listener
+
+		mutex   sync.Mutex
+		entries []stubEntry
+		t       testing.TB
+	}
+
+	stubEntry struct {
+		Dn         string
+		Password   string
+		Attributes map[string][]string
+	}
+)
+
+// Starts a directory on a local port, which is closed together with the test.
+func NewStubLdap(t testing.TB) *StubLdap {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Could not listen: %s", err.Error())
+	}
+
+	directory := &StubLdap{Listener: listener, t: t}
+	t.Cleanup(directory.Close)
+
+	go directory.serve()
+	return directory
+}
+
+// Returns the ldap:// URL of the directory.
+func (d *StubLdap) Url() string {
+	return "ldap://" + d.Listener.Addr().String()
+}
+
+// Adds an entry, which can bind with the password if it is not empty.
+func (d *StubLdap) Add(dn, password string, attributes map[string][]string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.entries = append(d.entries, stubEntry{Dn: dn, Password: password, Attributes: attributes})
+}
+
+// Stops accepting connections, so the directory becomes unavailable.
+func (d *StubLdap) Close() {
+	d.Listener.Close()
+}
+
+func (d *StubLdap) serve() {
+	for {
+		conn, err := d.Listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go d.handle(conn)
+	}
+}
+
+// Answers the requests of a connection until it is closed or unbound.
+func (d *StubLdap) handle(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+
+		id, _ := packet.Children[0].Value.(int64)
+		request := packet.Children[1]
+
+		switch request.Tag {
+		case ldap.ApplicationBindRequest:
+			code := d.bind(request.Children[1].Data.String(), request.Children[2].Data.String())
+			d.respond(conn, id, ldapResult(ldap.ApplicationBindResponse, code))
+		case ldap.ApplicationSearchRequest:
+			d.search(conn, id, request)
+		case ldap.ApplicationUnbindRequest:
+			return
+		default:
+			d.respond(conn, id, ldapResult(request.Tag+1, ldap.LDAPResultUnwillingToPerform))
+		}
+	}
+}
+
+func (d *StubLdap) bind(dn, password string) uint16 {
+	if dn == "" && password == "" {
+		return ldap.LDAPResultSuccess
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for _, entry := range d.entries {
+		if strings.EqualFold(entry.Dn, dn) && entry.Password != "" && entry.Password == password {
+			return ldap.LDAPResultSuccess
+		}
+	}
+
+	return ldap.LDAPResultInvalidCredentials
+}
+
+// Sends the entries below the base DN matching the filter.
+func (d *StubLdap) search(conn net.Conn, id int64, request *ber.Packet) {
+	base := strings.ToLower(request.Children[0].Data.String())
+	sizeLimit, _ := request.Children[3].Value.(int64)
+	filter := request.Children[6]
+
+	d.mutex.Lock()
+	var matches []stubEntry
+	for _, entry := range d.entries {
+		dn := strings.ToLower(entry.Dn)
+		if (dn == base || strings.HasSuffix(dn, ","+base)) && entry.matches(filter) {
+			matches = append(matches, entry)
+		}
+	}
+	d.mutex.Unlock()
+
+	code := uint16(ldap.LDAPResultSuccess)
+	if sizeLimit > 0 && int64(len(matches)) > sizeLimit {
+		matches = matches[:sizeLimit]
+		code = ldap.LDAPResultSizeLimitExceeded
+	}
+
+	for _, entry := range matches {
+		result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "")
+		result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.Dn, ""))
+
+		attributes := ber.NewSequence("")
+		for name, values := range entry.Attributes {
+			attribute := ber.NewSequence("")
+			attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, ""))
+
+			set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "")
+			for _, value := range values {
+				set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, ""))
+			}
+
+			attribute.AppendChild(set)
+			attributes.AppendChild(attribute)
+		}
+
+		result.AppendChild(attributes)
+		d.respond(conn, id, result)
+	}
+
+	d.respond(conn, id, ldapResult(ldap.ApplicationSearchResultDone, code))
+}
+
+func (d *StubLdap) respond(conn net.Conn, id int64, response *ber.Packet) {
+	envelope := ber.NewSequence("")
+	envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, ""))
+	envelope.AppendChild(response)
+
+	if _, err := conn.Write(envelope.Bytes()); err != nil {
+		d.t.Logf("Could not send LDAP response: %s", err.Error())
+	}
+}
+
+// Returns whether the entry matches the filter. Unsupported filters match
+// nothing.
+func (e stubEntry) matches(filter *ber.Packet) bool {
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !e.matches(child) {
+				return false
+			}
+		}
+
+		return true
+	case ldap.FilterOr:
+		for _, child := range filter.Children {
+			if e.matches(child) {
+				return true
+			}
+		}
+
+		return false
+	case ldap.FilterNot:
+		return !e.matches(filter.Children[0])
+	case ldap.FilterEqualityMatch:
+		for _, value := range e.values(filter.Children[0].Data.String()) {
+			if strings.EqualFold(value, filter.Children[1].Data.String()) {
+				return true
+			}
+		}
+
+		return false
+	case ldap.FilterPresent:
+		return len(e.values(filter.Data.String())) > 0
+	}
+
+	return false
+}
+
+func (e stubEntry) values(attribute string) []string {
+	for name, values := range e.Attributes {
+		if strings.EqualFold(name, attribute) {
+			return values
+		}
+	}
+
+	return nil
+}
+
+func ldapResult(tag ber.Tag, code uint16) *ber.Packet {
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), ""))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, fmt.Sprintf("code %d", code), ""))
+
+	return result
+}
@@ -0,0 +1,233 @@
+package apptest
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/kluddizz/maintenance-rest-service/auth"
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+type (
+	// A minimal OpenID Connect provider, which logs in every user without
+	// asking and issues ID tokens with the configured claims.
+	StubIdp struct {
+		Server       *httptest.Server
+		Keys         *auth.KeyManager
+		ClientId     string
+		ClientSecret string
+
+		// Subject and additional claims of the next ID tokens.
+		Subject string
+		Claims  map[string]interface{}
+
+		mutex sync.Mutex
+		codes map[string]stubAuthorization
+		t     testing.TB
+	}
+
+	// An authorization waiting for its code to be exchanged.
+	stubAuthorization struct {
+		ClientId    string
+		RedirectUri string
+		Nonce       string
+		Challenge   string
+		Subject     string
+		Claims      map[string]interface{}
+	}
+)
+
+// Starts a provider on a local test server, which is closed together with
+// the test.
+func NewStubIdp(t testing.TB) *StubIdp {
+	idp := &StubIdp{
+		Keys:         NewKeyManager(t),
+		ClientId:     "maintenance",
+		ClientSecret: "secret",
+		Subject:      "subject",
+		Claims:       make(map[string]interface{}),
+		codes:        make(map[string]stubAuthorization),
+		t:            t,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.discovery)
+	mux.HandleFunc("/jwks", idp.jwks)
+	mux.HandleFunc("/authorize", idp.authorize)
+	mux.HandleFunc("/token", idp.token)
+
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Server.Close)
+
+	return idp
+}
+
+// Returns the issuer, which is the URL of the provider.
+func (idp *StubIdp) Issuer() string {
+	return idp.Server.URL
+}
+
+func (idp *StubIdp) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJson(w, 200, map[string]interface{}{
+		"issuer":                                idp.Issuer(),
+		"authorization_endpoint":                idp.Issuer() + "/authorize",
+		"token_endpoint":                        idp.Issuer() + "/token",
+		"jwks_uri":                              idp.Issuer() + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"ES256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (idp *StubIdp) jwks(w http.ResponseWriter, r *http.Request) {
+	set, err := idp.Keys.JWKS()
+	if err != nil {
+		idp.t.Errorf("Could not encode keys: %s", err.Error())
+	}
+
+	writeJson(w, 200, set)
+}
+
+// Redirects back with a new code for the current subject and claims.
+func (idp *StubIdp) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if query.Get("response_type") != "code" || query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		http.Error(w, "invalid authorization request", 400)
+		return
+	}
+
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		idp.t.Errorf("Could not generate code: %s", err.Error())
+	}
+
+	code := base64.RawURLEncoding.EncodeToString(random)
+
+	idp.mutex.Lock()
+	claims := make(map[string]interface{})
+	for name, value := range idp.Claims {
+		claims[name] = value
+	}
+
+	idp.codes[code] = stubAuthorization{
+		ClientId:    query.Get("client_id"),
+		RedirectUri: query.Get("redirect_uri"),
+		Nonce:       query.Get("nonce"),
+		Challenge:   query.Get("code_challenge"),
+		Subject:     idp.Subject,
+		Claims:      claims,
+	}
+	idp.mutex.Unlock()
+
+	redirect, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", 400)
+		return
+	}
+
+	values := redirect.Query()
+	values.Set("code", code)
+	values.Set("state", query.Get("state"))
+	redirect.RawQuery = values.Encode()
+
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// Exchanges a code for an ID token, if client and PKCE verifier match.
+func (idp *StubIdp) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", 400)
+		return
+	}
+
+	clientId, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientId, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	idp.mutex.Lock()
+	authorization, found := idp.codes[r.PostForm.Get("code")]
+	delete(idp.codes, r.PostForm.Get("code"))
+	idp.mutex.Unlock()
+
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+
+	if !found || clientId != idp.ClientId || clientSecret != idp.ClientSecret ||
+		authorization.ClientId != clientId ||
+		authorization.RedirectUri != r.PostForm.Get("redirect_uri") ||
+		authorization.Challenge != base64.RawURLEncoding.EncodeToString(challenge[:]) {
+		writeJson(w, 400, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":   idp.Issuer(),
+		"sub":   authorization.Subject,
+		"aud":   clientId,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Minute).Unix(),
+		"nonce": authorization.Nonce,
+	}
+
+	for name, value := range authorization.Claims {
+		claims[name] = value
+	}
+
+	idToken, err := idp.Keys.Sign(claims)
+	if err != nil {
+		idp.t.Errorf("Could not sign ID token: %s", err.Error())
+	}
+
+	writeJson(w, 200, map[string]interface{}{
+		"access_token": "stub",
+		"token_type":   "Bearer",
+		"expires_in":   60,
+		"id_token":     idToken,
+	})
+}
+
+// Follows the login at the provider like a browser and returns the response
+// of the callback route.
+func (h *Harness) OidcLogin() (*http.Response, models.JsonResponse) {
+	h.t.Helper()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		h.t.Fatalf("Could not create cookie jar: %s", err.Error())
+	}
+
+	client := &http.Client{Jar: jar}
+
+	res, err := client.Get(h.Server.URL + "/oidc/login")
+	if err != nil {
+		h.t.Fatalf("Error while sending request: %s", err.Error())
+	}
+
+	defer res.Body.Close()
+
+	var response models.JsonResponse
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		h.t.Fatalf("Could not parse json: %s", err.Error())
+	}
+
+	return res, response
+}
+
+func writeJson(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/kluddizz/maintenance-rest-service/auth"
+	"github.com/kluddizz/maintenance-rest-service/config"
+	"github.com/kluddizz/maintenance-rest-service/models"
+	"github.com/kluddizz/maintenance-rest-service/repositories"
+)
+
+// Creates the LDAP authenticator configured in the auth section, or nil if
+// it is not one of the authenticators.
+func newLdapAuthenticator(cfg config.AuthConfig, store *repositories.Store) (*auth.LdapAuthenticator, error) {
+	enabled := false
+	for _, name := range cfg.Authenticators {
+		enabled = enabled || name == config.AuthenticatorLdap
+	}
+
+	if !enabled {
+		return nil, nil
+	}
+
+	ldap := auth.NewLdapAuthenticator(cfg.Ldap.Url, cfg.Ldap.BaseDn, store.Users, store.Identities)
+	ldap.Audit = store.Audit
+	ldap.StartTLS = cfg.Ldap.StartTLS
+	ldap.BindDn = cfg.Ldap.BindDn
+	ldap.BindPassword = cfg.Ldap.BindPassword
+	ldap.UserFilter = cfg.Ldap.UserFilter
+	ldap.UsernameAttribute = cfg.Ldap.UsernameAttribute
+	ldap.IdAttribute = cfg.Ldap.IdAttribute
+	ldap.GroupAttribute = cfg.Ldap.GroupAttribute
+	ldap.DefaultRole = models.Role(cfg.Ldap.DefaultRole)
+	ldap.Timeout = time.Duration(cfg.Ldap.Timeout)
+
+	for group, role := range cfg.Ldap.Roles {
+		ldap.Roles[group] = models.Role(role)
+	}
+
+	if cfg.Ldap.CAFile != "" {
+		bundle, err := os.ReadFile(cfg.Ldap.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, errors.New("no certificates found in LDAP CA bundle")
+		}
+
+		directory, err := url.Parse(cfg.Ldap.Url)
+		if err != nil {
+			return nil, err
+		}
+
+		ldap.TLSConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			RootCAs:    pool,
+			ServerName: directory.Hostname(),
+		}
+	}
+
+	return ldap, nil
+}
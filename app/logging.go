@@ -0,0 +1,26 @@
+package app
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/kluddizz/maintenance-rest-service/config"
+)
+
+// Installs the default logger described by the configuration. Output of the
+// standard log package is routed through it as well.
+func SetupLogging(cfg config.LogConfig) {
+	var level slog.Level
+	level.UnmarshalText([]byte(cfg.Level))
+
+	options := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	if cfg.Format == "json" {
+		handler = slog.NewJSONHandler(os.Stderr, options)
+	} else {
+		handler = slog.NewTextHandler(os.Stderr, options)
+	}
+
+	slog.SetDefault(slog.New(handler))
+}
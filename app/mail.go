@@ -0,0 +1,21 @@
+package app
+
+import (
+	"github.com/kluddizz/maintenance-rest-service/config"
+	"github.com/kluddizz/maintenance-rest-service/mail"
+)
+
+// Creates the mailer selected by the configuration, or nil if mails are
+// disabled.
+func newMailer(cfg config.MailConfig) (mail.Mailer, error) {
+	switch cfg.Mailer {
+	case config.MailerNone:
+		return nil, nil
+	case config.MailerFile:
+		return mail.NewFileMailer(cfg.File, cfg.From)
+	case config.MailerSMTP:
+		return mail.NewSmtpMailer(cfg.Smtp.Host, cfg.Smtp.Port, cfg.Smtp.Username, cfg.Smtp.Password, cfg.From), nil
+	}
+
+	return mail.NewLogMailer(cfg.From), nil
+}
@@ -0,0 +1,35 @@
+package app
+
+import (
+	"github.com/kluddizz/maintenance-rest-service/auth"
+	"github.com/kluddizz/maintenance-rest-service/config"
+	"github.com/kluddizz/maintenance-rest-service/models"
+	"github.com/kluddizz/maintenance-rest-service/repositories"
+	"golang.org/x/oauth2"
+)
+
+// Creates the OpenID Connect login configured in the auth section, or nil if
+// it is disabled. The provider is discovered on the first login.
+func newOidcService(cfg config.OidcConfig, store *repositories.Store, keys *auth.KeyManager) *auth.OidcService {
+	if !cfg.Enabled() {
+		return nil
+	}
+
+	service := auth.NewOidcService(cfg.Issuer, oauth2.Config{
+		ClientID:     cfg.ClientId,
+		ClientSecret: cfg.ClientSecret,
+		RedirectURL:  cfg.RedirectUrl,
+		Scopes:       cfg.Scopes,
+	}, store.Users, store.Identities, keys)
+
+	service.Audit = store.Audit
+	service.UsernameClaim = cfg.UsernameClaim
+	service.GroupsClaim = cfg.GroupsClaim
+	service.DefaultRole = models.Role(cfg.DefaultRole)
+
+	for group, role := range cfg.Roles {
+		service.Roles[group] = models.Role(role)
+	}
+
+	return service
+}
@@ -0,0 +1,38 @@
+package app
+
+import (
+	"regexp"
+
+	"github.com/kluddizz/maintenance-rest-service/auth"
+	"github.com/kluddizz/maintenance-rest-service/config"
+)
+
+// Creates the password policy configured in the auth section.
+func newPasswordPolicy(cfg config.PasswordPolicyConfig) *auth.PasswordPolicy {
+	policy := auth.NewPasswordPolicy()
+	policy.MinLength = cfg.MinLength
+	policy.MaxLength = cfg.MaxLength
+	policy.CharacterClasses = cfg.CharacterClasses
+	policy.AllowUsername = cfg.AllowUsername
+
+	if cfg.BreachedPasswords != "" {
+		policy.Breached = auth.NewBreachedPasswords(cfg.BreachedPasswords)
+		policy.Breached.MinCount = cfg.BreachedMinCount
+	}
+
+	return policy
+}
+
+// Creates the username policy configured in the auth section.
+func newUsernamePolicy(cfg config.UsernamePolicyConfig) (*auth.UsernamePolicy, error) {
+	pattern, err := regexp.Compile(cfg.Pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return &auth.UsernamePolicy{
+		MinLength: cfg.MinLength,
+		MaxLength: cfg.MaxLength,
+		Pattern:   pattern,
+	}, nil
+}
@@ -0,0 +1,230 @@
+package app
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/kluddizz/maintenance-rest-service/auth"
+	"github.com/kluddizz/maintenance-rest-service/config"
+	"github.com/kluddizz/maintenance-rest-service/controllers"
+	"github.com/kluddizz/maintenance-rest-service/middlewares"
+	"github.com/kluddizz/maintenance-rest-service/models"
+	"github.com/kluddizz/maintenance-rest-service/repositories"
+)
+
+type (
+	// Everything the routes need to handle requests.
+	Deps struct {
+		Store                *repositories.Store
+		Keys                 *auth.KeyManager
+		TokenLifetime        time.Duration
+		RefreshTokenLifetime time.Duration
+
+		// Revoked access tokens, checked on every authenticated request.
+		Revocations *auth.RevocationList
+
+		// Accept verified client certificates instead of tokens.
+		ClientCertIdentity bool
+		ClientCertRole     models.Role
+
+		// Role of newly registered users, viewer if empty.
+		DefaultRole models.Role
+
+		// Slows down password guessing, disabled if nil.
+		Throttle *auth.LoginThrottle
+
+		// Second factor of logins, created with defaults if nil.
+		Totp *auth.TotpService
+
+		// Role which requires TOTP to act beyond viewer, none if empty.
+		TotpRole models.Role
+
+		// Email verification and password resets, disabled if nil.
+		Accounts *auth.AccountService
+
+		// Login at an OpenID Connect provider, disabled if nil.
+		Oidc *auth.OidcService
+
+		// Authenticators asked in order at login, the users table if empty.
+		Authenticators        []string
+		AuthenticatorFallback bool
+
+		// Directory checking passwords if it is one of the authenticators.
+		Ldap *auth.LdapAuthenticator
+
+		// Rules for passwords and usernames chosen by users, the defaults
+		// if nil.
+		PasswordPolicy *auth.PasswordPolicy
+		UsernamePolicy *auth.UsernamePolicy
+
+		// Checks reported by the readiness endpoint.
+		ReadinessChecks map[string]controllers.HealthCheck
+	}
+)
+
+// Creates the router serving all routes of the REST service.
+func NewRouter(deps Deps) http.Handler {
+	r := httprouter.New()
+	am := middlewares.NewAuth(deps.Keys)
+	am.ClientCertIdentity = deps.ClientCertIdentity
+	am.ClientCertRole = deps.ClientCertRole
+
+	// A nil list must not end up as non-nil interface value.
+	if deps.Revocations != nil {
+		am.Revocations = deps.Revocations
+	}
+
+	tokens := auth.NewTokenService(
+		deps.Keys,
+		deps.Store.Users,
+		deps.Store.RefreshTokens,
+		deps.TokenLifetime,
+		deps.RefreshTokenLifetime,
+	)
+
+	tokens.Revocations = deps.Revocations
+
+	totp := deps.Totp
+	if totp == nil {
+		totp = auth.NewTotpService(deps.Store.Totp, deps.Keys)
+		totp.Audit = deps.Store.Audit
+	}
+
+	tokens.Totp = totp.Totp
+
+	tokens.TotpRole = deps.TotpRole
+
+	apiKeys := auth.NewApiKeyService(deps.Store.ApiKeys, deps.Store.Users)
+	apiKeys.Tokens = tokens
+	am.ApiKeys = apiKeys
+	am.Users = auth.NewUserStatus(deps.Store.Users)
+
+	uc := controllers.NewUserController(deps.Store.Users, tokens)
+	if deps.DefaultRole != "" {
+		uc.DefaultRole = deps.DefaultRole
+	}
+
+	uc.Authenticator = newAuthenticator(deps, tokens)
+	uc.Audit = deps.Store.Audit
+	uc.Throttle = deps.Throttle
+	uc.Totp = totp
+	uc.Accounts = deps.Accounts
+	uc.Identities = deps.Store.Identities
+
+	acc := controllers.NewAccountController(deps.Accounts, deps.Store.Users, tokens)
+	acc.Throttle = deps.Throttle
+
+	if deps.PasswordPolicy != nil {
+		uc.Passwords = deps.PasswordPolicy
+		acc.Passwords = deps.PasswordPolicy
+	}
+
+	if deps.UsernamePolicy != nil {
+		uc.Usernames = deps.UsernamePolicy
+	}
+
+	otc := controllers.NewTotpController(totp, deps.Store.Users, tokens)
+	otc.Throttle = deps.Throttle
+
+	tc := controllers.NewTokenController(tokens)
+	akc := controllers.NewApiKeyController(apiKeys)
+	mc := controllers.NewMasterController(deps.Store.Masters, deps.Store.Shares, deps.Store.Users)
+	ac := controllers.NewAuditController(deps.Store.Audit)
+	hc := controllers.NewHealthController(deps.ReadinessChecks)
+	kc := controllers.NewKeyController(deps.Keys)
+
+	// Define the routes of the REST service.
+	r.POST("/register", uc.CreateUser)
+	r.POST("/login", uc.LoginUser)
+	r.POST("/login/totp", otc.CompleteLogin)
+	r.POST("/logout", am.AuthMiddleWare(uc.LogoutUser))
+	r.POST("/token/refresh", tc.RefreshToken)
+	r.DELETE("/users", am.AuthMiddleWare(uc.DeleteUser))
+	r.GET("/users/me", am.AuthMiddleWare(uc.GetProfile))
+	r.PATCH("/users/me", am.AuthMiddleWare(uc.UpdateProfile))
+	r.PUT("/users/me/password", am.AuthMiddleWare(uc.ChangePassword))
+
+	if deps.Accounts != nil {
+		r.POST("/email/verify", acc.VerifyEmail)
+		r.POST("/users/me/email/verification", am.AuthMiddleWare(acc.SendVerification))
+		r.POST("/password/reset", acc.RequestPasswordReset)
+		r.POST("/password/reset/confirm", acc.ResetPassword)
+	}
+
+	r.GET("/users/me/api-keys", am.AuthMiddleWare(akc.GetApiKeys))
+	r.POST("/users/me/api-keys", am.AuthMiddleWare(akc.CreateApiKey))
+	r.DELETE("/users/me/api-keys/:id", am.AuthMiddleWare(akc.DeleteApiKey))
+
+	r.POST("/users/me/totp", am.AuthMiddleWare(otc.EnrollTotp))
+	r.POST("/users/me/totp/confirm", am.AuthMiddleWare(otc.ConfirmTotp))
+	r.DELETE("/users/me/totp", am.AuthMiddleWare(otc.DisableTotp))
+	r.POST("/users/me/totp/recovery-codes", am.AuthMiddleWare(otc.RenewRecoveryCodes))
+
+	r.GET("/admin/users", am.AuthMiddleWare(middlewares.RequirePermission(models.PermissionManageUsers, uc.ListUsers)))
+	r.GET("/admin/users/:id", am.AuthMiddleWare(middlewares.RequirePermission(models.PermissionManageUsers, uc.GetUser)))
+	r.DELETE("/admin/users/:id", am.AuthMiddleWare(middlewares.RequirePermission(models.PermissionManageUsers, uc.RemoveUser)))
+	r.PUT("/admin/users/:id/status", am.AuthMiddleWare(middlewares.RequirePermission(models.PermissionManageUsers, uc.SetUserStatus)))
+	r.POST("/admin/users/:id/password-reset", am.AuthMiddleWare(middlewares.RequirePermission(models.PermissionManageUsers, uc.ForcePasswordReset)))
+	r.POST("/admin/users/:id/impersonate", am.AuthMiddleWare(middlewares.RequirePermission(models.PermissionManageUsers, uc.ImpersonateUser)))
+	r.PUT("/admin/users/:id/role", am.AuthMiddleWare(middlewares.RequirePermission(models.PermissionManageUsers, uc.SetUserRole)))
+	r.DELETE("/admin/users/:id/lockout", am.AuthMiddleWare(middlewares.RequirePermission(models.PermissionManageUsers, uc.UnlockUser)))
+	r.GET("/admin/audit", am.AuthMiddleWare(middlewares.RequirePermission(models.PermissionManageUsers, ac.GetAuditEvents)))
+
+	r.GET("/masters", am.AuthMiddleWare(middlewares.RequirePermission(models.PermissionReadMasters, mc.GetMasters)))
+	r.POST("/masters", am.AuthMiddleWare(middlewares.RequirePermission(models.PermissionWriteMasters, mc.CreateMaster)))
+
+	r.GET("/masters/:id", am.AuthMiddleWare(middlewares.RequirePermission(models.PermissionReadMasters, mc.GetMaster)))
+	r.PUT("/masters/:id", am.AuthMiddleWare(middlewares.RequirePermission(models.PermissionWriteMasters, mc.UpdateMaster)))
+	r.DELETE("/masters/:id", am.AuthMiddleWare(middlewares.RequirePermission(models.PermissionWriteMasters, mc.DeleteMaster)))
+
+	r.GET("/masters/:id/shares", am.AuthMiddleWare(middlewares.RequirePermission(models.PermissionWriteMasters, mc.GetShares)))
+	r.PUT("/masters/:id/shares", am.AuthMiddleWare(middlewares.RequirePermission(models.PermissionWriteMasters, mc.ShareMaster)))
+	r.DELETE("/masters/:id/shares/:userId", am.AuthMiddleWare(middlewares.RequirePermission(models.PermissionWriteMasters, mc.UnshareMaster)))
+
+	if deps.Oidc != nil {
+		// Users whose role changed at the provider lose their tokens.
+		if deps.Oidc.Tokens == nil {
+			deps.Oidc.Tokens = tokens
+		}
+
+		oc := controllers.NewOidcController(deps.Oidc, tokens)
+		oc.Totp = totp
+
+		r.GET("/oidc/login", oc.Login)
+		r.GET("/oidc/callback", oc.Callback)
+	}
+
+	r.GET("/.well-known/jwks.json", kc.GetJWKS)
+
+	r.GET("/healthz", hc.Liveness)
+	r.GET("/readyz", hc.Readiness)
+	r.GET("/version", hc.Version)
+
+	return r
+}
+
+// Chains the authenticators in the configured order.
+func newAuthenticator(deps Deps, tokens *auth.TokenService) auth.Authenticator {
+	chain := auth.AuthenticatorChain{Fallback: deps.AuthenticatorFallback}
+
+	for _, name := range deps.Authenticators {
+		switch {
+		case name == config.AuthenticatorLocal:
+			chain.Authenticators = append(chain.Authenticators, auth.NewLocalAuthenticator(deps.Store.Users))
+		case name == config.AuthenticatorLdap && deps.Ldap != nil:
+			// Users whose role changed in the directory lose their tokens.
+			if deps.Ldap.Tokens == nil {
+				deps.Ldap.Tokens = tokens
+			}
+
+			chain.Authenticators = append(chain.Authenticators, deps.Ldap)
+		}
+	}
+
+	if len(chain.Authenticators) == 0 {
+		return auth.NewLocalAuthenticator(deps.Store.Users)
+	}
+
+	return chain
+}
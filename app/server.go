@@ -0,0 +1,229 @@
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/kluddizz/maintenance-rest-service/auth"
+	"github.com/kluddizz/maintenance-rest-service/config"
+	"github.com/kluddizz/maintenance-rest-service/controllers"
+	"github.com/kluddizz/maintenance-rest-service/models"
+	"github.com/kluddizz/maintenance-rest-service/repositories"
+)
+
+type (
+	// The REST service bound to its database.
+	Server struct {
+		Handler   http.Handler
+		Db        *sql.DB
+		Keys      *auth.KeyManager
+		Config    *config.Config
+		TLSConfig *tls.Config
+	}
+)
+
+// Opens the database, reads the signing key and wires all routes.
+func NewServer(cfg *config.Config) (*Server, error) {
+	db, dialect, err := repositories.Open(&cfg.Database)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the key pairs used to sign and verify tokens.
+	keys, err := loadKeys(cfg.Auth)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	keyManager, err := auth.NewKeyManager(keys, time.Duration(cfg.Auth.RotationOverlap))
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// Load the certificates now, so invalid files are reported on startup.
+	var tlsConfig *tls.Config
+	if cfg.Server.TLS.Enabled() {
+		tlsConfig, err = newTLSConfig(cfg.Server.TLS)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	mailer, err := newMailer(cfg.Mail)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	store := repositories.NewSqlStore(db, dialect)
+	revocations := auth.NewRevocationList(
+		store.Revocations,
+		time.Duration(cfg.Auth.TokenLifetime),
+		time.Duration(cfg.Auth.RevocationRefresh),
+	)
+
+	throttle := auth.NewLoginThrottle(store.LoginAttempts, store.Audit)
+	throttle.BaseDelay = time.Duration(cfg.Auth.Throttle.BaseDelay)
+	throttle.MaxDelay = time.Duration(cfg.Auth.Throttle.MaxDelay)
+	throttle.LockoutThreshold = cfg.Auth.Throttle.LockoutThreshold
+	throttle.IpLockoutThreshold = cfg.Auth.Throttle.IpLockoutThreshold
+	throttle.LockoutDuration = time.Duration(cfg.Auth.Throttle.LockoutDuration)
+
+	totp := auth.NewTotpService(store.Totp, keyManager)
+	totp.Audit = store.Audit
+	totp.Issuer = cfg.Auth.Totp.Issuer
+	totp.ChallengeLifetime = time.Duration(cfg.Auth.Totp.ChallengeLifetime)
+
+	var accounts *auth.AccountService
+	if mailer != nil {
+		accounts = auth.NewAccountService(store.Users, mailer, keyManager)
+		accounts.Audit = store.Audit
+		accounts.Identities = store.Identities
+		accounts.VerificationLifetime = time.Duration(cfg.Auth.EmailVerificationLifetime)
+		accounts.ResetLifetime = time.Duration(cfg.Auth.PasswordResetLifetime)
+		accounts.LinkUrl = cfg.Mail.LinkUrl
+	}
+
+	ldap, err := newLdapAuthenticator(cfg.Auth, store)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	usernames, err := newUsernamePolicy(cfg.Auth.UsernamePolicy)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	handler := NewRouter(Deps{
+		Store:                 store,
+		Revocations:           revocations,
+		Keys:                  keyManager,
+		TokenLifetime:         time.Duration(cfg.Auth.TokenLifetime),
+		RefreshTokenLifetime:  time.Duration(cfg.Auth.RefreshTokenLifetime),
+		ClientCertIdentity:    cfg.Auth.ClientCertIdentity,
+		ClientCertRole:        models.Role(cfg.Auth.ClientCertRole),
+		DefaultRole:           models.Role(cfg.Auth.DefaultRole),
+		Throttle:              throttle,
+		Totp:                  totp,
+		TotpRole:              models.Role(cfg.Auth.Totp.RequiredRole),
+		Accounts:              accounts,
+		Oidc:                  newOidcService(cfg.Auth.Oidc, store, keyManager),
+		Authenticators:        cfg.Auth.Authenticators,
+		AuthenticatorFallback: cfg.Auth.AuthenticatorFallback,
+		Ldap:                  ldap,
+		PasswordPolicy:        newPasswordPolicy(cfg.Auth.PasswordPolicy),
+		UsernamePolicy:        usernames,
+		ReadinessChecks: map[string]controllers.HealthCheck{
+			"database": db.PingContext,
+			"signingKey": func(ctx context.Context) error {
+				_, err := loadKeys(cfg.Auth)
+				return err
+			},
+		},
+	})
+
+	return &Server{
+		Handler:   handler,
+		Db:        db,
+		Keys:      keyManager,
+		Config:    cfg,
+		TLSConfig: tlsConfig,
+	}, nil
+}
+
+// Loads all token keys described by the configuration.
+func loadKeys(cfg config.AuthConfig) ([]auth.ManagedKey, error) {
+	var keys []auth.ManagedKey
+
+	for _, key := range cfg.AllKeys() {
+		pair, err := auth.LoadKeyPair(key.Algorithm, key.PrivateKey, key.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, auth.ManagedKey{
+			Id:         key.Id,
+			Pair:       pair,
+			ActiveFrom: key.ActiveFrom,
+		})
+	}
+
+	return keys, nil
+}
+
+// Reads the key files again, so replaced keys are used without a restart.
+func (s *Server) ReloadKeys() error {
+	keys, err := loadKeys(s.Config.Auth)
+	if err != nil {
+		return err
+	}
+
+	return s.Keys.SetKeys(keys)
+}
+
+// Serves clients until the context is cancelled or the listener fails. On
+// cancellation, in-flight requests are drained within the shutdown timeout.
+// The database connection is closed before returning.
+func (s *Server) Run(ctx context.Context) error {
+	defer s.Close()
+
+	httpServer := s.httpServer()
+
+	errs := make(chan error, 1)
+	go func() {
+		if httpServer.TLSConfig != nil {
+			errs <- httpServer.ListenAndServeTLS("", "")
+		} else {
+			errs <- httpServer.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down, draining open connections")
+
+	shutdownCtx, cancel := context.WithTimeout(
+		context.Background(),
+		time.Duration(s.Config.Server.ShutdownTimeout),
+	)
+
+	defer cancel()
+
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		httpServer.Close()
+		return err
+	}
+
+	return nil
+}
+
+// Creates the HTTP server using the configured timeouts.
+func (s *Server) httpServer() *http.Server {
+	return &http.Server{
+		Addr:              s.Config.Server.Address,
+		Handler:           s.Handler,
+		ReadTimeout:       time.Duration(s.Config.Server.ReadTimeout),
+		ReadHeaderTimeout: time.Duration(s.Config.Server.ReadHeaderTimeout),
+		WriteTimeout:      time.Duration(s.Config.Server.WriteTimeout),
+		IdleTimeout:       time.Duration(s.Config.Server.IdleTimeout),
+		TLSConfig:         s.TLSConfig,
+	}
+}
+
+// Releases the database connection.
+func (s *Server) Close() error {
+	return s.Db.Close()
+}
@@ -0,0 +1,130 @@
+package app
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kluddizz/maintenance-rest-service/auth"
+	"github.com/kluddizz/maintenance-rest-service/config"
+)
+
+// Creates a server on a free local port backed by a temporary SQLite file.
+func newTestServer(t *testing.T) *Server {
+	dir := t.TempDir()
+	writeCert(t, dir, "token", "Token Key", nil, nil)
+
+	// Reserve a free port for the server.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	listener.Close()
+
+	cfg := config.Default()
+	cfg.Server.Address = listener.Addr().String()
+	cfg.Database = config.DatabaseConfig{Driver: config.DriverSQLite, Database: filepath.Join(dir, "test.db")}
+	cfg.Auth.Algorithm = auth.ES256
+	cfg.Auth.PrivateKey = filepath.Join(dir, "token.key")
+	cfg.Auth.PublicKey = ""
+
+	server, err := NewServer(cfg)
+	if err != nil {
+		t.Fatalf("Could not create server: %s", err.Error())
+	}
+
+	return server
+}
+
+// Test if the server stops serving once its context is cancelled.
+func TestRunStopsOnCancel(t *testing.T) {
+	server := newTestServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Run(ctx)
+	}()
+
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected no error but received %s", err.Error())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Server did not stop after cancellation")
+	}
+
+	// The database must be closed after shutdown.
+	if err := server.Db.Ping(); err == nil {
+		t.Errorf("Expected database to be closed")
+	}
+}
+
+// Test if requests in flight are completed during shutdown.
+func TestRunDrainsRequests(t *testing.T) {
+	server := newTestServer(t)
+	started := make(chan bool)
+	server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- true
+		time.Sleep(200 * time.Millisecond)
+		w.WriteHeader(200)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Run(ctx)
+	}()
+
+	// Retry until the listener is up.
+	statusCodes := make(chan int, 1)
+	go func() {
+		for i := 0; i < 50; i++ {
+			res, err := http.Get("http://" + server.Config.Server.Address)
+			if err == nil {
+				res.Body.Close()
+				statusCodes <- res.StatusCode
+				return
+			}
+
+			time.Sleep(20 * time.Millisecond)
+		}
+
+		statusCodes <- 0
+	}()
+
+	<-started
+	cancel()
+
+	if code := <-statusCodes; code != 200 {
+		t.Errorf("Expected status code to be %d but received %d", 200, code)
+	}
+
+	if err := <-done; err != nil {
+		t.Errorf("Expected no error but received %s", err.Error())
+	}
+}
+
+// Test if password resets are disabled unless a mailer is configured, so
+// reset tokens are not written to the log.
+func TestResetWithoutMailer(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Db.Close()
+
+	recorder := httptest.NewRecorder()
+	body := strings.NewReader(`{"email": "user@example.com"}`)
+	server.Handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/password/reset", body))
+
+	if recorder.Code != 404 {
+		t.Errorf("Expected status code to be %d but received %d", 404, recorder.Code)
+	}
+}
@@ -0,0 +1,135 @@
+package app
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kluddizz/maintenance-rest-service/config"
+)
+
+type (
+	// Serves the configured certificate and reloads it once the certificate
+	// or key file changes on disk.
+	certReloader struct {
+		certFile string
+		keyFile  string
+		interval time.Duration
+
+		mutex     sync.Mutex
+		cert      *tls.Certificate
+		modTime   time.Time
+		lastCheck time.Time
+	}
+)
+
+// Creates the TLS configuration of the server including client certificate
+// verification.
+func newTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	reloader := &certReloader{
+		certFile: cfg.CertFile,
+		keyFile:  cfg.KeyFile,
+		interval: time.Duration(cfg.ReloadInterval),
+	}
+
+	if err := reloader.load(); err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	if cfg.ClientCAFile != "" {
+		bundle, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, errors.New("no certificates found in client CA bundle")
+		}
+
+		tlsConfig.ClientCAs = pool
+	}
+
+	switch cfg.ClientAuth {
+	case config.ClientAuthOptional:
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	case config.ClientAuthRequire:
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsConfig, nil
+}
+
+// Returns the current certificate, reloading it if the files have changed
+// since the last check.
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if time.Since(r.lastCheck) >= r.interval {
+		r.lastCheck = time.Now()
+
+		if modTime, err := r.latestModTime(); err == nil && modTime.After(r.modTime) {
+			if err := r.loadLocked(); err != nil {
+				slog.Error("Could not reload TLS certificate", "error", err)
+			} else {
+				slog.Info("Reloaded TLS certificate", "file", r.certFile)
+			}
+		}
+	}
+
+	return r.cert, nil
+}
+
+// Reads the certificate and key files.
+func (r *certReloader) load() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.lastCheck = time.Now()
+	return r.loadLocked()
+}
+
+func (r *certReloader) loadLocked() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.cert = &cert
+	r.modTime = modTime
+	return nil
+}
+
+// Returns the latest modification time of the certificate and key file.
+func (r *certReloader) latestModTime() (time.Time, error) {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if keyInfo.ModTime().After(certInfo.ModTime()) {
+		return keyInfo.ModTime(), nil
+	}
+
+	return certInfo.ModTime(), nil
+}
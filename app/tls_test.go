@@ -0,0 +1,159 @@
+package app
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kluddizz/maintenance-rest-service/auth"
+	"github.com/kluddizz/maintenance-rest-service/config"
+	"github.com/kluddizz/maintenance-rest-service/models"
+	"github.com/kluddizz/maintenance-rest-service/repositories"
+)
+
+// Creates a certificate signed by the parent, or a self-signed CA if parent
+// is nil, and writes it together with its key into dir.
+func writeCert(t *testing.T, dir, name, commonName string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+	os.WriteFile(filepath.Join(dir, name+".crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(filepath.Join(dir, name+".key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key
+}
+
+// Test if verified client certificates are accepted as identity.
+func TestClientCertIdentity(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := writeCert(t, dir, "ca", "Test CA", nil, nil)
+	writeCert(t, dir, "server", "localhost", ca, caKey)
+	writeCert(t, dir, "client", "testuser", ca, caKey)
+
+	tlsConfig, err := newTLSConfig(config.TLSConfig{
+		CertFile:       filepath.Join(dir, "server.crt"),
+		KeyFile:        filepath.Join(dir, "server.key"),
+		ClientCAFile:   filepath.Join(dir, "ca.crt"),
+		ClientAuth:     config.ClientAuthOptional,
+		ReloadInterval: config.Duration(time.Minute),
+	})
+
+	if err != nil {
+		t.Fatalf("Could not create TLS config: %s", err.Error())
+	}
+
+	// Disabling the user of the same name does not affect the certificate.
+	store := repositories.NewMemoryStore()
+	disabled := models.User{UserName: "testuser"}
+	store.Users.Create(&disabled, []byte{})
+	store.Users.SetDisabled(disabled.Id, true)
+
+	keys, _ := auth.NewKeyManager(nil, 0)
+	server := httptest.NewUnstartedServer(NewRouter(Deps{
+		Store:              store,
+		Keys:               keys,
+		ClientCertIdentity: true,
+		ClientCertRole:     models.RoleViewer,
+	}))
+
+	server.Listener = tls.NewListener(server.Listener, tlsConfig)
+	server.Start()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	clientCert, _ := tls.LoadX509KeyPair(filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key"))
+
+	tests := []struct {
+		certificates []tls.Certificate
+		expected     int
+	}{
+		{[]tls.Certificate{clientCert}, 200},
+		{nil, 400},
+	}
+
+	for _, test := range tests {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:      roots,
+			Certificates: test.certificates,
+		}}}
+
+		res, err := client.Get("https://" + server.Listener.Addr().String() + "/masters")
+		if err != nil {
+			t.Fatalf("Error while sending request: %s", err.Error())
+		}
+
+		res.Body.Close()
+
+		if res.StatusCode != test.expected {
+			t.Errorf("Expected status code to be %d but received %d", test.expected, res.StatusCode)
+		}
+	}
+}
+
+// Test if changed certificate files are picked up.
+func TestCertReload(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := writeCert(t, dir, "ca", "Test CA", nil, nil)
+	writeCert(t, dir, "server", "first", ca, caKey)
+
+	reloader := &certReloader{
+		certFile: filepath.Join(dir, "server.crt"),
+		keyFile:  filepath.Join(dir, "server.key"),
+		interval: time.Nanosecond,
+	}
+
+	if err := reloader.load(); err != nil {
+		t.Fatalf("Could not load certificate: %s", err.Error())
+	}
+
+	// Replace the certificate and make sure the modification time changes.
+	writeCert(t, dir, "server", "second", ca, caKey)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(reloader.certFile, future, future)
+
+	cert, _ := reloader.GetCertificate(nil)
+	parsed, _ := x509.ParseCertificate(cert.Certificate[0])
+
+	if parsed.Subject.CommonName != "second" {
+		t.Errorf("Expected certificate subject to be %s but received %s", "second", parsed.Subject.CommonName)
+	}
+}
@@ -0,0 +1,299 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/kluddizz/maintenance-rest-service/mail"
+	"github.com/kluddizz/maintenance-rest-service/models"
+	"github.com/kluddizz/maintenance-rest-service/repositories"
+)
+
+type (
+	// Verifies emails and resets forgotten passwords by signed tokens sent
+	// by mail. Tokens are not stored; a verification token is valid as long
+	// as the email did not change, a reset token until the password changed.
+	AccountService struct {
+		Users  repositories.UserRepository
+		Mailer mail.Mailer
+
+		// Optional log of verified emails and reset passwords.
+		Audit repositories.AuditRepository
+
+		// Optional links to identity providers. Linked users have no local
+		// password and cannot reset it.
+		Identities repositories.IdentityRepository
+
+		Signer   Signer
+		Verifier Verifier
+
+		VerificationLifetime time.Duration
+		ResetLifetime        time.Duration
+
+		// Optional URL of a frontend. Mails then link to its pages
+		// `/verify-email` and `/reset-password` with the token as query.
+		LinkUrl string
+
+		now func() time.Time
+	}
+)
+
+// Audiences of tokens sent by mail, which are not accepted as access tokens.
+const (
+	VerificationAudience = "email-verification"
+	ResetAudience        = "password-reset"
+)
+
+var (
+	ErrInvalidAccountToken = errors.New("invalid or expired token")
+	ErrNoEmail             = errors.New("user has no email")
+	ErrEmailVerified       = errors.New("email is already verified")
+	ErrNoLocalPassword     = errors.New("user has no local password")
+)
+
+// Creates a new account service signing tokens with the given keys.
+func NewAccountService(users repositories.UserRepository, mailer mail.Mailer, keys *KeyManager) *AccountService {
+	return &AccountService{
+		Users:                users,
+		Mailer:               mailer,
+		Signer:               keys,
+		Verifier:             keys,
+		VerificationLifetime: 24 * time.Hour,
+		ResetLifetime:        time.Hour,
+		now:                  time.Now,
+	}
+}
+
+// Sends a mail with a verification token to the email of the user.
+func (s *AccountService) SendVerification(user models.UserDb) error {
+	if !user.Email.Valid || user.Email.String == "" {
+		return ErrNoEmail
+	}
+
+	if user.EmailVerified {
+		return ErrEmailVerified
+	}
+
+	token, err := s.sign(VerificationAudience, s.VerificationLifetime, models.AccountClaims{
+		UserId: user.Id,
+		Email:  user.Email.String,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return s.Mailer.Send(mail.Message{
+		To:      user.Email.String,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf(
+			"Hello %s,\n\nplease verify your email with the following token:\n\n%s\n%s\nThe token expires in %s.\n",
+			user.UserName, token, s.link("/verify-email", token), s.VerificationLifetime,
+		),
+		Secrets: []string{token},
+	})
+}
+
+// Marks the email of the token as verified.
+func (s *AccountService) VerifyEmail(token string) error {
+	claims, err := s.verify(VerificationAudience, token)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.Users.FindById(claims.UserId)
+	if err == nil {
+		err = s.Users.VerifyEmail(user.Id, claims.Email)
+	}
+
+	if err == repositories.ErrNotFound {
+		return ErrInvalidAccountToken
+	} else if err != nil {
+		return err
+	}
+
+	return s.record(models.AuditEmailVerified, user)
+}
+
+// Sends a reset token to every user with the given verified email. Unknown
+// emails are ignored, so the result does not reveal registered emails.
+func (s *AccountService) RequestReset(email string) error {
+	users, err := s.Users.ListByEmail(email)
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		if !user.EmailVerified {
+			continue
+		}
+
+		if local, err := s.LocalPassword(user); err != nil {
+			return err
+		} else if !local {
+			continue
+		}
+
+		if err := s.SendReset(user); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Sends a reset token to the email of the user, e.g. after an admin required
+// a new password. Returns ErrNoLocalPassword for users of identity providers.
+func (s *AccountService) SendReset(user models.UserDb) error {
+	if local, err := s.LocalPassword(user); err != nil {
+		return err
+	} else if !local {
+		return ErrNoLocalPassword
+	}
+
+	token, err := s.sign(ResetAudience, s.ResetLifetime, models.AccountClaims{
+		UserId:   user.Id,
+		Password: passwordFingerprint(user),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return s.Mailer.Send(mail.Message{
+		To:      user.Email.String,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hello %s,\n\nyou can choose a new password with the following token:\n\n%s\n%s\nThe token expires in %s. If you did not request it, ignore this mail.\n",
+			user.UserName, token, s.link("/reset-password", token), s.ResetLifetime,
+		),
+		Secrets: []string{token},
+	})
+}
+
+// Returns the user of a reset token whose password did not change since the
+// token was issued.
+func (s *AccountService) VerifyResetToken(token string) (models.UserDb, error) {
+	claims, err := s.verify(ResetAudience, token)
+	if err != nil {
+		return models.UserDb{}, err
+	}
+
+	user, err := s.Users.FindById(claims.UserId)
+	if err == repositories.ErrNotFound {
+		return user, ErrInvalidAccountToken
+	} else if err != nil {
+		return user, err
+	}
+
+	if claims.Password != passwordFingerprint(user) {
+		return user, ErrInvalidAccountToken
+	}
+
+	// Tokens are only sent to users with local password.
+	if local, err := s.LocalPassword(user); err != nil {
+		return user, err
+	} else if !local {
+		return user, ErrInvalidAccountToken
+	}
+
+	return user, nil
+}
+
+// Returns whether the user logs in with a local password.
+func (s *AccountService) LocalPassword(user models.UserDb) (bool, error) {
+	return LocalPassword(s.Identities, user)
+}
+
+// Returns whether the user logs in with a local password. Users provisioned
+// by identity providers have no password hash and a linked identity. The
+// identities are optional.
+func LocalPassword(identities repositories.IdentityRepository, user models.UserDb) (bool, error) {
+	if len(user.Password) == 0 {
+		return false, nil
+	}
+
+	if identities == nil {
+		return true, nil
+	}
+
+	linked, err := identities.Linked(user.Id)
+	return !linked, err
+}
+
+// Replaces the password of a user whose reset token was verified, which
+// invalidates the token. Returns ErrInvalidAccountToken if the password has
+// been changed since, e.g. by a concurrent reset with the same token.
+func (s *AccountService) ResetPassword(user models.UserDb, hashedPassword []byte) error {
+	err := s.Users.ReplacePassword(user.Id, user.Password, hashedPassword)
+	if err == repositories.ErrNotFound {
+		return ErrInvalidAccountToken
+	} else if err != nil {
+		return err
+	}
+
+	return s.record(models.AuditPasswordReset, user)
+}
+
+func (s *AccountService) sign(audience string, lifetime time.Duration, claims models.AccountClaims) (string, error) {
+	tokenId, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := s.now()
+	claims.StandardClaims = jwt.StandardClaims{
+		Id:        tokenId,
+		Audience:  audience,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(lifetime).Unix(),
+		Issuer:    Issuer,
+	}
+
+	return s.Signer.Sign(claims)
+}
+
+func (s *AccountService) verify(audience, token string) (*models.AccountClaims, error) {
+	claims := &models.AccountClaims{}
+
+	if err := s.Verifier.Verify(token, claims); err != nil {
+		return nil, ErrInvalidAccountToken
+	}
+
+	if claims.Audience != audience || claims.Issuer != Issuer || claims.UserId == 0 {
+		return nil, ErrInvalidAccountToken
+	}
+
+	return claims, nil
+}
+
+// Returns the link to the frontend page handling the token, if configured.
+func (s *AccountService) link(page, token string) string {
+	if s.LinkUrl == "" {
+		return ""
+	}
+
+	return "\n" + strings.TrimSuffix(s.LinkUrl, "/") + page + "?token=" + url.QueryEscape(token) + "\n"
+}
+
+func (s *AccountService) record(event string, user models.UserDb) error {
+	if s.Audit == nil {
+		return nil
+	}
+
+	return s.Audit.Record(&models.AuditEvent{
+		Event:     event,
+		Subject:   userKey(user.UserName),
+		CreatedAt: s.now().Unix(),
+	})
+}
+
+// Returns a short hash of the password hash, which changes with every new
+// password since bcrypt hashes are salted.
+func passwordFingerprint(user models.UserDb) string {
+	return HashToken(string(user.Password))[:16]
+}
@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+	"github.com/kluddizz/maintenance-rest-service/repositories"
+)
+
+type (
+	// Authenticates requests by an API key instead of a token.
+	ApiKeyVerifier interface {
+		VerifyApiKey(key string) (*models.CustomClaims, error)
+	}
+
+	// Creates and verifies API keys. Keys act on behalf of their user with the
+	// user's current role, restricted to the scopes of the key.
+	ApiKeyService struct {
+		Keys  repositories.ApiKeyRepository
+		Users repositories.UserRepository
+
+		// Optional service limiting the role of users who did not enable a
+		// required TOTP, like it does for their access tokens.
+		Tokens *TokenService
+
+		// Minimal interval in which the last use of a key is recorded.
+		TouchInterval time.Duration
+
+		now func() time.Time
+	}
+)
+
+// Prefix of all API keys, which makes leaked keys easy to recognize.
+const ApiKeyPrefix = "mrs_"
+
+var (
+	ErrInvalidApiKey = errors.New("invalid or expired API key")
+	ErrInvalidScopes = errors.New("API keys require at least one known scope")
+	ErrScopesDenied  = errors.New("scopes exceed the permissions of the user")
+)
+
+// Creates a new API key service.
+func NewApiKeyService(keys repositories.ApiKeyRepository, users repositories.UserRepository) *ApiKeyService {
+	return &ApiKeyService{
+		Keys:          keys,
+		Users:         users,
+		TouchInterval: time.Minute,
+		now:           time.Now,
+	}
+}
+
+// Creates a new key for the user. The returned key is not stored and cannot
+// be retrieved again. Returns ErrScopesDenied if the user's current role does
+// not grant all scopes.
+func (s *ApiKeyService) Create(userId int, request models.ApiKeyRequest) (models.CreatedApiKey, error) {
+	if len(request.Scopes) == 0 {
+		return models.CreatedApiKey{}, ErrInvalidScopes
+	}
+
+	for _, scope := range request.Scopes {
+		if !scope.Valid() {
+			return models.CreatedApiKey{}, ErrInvalidScopes
+		}
+	}
+
+	user, err := s.Users.FindById(userId)
+	if err != nil {
+		return models.CreatedApiKey{}, err
+	}
+
+	role, err := s.role(user)
+	if err != nil {
+		return models.CreatedApiKey{}, err
+	}
+
+	for _, scope := range request.Scopes {
+		if !role.Can(scope) {
+			return models.CreatedApiKey{}, ErrScopesDenied
+		}
+	}
+
+	secret, err := randomToken()
+	if err != nil {
+		return models.CreatedApiKey{}, err
+	}
+
+	key := ApiKeyPrefix + secret
+	apiKey := models.ApiKey{
+		UserId:    userId,
+		Name:      request.Name,
+		Prefix:    key[:len(ApiKeyPrefix)+6],
+		KeyHash:   HashToken(key),
+		Scopes:    request.Scopes,
+		CreatedAt: s.now().Unix(),
+		ExpiresAt: request.ExpiresAt,
+	}
+
+	if err := s.Keys.Create(&apiKey); err != nil {
+		return models.CreatedApiKey{}, err
+	}
+
+	return models.CreatedApiKey{ApiKey: apiKey, Key: key}, nil
+}
+
+// Returns claims for the user of the key and records the use of the key.
+func (s *ApiKeyService) VerifyApiKey(key string) (*models.CustomClaims, error) {
+	now := s.now()
+
+	apiKey, err := s.Keys.FindByHash(HashToken(key))
+	if err == repositories.ErrNotFound {
+		return nil, ErrInvalidApiKey
+	} else if err != nil {
+		return nil, err
+	}
+
+	if apiKey.ExpiresAt != 0 && apiKey.ExpiresAt <= now.Unix() {
+		return nil, ErrInvalidApiKey
+	}
+
+	user, err := s.Users.FindById(apiKey.UserId)
+	if err == repositories.ErrNotFound {
+		return nil, ErrInvalidApiKey
+	} else if err != nil {
+		return nil, err
+	}
+
+	role, err := s.role(user)
+	if err != nil {
+		return nil, err
+	}
+
+	if now.Unix()-apiKey.LastUsedAt >= int64(s.TouchInterval/time.Second) {
+		if err := s.Keys.Touch(apiKey.Id, now.Unix()); err != nil {
+			return nil, err
+		}
+	}
+
+	return &models.CustomClaims{
+		UserName: user.UserName,
+		UserId:   user.Id,
+		Role:     role,
+		ApiKeyId: apiKey.Id,
+		Scopes:   apiKey.Scopes,
+	}, nil
+}
+
+// Returns the role granted to the user, limited like the role of access
+// tokens.
+func (s *ApiKeyService) role(user models.UserDb) (models.Role, error) {
+	if s.Tokens == nil {
+		return user.Role, nil
+	}
+
+	return s.Tokens.Role(user)
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+	"github.com/kluddizz/maintenance-rest-service/repositories"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type (
+	// Checks the password of a user at login.
+	Authenticator interface {
+		// Returns the user with the given credentials. Unknown users and wrong
+		// passwords result in ErrInvalidCredentials, users without role in
+		// ErrLoginDenied.
+		Authenticate(ctx context.Context, username, password string) (models.UserDb, error)
+	}
+
+	// Checks passwords against the bcrypt hashes of the users table.
+	LocalAuthenticator struct {
+		Users repositories.UserRepository
+	}
+
+	// Asks authenticators in order until one accepts the credentials.
+	AuthenticatorChain struct {
+		Authenticators []Authenticator
+
+		// Ask the next authenticator if one fails, e.g. because its directory
+		// is not available. Otherwise the login fails with the error.
+		Fallback bool
+	}
+)
+
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// Creates a new authenticator checking the stored password hashes.
+func NewLocalAuthenticator(users repositories.UserRepository) *LocalAuthenticator {
+	return &LocalAuthenticator{Users: users}
+}
+
+// Compares the password with the stored hash of the user.
+func (a *LocalAuthenticator) Authenticate(ctx context.Context, username, password string) (models.UserDb, error) {
+	user, err := a.Users.FindByUsername(username)
+
+	if err == nil {
+		err = bcrypt.CompareHashAndPassword(user.Password, []byte(password))
+	}
+
+	// Users provisioned by an identity provider have no password
+	if err == repositories.ErrNotFound || err == bcrypt.ErrMismatchedHashAndPassword || err == bcrypt.ErrHashTooShort {
+		return user, ErrInvalidCredentials
+	}
+
+	return user, err
+}
+
+// Returns the user of the first authenticator accepting the credentials.
+// A denied login is not passed on to the next authenticator. With fallback,
+// the error of a failed authenticator is only returned if no other one
+// accepted the credentials.
+func (c AuthenticatorChain) Authenticate(ctx context.Context, username, password string) (models.UserDb, error) {
+	var failure error
+
+	for _, authenticator := range c.Authenticators {
+		user, err := authenticator.Authenticate(ctx, username, password)
+
+		if err == ErrInvalidCredentials {
+			continue
+		}
+
+		if err != nil && err != ErrLoginDenied && c.Fallback {
+			failure = errors.Join(failure, err)
+			continue
+		}
+
+		return user, err
+	}
+
+	if failure != nil {
+		return models.UserDb{}, failure
+	}
+
+	return models.UserDb{}, ErrInvalidCredentials
+}
@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+type stubAuthenticator struct {
+	user models.UserDb
+	err  error
+}
+
+func (a stubAuthenticator) Authenticate(ctx context.Context, username, password string) (models.UserDb, error) {
+	return a.user, a.err
+}
+
+// Test if the chain asks authenticators in order and falls back only if enabled.
+func TestAuthenticatorChain(t *testing.T) {
+	unavailable := errors.New("directory unavailable")
+	accepting := stubAuthenticator{user: models.UserDb{Id: 2}}
+
+	chain := AuthenticatorChain{Authenticators: []Authenticator{
+		stubAuthenticator{err: ErrInvalidCredentials},
+		stubAuthenticator{err: unavailable},
+		accepting,
+	}}
+
+	if _, err := chain.Authenticate(context.Background(), "alice", "alicepw"); err != unavailable {
+		t.Errorf("Expected error to be %v but received %v", unavailable, err)
+	}
+
+	chain.Fallback = true
+
+	if user, err := chain.Authenticate(context.Background(), "alice", "alicepw"); err != nil || user.Id != 2 {
+		t.Errorf("Expected user %d but received %v (%v)", 2, user, err)
+	}
+
+	chain.Authenticators[0] = stubAuthenticator{err: ErrLoginDenied}
+
+	if _, err := chain.Authenticate(context.Background(), "alice", "alicepw"); err != ErrLoginDenied {
+		t.Errorf("Expected error to be %v but received %v", ErrLoginDenied, err)
+	}
+
+	chain.Authenticators = chain.Authenticators[1:2]
+
+	if _, err := chain.Authenticate(context.Background(), "alice", "alicepw"); !errors.Is(err, unavailable) {
+		t.Errorf("Expected error to be %v but received %v", unavailable, err)
+	}
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type (
+	// Looks up passwords in a local copy of breached password hashes, split
+	// into range files like the k-anonymity API of Pwned Passwords. The file
+	// named by the first 5 hex digits of a password's SHA-1 hash, optionally
+	// with .txt extension, lists the remaining 35 digits of every breached
+	// hash with that prefix as `SUFFIX:COUNT` lines.
+	BreachedPasswords struct {
+		Dir string
+
+		// Hashes seen less often are ignored. Padding entries of the range
+		// files have a count of 0.
+		MinCount int
+	}
+)
+
+// Creates a new lookup of the range files in the given directory.
+func NewBreachedPasswords(dir string) *BreachedPasswords {
+	return &BreachedPasswords{Dir: dir, MinCount: 1}
+}
+
+// Returns whether the password is listed as breached. Prefixes without range
+// file contain no breached passwords.
+func (b *BreachedPasswords) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	file, err := os.Open(filepath.Join(b.Dir, prefix))
+	if errors.Is(err, fs.ErrNotExist) {
+		file, err = os.Open(filepath.Join(b.Dir, prefix+".txt"))
+	}
+
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		entry, count, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !strings.EqualFold(entry, suffix) {
+			continue
+		}
+
+		return b.seen(count), nil
+	}
+
+	return false, scanner.Err()
+}
+
+// Returns whether the count of a range file entry reaches the minimal count.
+// Entries without valid count are always breached.
+func (b *BreachedPasswords) seen(count string) bool {
+	seen, err := strconv.Atoi(count)
+	return err != nil || seen >= b.MinCount
+}
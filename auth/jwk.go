@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+type (
+	// A public key in JSON Web Key format (RFC 7517).
+	JSONWebKey struct {
+		KeyType   string `json:"kty"`
+		Use       string `json:"use,omitempty"`
+		KeyId     string `json:"kid,omitempty"`
+		Algorithm string `json:"alg,omitempty"`
+
+		// RSA keys
+		N string `json:"n,omitempty"`
+		E string `json:"e,omitempty"`
+
+		// Elliptic curve keys
+		Curve string `json:"crv,omitempty"`
+		X     string `json:"x,omitempty"`
+		Y     string `json:"y,omitempty"`
+	}
+
+	JSONWebKeySet struct {
+		Keys []JSONWebKey `json:"keys"`
+	}
+)
+
+// Returns the published keys as JSON Web Key Set.
+func (m *KeyManager) JWKS() (JSONWebKeySet, error) {
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+
+	for _, key := range m.PublicKeys() {
+		jwk, err := NewJSONWebKey(key.Pair.PublicKey)
+		if err != nil {
+			return set, err
+		}
+
+		jwk.Use = "sig"
+		jwk.KeyId = key.Id
+		jwk.Algorithm = key.Pair.Method.Alg()
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set, nil
+}
+
+// Converts an RSA or P-256 public key into its JSON Web Key representation.
+func NewJSONWebKey(publicKey crypto.PublicKey) (JSONWebKey, error) {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return JSONWebKey{
+			KeyType: "RSA",
+			N:       encodeBase64(key.N.Bytes()),
+			E:       encodeBase64(big.NewInt(int64(key.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		return JSONWebKey{
+			KeyType: "EC",
+			Curve:   key.Curve.Params().Name,
+			X:       encodeBase64(key.X.FillBytes(make([]byte, size))),
+			Y:       encodeBase64(key.Y.FillBytes(make([]byte, size))),
+		}, nil
+	}
+
+	return JSONWebKey{}, fmt.Errorf("unsupported public key type %T", publicKey)
+}
+
+// Returns the JWK thumbprint of a public key (RFC 7638).
+func Thumbprint(publicKey crypto.PublicKey) (string, error) {
+	jwk, err := NewJSONWebKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+
+	// Only the required members in lexicographic order are hashed.
+	var members interface{}
+	if jwk.KeyType == "RSA" {
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.KeyType, jwk.N}
+	} else {
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Curve, jwk.KeyType, jwk.X, jwk.Y}
+	}
+
+	canonical, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(canonical)
+	return encodeBase64(sum[:]), nil
+}
+
+func encodeBase64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
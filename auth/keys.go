@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Supported token signing algorithms.
+const (
+	RS256 = "RS256"
+	ES256 = "ES256"
+)
+
+type (
+	// Signs the claims of a token.
+	Signer interface {
+		Sign(claims jwt.Claims) (string, error)
+	}
+
+	// Parses a token into the given claims and checks its signature.
+	Verifier interface {
+		Verify(token string, claims jwt.Claims) error
+	}
+
+	// An asymmetric key pair signing tokens with the private key and
+	// verifying them with the public key. Pairs without a private key can
+	// only verify tokens.
+	KeyPair struct {
+		Method     jwt.SigningMethod
+		PrivateKey crypto.PrivateKey
+		PublicKey  crypto.PublicKey
+	}
+)
+
+var (
+	ErrNoPrivateKey = errors.New("key pair has no private key")
+)
+
+// Loads a PEM encoded key pair for the given algorithm. If no public key path
+// is given, the public key is derived from the private key.
+func LoadKeyPair(algorithm, privateKeyPath, publicKeyPath string) (*KeyPair, error) {
+	pair := &KeyPair{}
+
+	if privateKeyPath != "" {
+		pem, err := os.ReadFile(privateKeyPath)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := pair.parsePrivateKey(algorithm, pem); err != nil {
+			return nil, fmt.Errorf("%s: %w", privateKeyPath, err)
+		}
+	}
+
+	if publicKeyPath != "" {
+		pem, err := os.ReadFile(publicKeyPath)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := pair.parsePublicKey(algorithm, pem); err != nil {
+			return nil, fmt.Errorf("%s: %w", publicKeyPath, err)
+		}
+	}
+
+	if pair.PublicKey == nil {
+		return nil, errors.New("either a private or a public key is required")
+	}
+
+	return pair, nil
+}
+
+// Creates a signed token containing the claims.
+func (k *KeyPair) Sign(claims jwt.Claims) (string, error) {
+	if k.PrivateKey == nil {
+		return "", ErrNoPrivateKey
+	}
+
+	return jwt.NewWithClaims(k.Method, claims).SignedString(k.PrivateKey)
+}
+
+// Parses the token into the claims and checks that it was signed by the
+// private key of this pair using the expected algorithm.
+func (k *KeyPair) Verify(tokenString string, claims jwt.Claims) error {
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method.Alg() != k.Method.Alg() {
+			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+		}
+
+		return k.PublicKey, nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if !token.Valid {
+		return errors.New("invalid token")
+	}
+
+	return nil
+}
+
+func (k *KeyPair) parsePrivateKey(algorithm string, pem []byte) error {
+	switch algorithm {
+	case RS256:
+		key, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+		if err != nil {
+			return err
+		}
+
+		k.Method, k.PrivateKey, k.PublicKey = jwt.SigningMethodRS256, key, &key.PublicKey
+	case ES256:
+		key, err := jwt.ParseECPrivateKeyFromPEM(pem)
+		if err != nil {
+			return err
+		}
+
+		if key.Curve != elliptic.P256() {
+			return errors.New("ES256 requires a P-256 key")
+		}
+
+		k.Method, k.PrivateKey, k.PublicKey = jwt.SigningMethodES256, key, &key.PublicKey
+	default:
+		return fmt.Errorf("unsupported algorithm `%s`", algorithm)
+	}
+
+	return nil
+}
+
+func (k *KeyPair) parsePublicKey(algorithm string, pem []byte) error {
+	var publicKey crypto.PublicKey
+
+	switch algorithm {
+	case RS256:
+		key, err := jwt.ParseRSAPublicKeyFromPEM(pem)
+		if err != nil {
+			return err
+		}
+
+		k.Method, publicKey = jwt.SigningMethodRS256, key
+	case ES256:
+		key, err := jwt.ParseECPublicKeyFromPEM(pem)
+		if err != nil {
+			return err
+		}
+
+		k.Method, publicKey = jwt.SigningMethodES256, key
+	default:
+		return fmt.Errorf("unsupported algorithm `%s`", algorithm)
+	}
+
+	// The public key has to belong to the private key if both are given.
+	if k.PublicKey != nil && !samePublicKey(k.PublicKey, publicKey) {
+		return errors.New("public key does not match the private key")
+	}
+
+	k.PublicKey = publicKey
+	return nil
+}
+
+func samePublicKey(a, b crypto.PublicKey) bool {
+	switch a := a.(type) {
+	case *rsa.PublicKey:
+		return a.Equal(b)
+	case *ecdsa.PublicKey:
+		return a.Equal(b)
+	}
+
+	return false
+}
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Writes a key pair in the PEM formats produced by the commands in the README.
+func writeKeyPair(t *testing.T, algorithm string) (string, string) {
+	dir := t.TempDir()
+	var privateBlock *pem.Block
+	var publicKey interface{}
+
+	switch algorithm {
+	case RS256:
+		key, _ := rsa.GenerateKey(rand.Reader, 2048)
+		privateBlock = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+		publicKey = &key.PublicKey
+	case ES256:
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		der, _ := x509.MarshalECPrivateKey(key)
+		privateBlock = &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}
+		publicKey = &key.PublicKey
+	}
+
+	publicDer, _ := x509.MarshalPKIXPublicKey(publicKey)
+	privatePath := filepath.Join(dir, "private.key")
+	publicPath := filepath.Join(dir, "public.key")
+	os.WriteFile(privatePath, pem.EncodeToMemory(privateBlock), 0600)
+	os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDer}), 0600)
+
+	return privatePath, publicPath
+}
+
+// Test if tokens signed with the private key are verified with the public key only.
+func TestSignAndVerify(t *testing.T) {
+	for _, algorithm := range []string{RS256, ES256} {
+		privatePath, publicPath := writeKeyPair(t, algorithm)
+
+		signer, err := LoadKeyPair(algorithm, privatePath, publicPath)
+		if err != nil {
+			t.Fatalf("Could not load %s key pair: %s", algorithm, err.Error())
+		}
+
+		verifier, err := LoadKeyPair(algorithm, "", publicPath)
+		if err != nil {
+			t.Fatalf("Could not load %s public key: %s", algorithm, err.Error())
+		}
+
+		token, err := signer.Sign(jwt.StandardClaims{
+			Subject:   "testuser",
+			ExpiresAt: time.Now().Add(time.Minute).Unix(),
+		})
+
+		if err != nil {
+			t.Fatalf("Could not sign token: %s", err.Error())
+		}
+
+		var claims jwt.StandardClaims
+		if err := verifier.Verify(token, &claims); err != nil || claims.Subject != "testuser" {
+			t.Errorf("Expected %s token to be valid for %s but received %v", algorithm, "testuser", err)
+		}
+
+		if _, err := verifier.Sign(jwt.StandardClaims{}); err != ErrNoPrivateKey {
+			t.Errorf("Expected error to be %v but received %v", ErrNoPrivateKey, err)
+		}
+	}
+}
+
+// Test if tokens using another algorithm are rejected, including HMAC tokens
+// using the public key as secret.
+func TestVerifyRejectsOtherAlgorithms(t *testing.T) {
+	privatePath, publicPath := writeKeyPair(t, RS256)
+	keys, _ := LoadKeyPair(RS256, privatePath, publicPath)
+
+	publicPem, _ := os.ReadFile(publicPath)
+	forged, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{}).SignedString(publicPem)
+
+	if err := keys.Verify(forged, &jwt.StandardClaims{}); err == nil {
+		t.Errorf("Expected HS256 token to be rejected")
+	}
+
+	otherPrivate, otherPublic := writeKeyPair(t, ES256)
+	other, _ := LoadKeyPair(ES256, otherPrivate, otherPublic)
+	token, _ := other.Sign(jwt.StandardClaims{})
+
+	if err := keys.Verify(token, &jwt.StandardClaims{}); err == nil {
+		t.Errorf("Expected ES256 token to be rejected")
+	}
+}
+
+// Test if mismatching key files are reported.
+func TestLoadKeyPairMismatch(t *testing.T) {
+	privatePath, _ := writeKeyPair(t, RS256)
+	_, otherPublic := writeKeyPair(t, RS256)
+
+	if _, err := LoadKeyPair(RS256, privatePath, otherPublic); err == nil {
+		t.Errorf("Expected mismatching key pair to be rejected")
+	}
+}
@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/kluddizz/maintenance-rest-service/models"
+	"github.com/kluddizz/maintenance-rest-service/repositories"
+)
+
+type (
+	// Checks passwords by binding as the user at an LDAP directory such as
+	// Active Directory. The user is searched with a service account first,
+	// and provisioned by the URL of the directory and the user's id.
+	LdapAuthenticator struct {
+		Provisioner
+
+		// ldap:// or ldaps:// URL of the directory.
+		Url string
+
+		// Upgrade ldap:// connections with StartTLS.
+		StartTLS  bool
+		TLSConfig *tls.Config
+
+		// Service account searching users, anonymous if empty.
+		BindDn       string
+		BindPassword string
+
+		// Users are searched below the base DN with the filter, in which %s
+		// is replaced by the escaped username.
+		BaseDn     string
+		UserFilter string
+
+		// Attribute with the username stored for the user.
+		UsernameAttribute string
+
+		// Attribute with a stable id of the user, the DN if empty.
+		IdAttribute string
+
+		// Attribute with the DNs of the user's groups. Groups are mapped to
+		// roles by their DN or by the value of their first RDN, e.g. CN.
+		GroupAttribute string
+
+		Timeout time.Duration
+	}
+)
+
+// Creates a new authenticator searching users below the base DN of the
+// directory with the defaults of OpenLDAP.
+func NewLdapAuthenticator(directoryUrl, baseDn string, users repositories.UserRepository, identities repositories.IdentityRepository) *LdapAuthenticator {
+	return &LdapAuthenticator{
+		Provisioner:       NewProvisioner(users, identities),
+		Url:               directoryUrl,
+		BaseDn:            baseDn,
+		UserFilter:        "(&(objectClass=person)(uid=%s))",
+		UsernameAttribute: "uid",
+		GroupAttribute:    "memberOf",
+		Timeout:           10 * time.Second,
+	}
+}
+
+// Searches the user, binds with the password and returns the provisioned user.
+func (a *LdapAuthenticator) Authenticate(ctx context.Context, username, password string) (models.UserDb, error) {
+	// An empty password would be an unauthenticated bind, which succeeds.
+	if username == "" || password == "" {
+		return models.UserDb{}, ErrInvalidCredentials
+	}
+
+	conn, err := a.dial(ctx)
+	if err != nil {
+		return models.UserDb{}, err
+	}
+
+	defer conn.Close()
+
+	if a.BindDn != "" {
+		if err := conn.Bind(a.BindDn, a.BindPassword); err != nil {
+			return models.UserDb{}, fmt.Errorf("binding service account: %w", err)
+		}
+	}
+
+	attributes := []string{a.UsernameAttribute, a.GroupAttribute, "givenName", "sn", "mail"}
+	if a.IdAttribute != "" {
+		attributes = append(attributes, a.IdAttribute)
+	}
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		a.BaseDn, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(a.Timeout.Seconds()), false,
+		fmt.Sprintf(a.UserFilter, ldap.EscapeFilter(username)), attributes, nil,
+	))
+
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return models.UserDb{}, fmt.Errorf("searching user: %w", err)
+	}
+
+	// Ambiguous usernames are rejected like unknown ones.
+	if err != nil || len(result.Entries) != 1 {
+		return models.UserDb{}, ErrInvalidCredentials
+	}
+
+	entry := result.Entries[0]
+
+	err = conn.Bind(entry.DN, password)
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+		return models.UserDb{}, ErrInvalidCredentials
+	} else if err != nil {
+		return models.UserDb{}, fmt.Errorf("binding user: %w", err)
+	}
+
+	subject := entry.DN
+	if a.IdAttribute != "" {
+		subject = ldapId(entry.GetRawAttributeValue(a.IdAttribute))
+	}
+
+	if subject == "" {
+		return models.UserDb{}, fmt.Errorf("user %s has no %s", entry.DN, a.IdAttribute)
+	}
+
+	return a.Provision(a.Url, subject, a.profile(entry))
+}
+
+// Returns the account described by the attributes of a directory entry.
+func (a *LdapAuthenticator) profile(entry *ldap.Entry) Profile {
+	profile := Profile{
+		UserName:  entry.GetAttributeValue(a.UsernameAttribute),
+		FirstName: entry.GetAttributeValue("givenName"),
+		LastName:  entry.GetAttributeValue("sn"),
+		Email:     entry.GetAttributeValue("mail"),
+	}
+
+	for _, group := range entry.GetAttributeValues(a.GroupAttribute) {
+		profile.Groups = append(profile.Groups, group)
+
+		dn, err := ldap.ParseDN(group)
+		if err == nil && len(dn.RDNs) > 0 && len(dn.RDNs[0].Attributes) > 0 {
+			profile.Groups = append(profile.Groups, dn.RDNs[0].Attributes[0].Value)
+		}
+	}
+
+	return profile
+}
+
+// Connects to the directory, upgrading the connection if configured.
+func (a *LdapAuthenticator) dial(ctx context.Context) (*ldap.Conn, error) {
+	dialer := &net.Dialer{Timeout: a.Timeout}
+	if deadline, ok := ctx.Deadline(); ok {
+		dialer.Deadline = deadline
+	}
+
+	tlsConfig := a.TLSConfig
+	if tlsConfig == nil {
+		directory, err := url.Parse(a.Url)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig = &tls.Config{ServerName: directory.Hostname()}
+	}
+
+	conn, err := ldap.DialURL(a.Url, ldap.DialWithDialer(dialer), ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, fmt.Errorf("connecting to directory: %w", err)
+	}
+
+	conn.SetTimeout(a.Timeout)
+
+	if a.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("starting TLS: %w", err)
+		}
+	}
+
+	return conn, nil
+}
+
+// Returns a textual id like entryUUID as is and a binary one like the
+// objectGUID of Active Directory hex encoded.
+func ldapId(value []byte) string {
+	if utf8.Valid(value) {
+		return string(value)
+	}
+
+	return hex.EncodeToString(value)
+}
@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+type (
+	// A key pair identified by its key id, which is used for signing from
+	// the given point in time on.
+	ManagedKey struct {
+		Id         string
+		Pair       *KeyPair
+		ActiveFrom time.Time
+	}
+
+	// Signs tokens with the currently active key and verifies tokens by their
+	// `kid` header. Once a newer key becomes active, the previous key keeps
+	// verifying tokens for the overlap period before it is retired.
+	KeyManager struct {
+		Overlap time.Duration
+
+		mutex sync.RWMutex
+		keys  []ManagedKey
+		now   func() time.Time
+	}
+)
+
+var (
+	ErrNoActiveKey = errors.New("no active signing key")
+	ErrUnknownKey  = errors.New("unknown or retired key id")
+)
+
+// Creates a key manager for the given keys. Keys without id are identified by
+// their thumbprint.
+func NewKeyManager(keys []ManagedKey, overlap time.Duration) (*KeyManager, error) {
+	m := &KeyManager{
+		Overlap: overlap,
+		now:     time.Now,
+	}
+
+	if err := m.SetKeys(keys); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// Replaces the managed keys, e.g. after the key files were rotated.
+func (m *KeyManager) SetKeys(keys []ManagedKey) error {
+	keys = append([]ManagedKey(nil), keys...)
+	ids := make(map[string]bool)
+
+	for i := range keys {
+		if keys[i].Id == "" {
+			thumbprint, err := Thumbprint(keys[i].Pair.PublicKey)
+			if err != nil {
+				return err
+			}
+
+			keys[i].Id = thumbprint
+		}
+
+		if ids[keys[i].Id] {
+			return fmt.Errorf("duplicate key id `%s`", keys[i].Id)
+		}
+
+		ids[keys[i].Id] = true
+	}
+
+	sort.SliceStable(keys, func(i, j int) bool {
+		return keys[i].ActiveFrom.Before(keys[j].ActiveFrom)
+	})
+
+	// Only the initial key is active from the start, further keys would
+	// retire it at once.
+	if len(keys) > 1 && keys[1].ActiveFrom.IsZero() {
+		return fmt.Errorf("key `%s` has no activation time", keys[1].Id)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.keys = keys
+	return nil
+}
+
+// Creates a token signed by the active key, stating its id in the header.
+func (m *KeyManager) Sign(claims jwt.Claims) (string, error) {
+	key, err := m.SigningKey()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(key.Pair.Method, claims)
+	token.Header["kid"] = key.Id
+
+	return token.SignedString(key.Pair.PrivateKey)
+}
+
+// Parses the token into the claims and checks its signature using the key
+// named in the `kid` header.
+func (m *KeyManager) Verify(tokenString string, claims jwt.Claims) error {
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		id, _ := token.Header["kid"].(string)
+
+		key, ok := m.verificationKey(id)
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+
+		if token.Method.Alg() != key.Pair.Method.Alg() {
+			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+		}
+
+		return key.Pair.PublicKey, nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if !token.Valid {
+		return errors.New("invalid token")
+	}
+
+	return nil
+}
+
+// Returns the latest key with a private key which is already active.
+func (m *KeyManager) SigningKey() (ManagedKey, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	now := m.now()
+	for i := len(m.keys) - 1; i >= 0; i-- {
+		key := m.keys[i]
+		if !key.ActiveFrom.After(now) && key.Pair.PrivateKey != nil {
+			return key, nil
+		}
+	}
+
+	return ManagedKey{}, ErrNoActiveKey
+}
+
+// Returns all keys which are not retired yet, including keys which become
+// active in the future, so verifiers can pick them up in advance.
+func (m *KeyManager) PublicKeys() []ManagedKey {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var keys []ManagedKey
+	for i, key := range m.keys {
+		if !m.retired(i) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// Returns the key with the given id if it may still verify tokens.
+func (m *KeyManager) verificationKey(id string) (ManagedKey, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	for i, key := range m.keys {
+		if key.Id == id && !m.retired(i) {
+			return key, true
+		}
+	}
+
+	return ManagedKey{}, false
+}
+
+// Checks whether a newer key has been signing for longer than the overlap.
+// Keys without private key cannot sign, so they never retire older keys.
+func (m *KeyManager) retired(i int) bool {
+	now := m.now()
+	for _, newer := range m.keys[i+1:] {
+		if newer.Pair.PrivateKey != nil && !newer.ActiveFrom.Add(m.Overlap).After(now) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func newTestPair(t *testing.T) *KeyPair {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	return &KeyPair{Method: jwt.SigningMethodES256, PrivateKey: key, PublicKey: &key.PublicKey}
+}
+
+// Test if keys are rotated at their activation time and retired after the overlap.
+func TestKeyRotation(t *testing.T) {
+	now := time.Now()
+	rotation := now.Add(time.Hour)
+
+	manager, err := NewKeyManager([]ManagedKey{
+		{Id: "new", Pair: newTestPair(t), ActiveFrom: rotation},
+		{Id: "old", Pair: newTestPair(t)},
+	}, 10*time.Minute)
+
+	if err != nil {
+		t.Fatalf("Could not create key manager: %s", err.Error())
+	}
+
+	manager.now = func() time.Time { return now }
+
+	// Before the rotation the old key signs, but both keys are published.
+	oldToken, _ := manager.Sign(jwt.StandardClaims{})
+	if key, _ := manager.SigningKey(); key.Id != "old" {
+		t.Errorf("Expected signing key to be %s but received %s", "old", key.Id)
+	}
+
+	if set, _ := manager.JWKS(); len(set.Keys) != 2 {
+		t.Errorf("Expected %d published keys but received %d", 2, len(set.Keys))
+	}
+
+	// During the overlap the new key signs and old tokens are still valid.
+	manager.now = func() time.Time { return rotation.Add(5 * time.Minute) }
+
+	newToken, _ := manager.Sign(jwt.StandardClaims{})
+	token, _, _ := new(jwt.Parser).ParseUnverified(newToken, &jwt.StandardClaims{})
+	if token.Header["kid"] != "new" {
+		t.Errorf("Expected kid to be %s but received %v", "new", token.Header["kid"])
+	}
+
+	if err := manager.Verify(oldToken, &jwt.StandardClaims{}); err != nil {
+		t.Errorf("Expected old token to be valid during overlap but received %s", err.Error())
+	}
+
+	// After the overlap the old key is retired.
+	manager.now = func() time.Time { return rotation.Add(11 * time.Minute) }
+
+	if err := manager.Verify(oldToken, &jwt.StandardClaims{}); err == nil {
+		t.Errorf("Expected old token to be rejected after overlap")
+	}
+
+	if err := manager.Verify(newToken, &jwt.StandardClaims{}); err != nil {
+		t.Errorf("Expected new token to be valid but received %s", err.Error())
+	}
+
+	if set, _ := manager.JWKS(); len(set.Keys) != 1 || set.Keys[0].KeyId != "new" {
+		t.Errorf("Expected only the new key to be published but received %v", set.Keys)
+	}
+}
+
+// Test if keys without id are identified by their thumbprint.
+func TestThumbprintKeyId(t *testing.T) {
+	pair := newTestPair(t)
+	manager, _ := NewKeyManager([]ManagedKey{{Pair: pair}}, 0)
+	thumbprint, _ := Thumbprint(pair.PublicKey)
+
+	if key, _ := manager.SigningKey(); key.Id != thumbprint || len(thumbprint) != 43 {
+		t.Errorf("Expected key id to be %s but received %s", thumbprint, key.Id)
+	}
+}
+
+// Test if only signing keys retire older keys and if further keys need an
+// activation time.
+func TestKeyRetirement(t *testing.T) {
+	now := time.Now()
+	verifying := newTestPair(t)
+	verifying.PrivateKey = nil
+
+	manager, err := NewKeyManager([]ManagedKey{
+		{Id: "old", Pair: newTestPair(t)},
+		{Id: "verifying", Pair: verifying, ActiveFrom: now.Add(-time.Hour)},
+	}, 0)
+
+	if err != nil {
+		t.Fatalf("Could not create key manager: %s", err.Error())
+	}
+
+	token, _ := manager.Sign(jwt.StandardClaims{})
+	if err := manager.Verify(token, &jwt.StandardClaims{}); err != nil {
+		t.Errorf("Expected old key to keep verifying but received %s", err.Error())
+	}
+
+	_, err = NewKeyManager([]ManagedKey{
+		{Id: "old", Pair: newTestPair(t)},
+		{Id: "new", Pair: newTestPair(t)},
+	}, 0)
+
+	if err == nil {
+		t.Errorf("Expected key without activation time to be rejected")
+	}
+}
@@ -0,0 +1,224 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/kluddizz/maintenance-rest-service/models"
+	"github.com/kluddizz/maintenance-rest-service/repositories"
+	"golang.org/x/oauth2"
+)
+
+type (
+	// Logs users in at an external OpenID Connect provider using the
+	// authorization code flow with PKCE. Users are provisioned by issuer and
+	// subject of their ID token.
+	OidcService struct {
+		Provisioner
+
+		Issuer string
+
+		// Client credentials and redirect URL registered at the provider.
+		// The endpoints are discovered from the issuer.
+		OAuth oauth2.Config
+
+		// Signs the cookie keeping state, nonce and PKCE verifier.
+		Signer   Signer
+		Verifier Verifier
+
+		UsernameClaim string
+		GroupsClaim   string
+
+		// Time to login at the provider.
+		StateLifetime time.Duration
+
+		// Client for requests to the provider, the default client if nil.
+		Client *http.Client
+
+		mutex    sync.Mutex
+		provider *oidc.Provider
+	}
+)
+
+// Audience of the state cookie, which is not accepted as access token.
+const OidcStateAudience = "oidc-state"
+
+var ErrInvalidOidcState = errors.New("invalid or expired login state")
+
+// Creates a new OpenID Connect service for the given issuer and client,
+// which signs its state with the given keys.
+func NewOidcService(issuer string, oauth oauth2.Config, users repositories.UserRepository, identities repositories.IdentityRepository, keys *KeyManager) *OidcService {
+	if len(oauth.Scopes) == 0 {
+		oauth.Scopes = []string{oidc.ScopeOpenID, "profile", "email"}
+	}
+
+	return &OidcService{
+		Provisioner:   NewProvisioner(users, identities),
+		Issuer:        issuer,
+		OAuth:         oauth,
+		Signer:        keys,
+		Verifier:      keys,
+		UsernameClaim: "preferred_username",
+		GroupsClaim:   "groups",
+		StateLifetime: 10 * time.Minute,
+	}
+}
+
+// Returns the URL of the provider's login page and the signed state, which
+// has to be sent back together with the authorization code.
+func (s *OidcService) Start(ctx context.Context) (string, string, error) {
+	provider, err := s.discover(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := randomToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	nonce, err := randomToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	now := s.now()
+	claims := models.OidcStateClaims{
+		State:    state,
+		Nonce:    nonce,
+		Verifier: oauth2.GenerateVerifier(),
+		StandardClaims: jwt.StandardClaims{
+			Audience:  OidcStateAudience,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(s.StateLifetime).Unix(),
+			Issuer:    Issuer,
+		},
+	}
+
+	signedState, err := s.Signer.Sign(claims)
+	if err != nil {
+		return "", "", err
+	}
+
+	config := s.config(provider)
+	return config.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(claims.Verifier)), signedState, nil
+}
+
+// Exchanges the authorization code for an ID token and returns its user,
+// who is created on the first login.
+func (s *OidcService) Finish(ctx context.Context, signedState, state, code string) (models.UserDb, error) {
+	claims := &models.OidcStateClaims{}
+
+	err := s.Verifier.Verify(signedState, claims)
+	if err != nil || claims.Audience != OidcStateAudience || claims.Issuer != Issuer ||
+		subtle.ConstantTimeCompare([]byte(claims.State), []byte(state)) != 1 {
+		return models.UserDb{}, ErrInvalidOidcState
+	}
+
+	provider, err := s.discover(ctx)
+	if err != nil {
+		return models.UserDb{}, err
+	}
+
+	ctx = s.context(ctx)
+	config := s.config(provider)
+
+	token, err := config.Exchange(ctx, code, oauth2.VerifierOption(claims.Verifier))
+	if err != nil {
+		return models.UserDb{}, fmt.Errorf("exchanging code: %w", err)
+	}
+
+	rawIdToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return models.UserDb{}, errors.New("token response without id_token")
+	}
+
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: s.OAuth.ClientID}).Verify(ctx, rawIdToken)
+	if err != nil {
+		return models.UserDb{}, fmt.Errorf("verifying id_token: %w", err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(claims.Nonce)) != 1 {
+		return models.UserDb{}, errors.New("id_token with invalid nonce")
+	}
+
+	var idClaims map[string]interface{}
+	if err := idToken.Claims(&idClaims); err != nil {
+		return models.UserDb{}, err
+	}
+
+	return s.Provision(idToken.Issuer, idToken.Subject, s.profile(idClaims))
+}
+
+// Returns the account described by the claims of an ID token.
+func (s *OidcService) profile(claims map[string]interface{}) Profile {
+	verified, _ := claims["email_verified"].(bool)
+
+	profile := Profile{
+		UserName:      stringClaim(claims, s.UsernameClaim),
+		FirstName:     stringClaim(claims, "given_name"),
+		LastName:      stringClaim(claims, "family_name"),
+		Email:         stringClaim(claims, "email"),
+		EmailVerified: verified,
+	}
+
+	switch groups := claims[s.GroupsClaim].(type) {
+	case string:
+		profile.Groups = []string{groups}
+	case []interface{}:
+		for _, group := range groups {
+			if name, ok := group.(string); ok {
+				profile.Groups = append(profile.Groups, name)
+			}
+		}
+	}
+
+	return profile
+}
+
+// Discovers the endpoints of the provider once.
+func (s *OidcService) discover(ctx context.Context) (*oidc.Provider, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.provider != nil {
+		return s.provider, nil
+	}
+
+	provider, err := oidc.NewProvider(s.context(ctx), s.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("discovering provider: %w", err)
+	}
+
+	s.provider = provider
+	return provider, nil
+}
+
+// Returns the client configuration with the discovered endpoints.
+func (s *OidcService) config(provider *oidc.Provider) oauth2.Config {
+	config := s.OAuth
+	config.Endpoint = provider.Endpoint()
+
+	return config
+}
+
+// Returns a context using the configured client for requests to the provider.
+func (s *OidcService) context(ctx context.Context) context.Context {
+	if s.Client == nil {
+		return ctx
+	}
+
+	return oidc.ClientContext(ctx, s.Client)
+}
+
+func stringClaim(claims map[string]interface{}, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
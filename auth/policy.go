@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type (
+	// Rules for passwords chosen by users.
+	PasswordPolicy struct {
+		// Minimal number of characters.
+		MinLength int
+
+		// Maximal number of bytes. bcrypt only uses the first 72 bytes.
+		MaxLength int
+
+		// Number of character classes a password has to contain, out of
+		// lowercase letters, uppercase letters, digits and other characters.
+		CharacterClasses int
+
+		// Allow passwords containing the username.
+		AllowUsername bool
+
+		// Optional list of breached passwords, which are rejected.
+		Breached *BreachedPasswords
+	}
+
+	// Rules for usernames chosen at registration.
+	UsernamePolicy struct {
+		MinLength int
+		MaxLength int
+		Pattern   *regexp.Regexp
+	}
+
+	// Lists every rule a username or password violates.
+	PolicyError struct {
+		Subject    string
+		Violations []string
+	}
+)
+
+// Bytes of a password used by bcrypt.
+const BcryptMaxLength = 72
+
+// Creates a new password policy requiring 8 characters not containing the
+// username.
+func NewPasswordPolicy() *PasswordPolicy {
+	return &PasswordPolicy{
+		MinLength: 8,
+		MaxLength: BcryptMaxLength,
+	}
+}
+
+// Creates a new username policy allowing 3 to 64 letters, digits and the
+// characters . _ @ -, starting with a letter or digit.
+func NewUsernamePolicy() *UsernamePolicy {
+	return &UsernamePolicy{
+		MinLength: 3,
+		MaxLength: 64,
+		Pattern:   regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._@-]*$`),
+	}
+}
+
+// Returns a PolicyError if the password of the user violates the policy.
+// Other errors are returned if the breached passwords cannot be read.
+func (p *PasswordPolicy) Validate(username, password string) error {
+	violations := []string{}
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, fmt.Sprintf("must have at least %d characters", p.MinLength))
+	}
+
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		violations = append(violations, fmt.Sprintf("must not be longer than %d bytes", p.MaxLength))
+	}
+
+	if characterClasses(password) < p.CharacterClasses {
+		violations = append(violations, fmt.Sprintf(
+			"must contain %d of lowercase letters, uppercase letters, digits and other characters", p.CharacterClasses,
+		))
+	}
+
+	// Very short usernames would be found in many passwords.
+	if !p.AllowUsername && utf8.RuneCountInString(username) >= 3 && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		violations = append(violations, "must not contain the username")
+	}
+
+	// Passwords are only looked up if they are acceptable otherwise.
+	if len(violations) == 0 && p.Breached != nil {
+		breached, err := p.Breached.Contains(password)
+		if err != nil {
+			return err
+		}
+
+		if breached {
+			violations = append(violations, "has appeared in a data breach, choose another one")
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Subject: "password", Violations: violations}
+	}
+
+	return nil
+}
+
+// Returns a PolicyError if the username violates the policy.
+func (p *UsernamePolicy) Validate(username string) error {
+	violations := []string{}
+	length := utf8.RuneCountInString(username)
+
+	if length < p.MinLength {
+		violations = append(violations, fmt.Sprintf("must have at least %d characters", p.MinLength))
+	}
+
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violations = append(violations, fmt.Sprintf("must not have more than %d characters", p.MaxLength))
+	}
+
+	if p.Pattern != nil && username != "" && !p.Pattern.MatchString(username) {
+		violations = append(violations, fmt.Sprintf("must match %s", p.Pattern.String()))
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Subject: "username", Violations: violations}
+	}
+
+	return nil
+}
+
+// Describes all violations, e.g. "Invalid password: must have at least 8
+// characters, must not contain the username".
+func (e *PolicyError) Error() string {
+	return "Invalid " + e.Subject + ": " + strings.Join(e.Violations, ", ")
+}
+
+// Returns the number of character classes the password contains.
+func characterClasses(password string) int {
+	var lower, upper, digit, other bool
+
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+
+	classes := 0
+	for _, present := range []bool{lower, upper, digit, other} {
+		if present {
+			classes++
+		}
+	}
+
+	return classes
+}
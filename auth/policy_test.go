@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test if passwords violating the policy are rejected with every violation.
+func TestPasswordPolicy(t *testing.T) {
+	policy := NewPasswordPolicy()
+	policy.CharacterClasses = 3
+
+	for password, violations := range map[string]int{
+		"Correct-Horse-7":         0,
+		"short":                   2,
+		"alice-Battery-7":         1,
+		strings.Repeat("Aa1", 25): 1,
+	} {
+		var policyErr *PolicyError
+		err := policy.Validate("Alice", password)
+
+		if violations == 0 && err != nil {
+			t.Errorf("Expected %s to be accepted but received %v", password, err)
+		} else if violations > 0 && (!errors.As(err, &policyErr) || len(policyErr.Violations) != violations) {
+			t.Errorf("Expected %d violations of %s but received %v", violations, password, err)
+		}
+	}
+}
+
+// Test if breached passwords are found in the range file of their prefix.
+func TestBreachedPasswords(t *testing.T) {
+	dir := t.TempDir()
+	sum := sha1.Sum([]byte("Correct-Horse-7"))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	padding := strings.Repeat("0", 35) + ":0\r\n"
+	if err := os.WriteFile(filepath.Join(dir, hash[:5]+".txt"), []byte(padding+hash[5:]+":3\r\n"), 0o600); err != nil {
+		t.Fatalf("Could not write range file: %s", err.Error())
+	}
+
+	breached := NewBreachedPasswords(dir)
+	policy := NewPasswordPolicy()
+	policy.Breached = breached
+
+	if err := policy.Validate("alice", "Correct-Horse-7"); err == nil {
+		t.Errorf("Expected breached password to be rejected")
+	}
+
+	if err := policy.Validate("alice", "Battery-Staple-7"); err != nil {
+		t.Errorf("Expected password without range file to be accepted but received %v", err)
+	}
+
+	// Rarely breached passwords may be accepted.
+	breached.MinCount = 4
+	if found, err := breached.Contains("Correct-Horse-7"); err != nil || found {
+		t.Errorf("Expected password below the minimal count to be accepted but received %v (%v)", found, err)
+	}
+}
+
+// Test if usernames have to match the pattern.
+func TestUsernamePolicy(t *testing.T) {
+	policy := NewUsernamePolicy()
+
+	for username, valid := range map[string]bool{
+		"alice.smith@example": true,
+		"al":                  false,
+		".alice":              false,
+		"alice smith":         false,
+	} {
+		if err := policy.Validate(username); (err == nil) != valid {
+			t.Errorf("Expected %s to be valid %v but received %v", username, valid, err)
+		}
+	}
+}
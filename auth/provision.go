@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+	"github.com/kluddizz/maintenance-rest-service/repositories"
+)
+
+type (
+	// Links accounts of external identity providers to stored users, which
+	// are created on the first login. Users receive the role mapped from
+	// their groups on every login.
+	Provisioner struct {
+		Users      repositories.UserRepository
+		Identities repositories.IdentityRepository
+
+		// Optional log of provisioned users and changed roles.
+		Audit repositories.AuditRepository
+
+		// Optional service revoking the tokens of users whose role changed.
+		Tokens *TokenService
+
+		// Roles of groups. Users of several groups receive the most
+		// privileged role, users without mapped group the default role or,
+		// if empty, are not allowed to login.
+		Roles       map[string]models.Role
+		DefaultRole models.Role
+
+		now func() time.Time
+	}
+
+	// Account of a user at an identity provider.
+	Profile struct {
+		UserName      string
+		FirstName     string
+		LastName      string
+		Email         string
+		EmailVerified bool
+		Groups        []string
+	}
+)
+
+var ErrLoginDenied = errors.New("no role is mapped to the groups of the user")
+
+// Creates a new provisioner mapping no group and assigning the viewer role.
+func NewProvisioner(users repositories.UserRepository, identities repositories.IdentityRepository) Provisioner {
+	return Provisioner{
+		Users:       users,
+		Identities:  identities,
+		Roles:       make(map[string]models.Role),
+		DefaultRole: models.RoleViewer,
+		now:         time.Now,
+	}
+}
+
+// Returns the user linked to the subject of the issuer with the role of the
+// groups in the profile, or creates a new user if the subject is not linked
+// or its user has been deleted.
+func (p *Provisioner) Provision(issuer, subject string, profile Profile) (models.UserDb, error) {
+	role, ok := p.Role(profile.Groups)
+	if !ok {
+		return models.UserDb{}, ErrLoginDenied
+	}
+
+	identity, err := p.Identities.Find(issuer, subject)
+	if err == repositories.ErrNotFound {
+		return p.create(issuer, subject, role, profile)
+	} else if err != nil {
+		return models.UserDb{}, err
+	}
+
+	user, err := p.Users.FindById(identity.UserId)
+	if err == repositories.ErrNotFound {
+		// The link outlived its user, so the subject is provisioned again.
+		if err := p.Identities.Delete(issuer, subject); err != nil && err != repositories.ErrNotFound {
+			return models.UserDb{}, err
+		}
+
+		return p.create(issuer, subject, role, profile)
+	}
+
+	if err != nil || user.Role == role {
+		return user, err
+	}
+
+	if err := p.Users.SetRole(user.Id, role); err != nil {
+		return user, err
+	}
+
+	if p.Tokens != nil {
+		if err := p.Tokens.RevokeUser(user.Id); err != nil {
+			return user, err
+		}
+	}
+
+	err = p.record(models.AuditRoleChanged, user, fmt.Sprintf("%s to %s by groups of %s", user.Role, role, issuer))
+	user.Role = role
+
+	return user, err
+}
+
+// Returns the most privileged role mapped to one of the groups, otherwise
+// the default role. Groups are compared case-insensitively. Returns false if
+// no role applies.
+func (p *Provisioner) Role(groups []string) (models.Role, bool) {
+	role := p.DefaultRole
+
+	for mappedGroup, mapped := range p.Roles {
+		for _, group := range groups {
+			if strings.EqualFold(group, mappedGroup) && (!role.Valid() || mapped.Includes(role)) {
+				role = mapped
+			}
+		}
+	}
+
+	return role, role.Valid()
+}
+
+// Creates a user for the subject. If the username is taken by another user,
+// it is made unique by a suffix derived from the subject.
+func (p *Provisioner) create(issuer, subject string, role models.Role, profile Profile) (models.UserDb, error) {
+	username := profile.UserName
+	if username == "" {
+		username = subject
+	}
+
+	user := models.User{
+		UserName:  username,
+		FirstName: profile.FirstName,
+		LastName:  profile.LastName,
+		Email:     profile.Email,
+		Role:      role,
+	}
+
+	// Without password hash, the user cannot login with a local password.
+	err := p.Users.Create(&user, []byte{})
+	if err == repositories.ErrDuplicate {
+		user.UserName = username + "-" + HashToken(issuer + " " + subject)[:6]
+		err = p.Users.Create(&user, []byte{})
+	}
+
+	if err != nil {
+		return models.UserDb{}, err
+	}
+
+	err = p.Identities.Create(models.Identity{Issuer: issuer, Subject: subject, UserId: user.Id})
+	if err != nil {
+		return models.UserDb{}, err
+	}
+
+	if profile.EmailVerified && user.Email != "" {
+		if err := p.Users.VerifyEmail(user.Id, user.Email); err != nil {
+			return models.UserDb{}, err
+		}
+	}
+
+	created, err := p.Users.FindById(user.Id)
+	if err != nil {
+		return created, err
+	}
+
+	return created, p.record(models.AuditUserProvisioned, created, "subject "+subject+" of "+issuer)
+}
+
+func (p *Provisioner) record(event string, user models.UserDb, details string) error {
+	if p.Audit == nil {
+		return nil
+	}
+
+	return p.Audit.Record(&models.AuditEvent{
+		Event:     event,
+		Subject:   userKey(user.UserName),
+		Details:   details,
+		CreatedAt: p.now().Unix(),
+	})
+}
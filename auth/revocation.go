@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+	"github.com/kluddizz/maintenance-rest-service/repositories"
+)
+
+type (
+	// Decides whether verified claims have been revoked.
+	RevocationChecker interface {
+		IsRevoked(claims *models.CustomClaims) (bool, error)
+	}
+
+	// Keeps the revoked access tokens of the repository in memory. The cache
+	// is reloaded after the refresh interval, so revocations made by other
+	// instances of the service are picked up.
+	RevocationList struct {
+		Revocations     repositories.RevocationRepository
+		RefreshInterval time.Duration
+
+		// Lifetime of access tokens, after which user revocations are removed.
+		TokenLifetime time.Duration
+
+		mutex    sync.RWMutex
+		tokens   map[string]int64
+		users    map[int]int64
+		loadedAt time.Time
+		now      func() time.Time
+	}
+)
+
+var ErrNoTokenId = errors.New("token has no id")
+
+// Creates a new revocation list on top of the given repository.
+func NewRevocationList(revocations repositories.RevocationRepository, tokenLifetime, refreshInterval time.Duration) *RevocationList {
+	return &RevocationList{
+		Revocations:     revocations,
+		RefreshInterval: refreshInterval,
+		TokenLifetime:   tokenLifetime,
+		tokens:          make(map[string]int64),
+		users:           make(map[int]int64),
+		now:             time.Now,
+	}
+}
+
+// Removes expired revocations from the repository and reloads the cache.
+func (l *RevocationList) Load() error {
+	now := l.now()
+
+	err := l.Revocations.DeleteExpired(now.Unix(), now.Add(-l.TokenLifetime).UnixMicro())
+	if err != nil {
+		return err
+	}
+
+	revokedTokens, err := l.Revocations.ListTokens(now.Unix())
+	if err != nil {
+		return err
+	}
+
+	revokedUsers, err := l.Revocations.ListUsers()
+	if err != nil {
+		return err
+	}
+
+	tokens := make(map[string]int64, len(revokedTokens))
+	for _, token := range revokedTokens {
+		tokens[token.TokenId] = token.ExpiresAt
+	}
+
+	users := make(map[int]int64, len(revokedUsers))
+	for _, user := range revokedUsers {
+		users[user.UserId] = user.RevokedBefore
+	}
+
+	l.mutex.Lock()
+	l.tokens = tokens
+	l.users = users
+	l.loadedAt = now
+	l.mutex.Unlock()
+
+	return nil
+}
+
+// Returns whether the token itself or all tokens of its user were revoked.
+// Impersonation tokens are revoked with the tokens of the impersonating
+// admin as well.
+func (l *RevocationList) IsRevoked(claims *models.CustomClaims) (bool, error) {
+	l.mutex.RLock()
+	stale := l.now().Sub(l.loadedAt) >= l.RefreshInterval
+	l.mutex.RUnlock()
+
+	if stale {
+		if err := l.Load(); err != nil {
+			return false, err
+		}
+	}
+
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	if _, ok := l.tokens[claims.Id]; ok && claims.Id != "" {
+		return true, nil
+	}
+
+	for _, userId := range []int{claims.UserId, claims.ImpersonatorId} {
+		if before, ok := l.users[userId]; ok && userId != 0 && issuedAtMicro(claims) < before {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Revokes the token described by the claims until it expires.
+func (l *RevocationList) RevokeToken(claims *models.CustomClaims) error {
+	if claims.Id == "" {
+		return ErrNoTokenId
+	}
+
+	err := l.Revocations.RevokeToken(models.RevokedToken{
+		TokenId:   claims.Id,
+		ExpiresAt: claims.ExpiresAt,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	l.mutex.Lock()
+	l.tokens[claims.Id] = claims.ExpiresAt
+	l.mutex.Unlock()
+
+	return nil
+}
+
+// Revokes all tokens of the user issued up to now.
+func (l *RevocationList) RevokeUser(userId int) error {
+	revocation := models.UserRevocation{
+		UserId:        userId,
+		RevokedBefore: l.now().UnixMicro(),
+	}
+
+	if err := l.Revocations.RevokeUser(revocation); err != nil {
+		return err
+	}
+
+	l.mutex.Lock()
+	l.users[userId] = revocation.RevokedBefore
+	l.mutex.Unlock()
+
+	return nil
+}
+
+// Returns the issue time of the token in microseconds. Tokens without it are
+// assumed to be issued at the start of their second.
+func issuedAtMicro(claims *models.CustomClaims) int64 {
+	if claims.IssuedAtMicro != 0 {
+		return claims.IssuedAtMicro
+	}
+
+	return claims.IssuedAt * int64(time.Second/time.Microsecond)
+}
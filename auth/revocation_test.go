@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/kluddizz/maintenance-rest-service/models"
+	"github.com/kluddizz/maintenance-rest-service/repositories"
+)
+
+// Test if revocations are shared through the repository and expire with the tokens.
+func TestRevocationList(t *testing.T) {
+	now := time.Now()
+	repository := repositories.NewMemoryRevocationRepository()
+
+	list := NewRevocationList(repository, time.Hour, time.Minute)
+	list.now = func() time.Time { return now }
+
+	token := &models.CustomClaims{
+		UserId:         1,
+		StandardClaims: jwt.StandardClaims{Id: "token", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Hour).Unix()},
+	}
+
+	if err := list.RevokeToken(token); err != nil {
+		t.Fatalf("Could not revoke token: %s", err.Error())
+	}
+
+	// A second instance only sees revocations stored in the repository.
+	other := NewRevocationList(repository, time.Hour, time.Minute)
+	other.now = list.now
+
+	if revoked, err := other.IsRevoked(token); !revoked || err != nil {
+		t.Errorf("Expected token to be revoked (%v)", err)
+	}
+
+	// Tokens issued before the user revocation are rejected, later ones not,
+	// even within the same second. Tokens without issue time in
+	// microseconds count as issued at the start of their second.
+	now = time.Unix(now.Unix(), int64(500*time.Millisecond))
+	older := &models.CustomClaims{UserId: 2, IssuedAtMicro: now.UnixMicro() - 1}
+	newer := &models.CustomClaims{UserId: 2, IssuedAtMicro: now.UnixMicro()}
+	legacy := &models.CustomClaims{UserId: 2, StandardClaims: jwt.StandardClaims{IssuedAt: now.Unix()}}
+
+	if err := list.RevokeUser(2); err != nil {
+		t.Fatalf("Could not revoke user: %s", err.Error())
+	}
+
+	for _, claims := range []*models.CustomClaims{older, legacy} {
+		if revoked, _ := list.IsRevoked(claims); !revoked {
+			t.Errorf("Expected token issued before the revocation to be revoked")
+		}
+	}
+
+	if revoked, _ := list.IsRevoked(newer); revoked {
+		t.Errorf("Expected token issued after the revocation to be valid")
+	}
+
+	// Impersonation tokens are revoked with the impersonating admin.
+	impersonated := &models.CustomClaims{UserId: 3, ImpersonatorId: 2, IssuedAtMicro: now.UnixMicro() - 1}
+	if revoked, _ := list.IsRevoked(impersonated); !revoked {
+		t.Errorf("Expected token of the impersonator to be revoked")
+	}
+
+	// Once all revoked tokens expired, the revocations are removed.
+	now = now.Add(2 * time.Hour)
+	if err := list.Load(); err != nil {
+		t.Fatalf("Could not load revocations: %s", err.Error())
+	}
+
+	if tokens, _ := repository.ListTokens(0); len(tokens) != 0 {
+		t.Errorf("Expected revoked tokens to be removed but received %v", tokens)
+	}
+
+	if users, _ := repository.ListUsers(); len(users) != 0 {
+		t.Errorf("Expected user revocations to be removed but received %v", users)
+	}
+}
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"github.com/kluddizz/maintenance-rest-service/models"
+	"github.com/kluddizz/maintenance-rest-service/repositories"
+)
+
+type (
+	// Decides whether the user of verified claims has been disabled.
+	UserStatusChecker interface {
+		IsDisabled(claims *models.CustomClaims) (bool, error)
+	}
+
+	// Looks up the status of users in the repository on every request, so
+	// disabling a user takes effect immediately.
+	UserStatus struct {
+		Users repositories.UserRepository
+	}
+)
+
+// Creates a new status checker on top of the given repository.
+func NewUserStatus(users repositories.UserRepository) *UserStatus {
+	return &UserStatus{Users: users}
+}
+
+// Returns whether the user or the admin impersonating the user is disabled
+// or has been deleted. Claims without user, e.g. of client certificates, are
+// never disabled.
+func (s *UserStatus) IsDisabled(claims *models.CustomClaims) (bool, error) {
+	for _, id := range []int{claims.UserId, claims.ImpersonatorId} {
+		if id == 0 {
+			continue
+		}
+
+		user, err := s.Users.FindById(id)
+		if err == repositories.ErrNotFound {
+			return true, nil
+		} else if err != nil {
+			return false, err
+		}
+
+		if user.Disabled {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
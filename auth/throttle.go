@@ -0,0 +1,304 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+	"github.com/kluddizz/maintenance-rest-service/repositories"
+)
+
+type (
+	// Tracks failed password checks per username and client address. Every
+	// failure doubles the delay before the next attempt up to the maximal
+	// delay, and reaching the threshold locks the key out. Failures older than
+	// the lockout duration are forgotten.
+	LoginThrottle struct {
+		Attempts repositories.LoginAttemptRepository
+		Audit    repositories.AuditRepository
+
+		BaseDelay          time.Duration
+		MaxDelay           time.Duration
+		LockoutThreshold   int
+		IpLockoutThreshold int
+		LockoutDuration    time.Duration
+
+		now func() time.Time
+	}
+
+	// Returned while a username or client address has to wait.
+	ThrottledError struct {
+		RetryAfter time.Duration
+		Locked     bool
+	}
+)
+
+func (e *ThrottledError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("locked out, retry in %s", e.RetryAfter)
+	}
+
+	return fmt.Sprintf("too many failed attempts, retry in %s", e.RetryAfter)
+}
+
+// Creates a new login throttle storing failures in the given repositories.
+func NewLoginThrottle(attempts repositories.LoginAttemptRepository, audit repositories.AuditRepository) *LoginThrottle {
+	return &LoginThrottle{
+		Attempts:           attempts,
+		Audit:              audit,
+		BaseDelay:          time.Second,
+		MaxDelay:           time.Minute,
+		LockoutThreshold:   10,
+		IpLockoutThreshold: 50,
+		LockoutDuration:    15 * time.Minute,
+		now:                time.Now,
+	}
+}
+
+// Returns a ThrottledError if the username or the client address must wait
+// before the password may be checked.
+func (t *LoginThrottle) Check(username, ip string) error {
+	return t.check(attemptKey(username), ipKey(ip))
+}
+
+// Returns a ThrottledError if reset mails to the email or requests of the
+// client address must wait.
+func (t *LoginThrottle) CheckReset(email, ip string) error {
+	return t.check(resetKey(email), ipKey(ip))
+}
+
+// Records a requested reset mail like a failed login, so repeated requests
+// for an email or from a client address are delayed and locked out.
+func (t *LoginThrottle) ResetRequested(email, ip string) error {
+	if err := t.fail(resetKey(email), t.LockoutThreshold); err != nil {
+		return err
+	}
+
+	return t.fail(ipKey(ip), t.IpLockoutThreshold)
+}
+
+func (t *LoginThrottle) check(keys ...string) error {
+	now := t.now()
+	var wait *ThrottledError
+
+	for _, key := range keys {
+		attempt, err := t.attempt(key, now)
+		if err != nil {
+			return err
+		}
+
+		retry := &ThrottledError{}
+		if lockedUntil := time.Unix(attempt.LockedUntil, 0); now.Before(lockedUntil) {
+			retry.RetryAfter = lockedUntil.Sub(now)
+			retry.Locked = true
+		} else if attempt.Failures > 0 {
+			retry.RetryAfter = time.Unix(attempt.LastFailure, 0).Add(t.delay(attempt.Failures)).Sub(now)
+		}
+
+		if retry.RetryAfter > 0 && (wait == nil || retry.RetryAfter > wait.RetryAfter) {
+			wait = retry
+		}
+	}
+
+	if wait != nil {
+		return wait
+	}
+
+	return nil
+}
+
+// Checks like Check and counts a failure of the username before its password
+// is compared, so concurrent attempts cannot all pass the check. Returns a
+// ThrottledError as well if another attempt of the username was counted in
+// the meantime. The result of the comparison is recorded by Rejected or
+// Success.
+func (t *LoginThrottle) Reserve(username, ip string) error {
+	return t.reserve(attemptKey(username), ip)
+}
+
+// Records the failure of a reserved password check. The failure of the
+// username has been counted already, so it is only locked out if it reached
+// its threshold, while the failure of the client address is counted.
+func (t *LoginThrottle) Rejected(username, ip string) error {
+	return t.rejected(attemptKey(username), ip)
+}
+
+// Reserves the check of a TOTP or recovery code of the username like Reserve.
+// Codes are counted apart from passwords, so knowing the password does not
+// forget failed codes. The result is recorded by CodeRejected or
+// CodeSuccess.
+func (t *LoginThrottle) ReserveCode(username, ip string) error {
+	return t.reserve(codeKey(username), ip)
+}
+
+// Records the failure of a reserved code check like Rejected.
+func (t *LoginThrottle) CodeRejected(username, ip string) error {
+	return t.rejected(codeKey(username), ip)
+}
+
+// Forgets the failed codes of a username after a code was accepted.
+func (t *LoginThrottle) CodeSuccess(username string) error {
+	return t.Attempts.Delete(codeKey(username))
+}
+
+func (t *LoginThrottle) reserve(key, ip string) error {
+	now := t.now()
+
+	checked, err := t.attempt(key, now)
+	if err != nil {
+		return err
+	}
+
+	if err := t.check(key, ipKey(ip)); err != nil {
+		return err
+	}
+
+	attempt, err := t.Attempts.Fail(key, now.Unix(), now.Add(-t.LockoutDuration).Unix())
+	if err != nil {
+		return err
+	}
+
+	if attempt.Failures != checked.Failures+1 {
+		return &ThrottledError{RetryAfter: t.delay(attempt.Failures)}
+	}
+
+	return nil
+}
+
+func (t *LoginThrottle) rejected(key, ip string) error {
+	attempt, err := t.Attempts.Get(key)
+	if err != nil && err != repositories.ErrNotFound {
+		return err
+	}
+
+	if err == nil {
+		if err := t.lock(attempt, t.LockoutThreshold, t.now()); err != nil {
+			return err
+		}
+	}
+
+	return t.fail(ipKey(ip), t.IpLockoutThreshold)
+}
+
+// Records a failed password check and locks the username or the client
+// address out if they reached their threshold.
+func (t *LoginThrottle) Failure(username, ip string) error {
+	if err := t.fail(attemptKey(username), t.LockoutThreshold); err != nil {
+		return err
+	}
+
+	return t.fail(ipKey(ip), t.IpLockoutThreshold)
+}
+
+// Forgets the failures of a username after its password was checked
+// successfully. Failures of the client address are kept, so one known
+// password does not allow to guess others.
+func (t *LoginThrottle) Success(username string) error {
+	return t.Attempts.Delete(attemptKey(username))
+}
+
+// Lifts the lockout of a username and its codes, e.g. on behalf of an admin.
+func (t *LoginThrottle) Unlock(username, actor string) error {
+	if err := t.Attempts.Delete(attemptKey(username)); err != nil {
+		return err
+	}
+
+	if err := t.Attempts.Delete(codeKey(username)); err != nil {
+		return err
+	}
+
+	return t.Audit.Record(&models.AuditEvent{
+		Event:     models.AuditUnlock,
+		Subject:   attemptKey(username),
+		Actor:     actor,
+		CreatedAt: t.now().Unix(),
+	})
+}
+
+func (t *LoginThrottle) fail(key string, threshold int) error {
+	now := t.now()
+
+	attempt, err := t.Attempts.Fail(key, now.Unix(), now.Add(-t.LockoutDuration).Unix())
+	if err != nil {
+		return err
+	}
+
+	return t.lock(attempt, threshold, now)
+}
+
+// Locks the key of the attempt out if its failures reached the threshold.
+func (t *LoginThrottle) lock(attempt models.LoginAttempt, threshold int, now time.Time) error {
+	if threshold <= 0 || attempt.Failures < threshold {
+		return nil
+	}
+
+	// Only the failure which locks the key records the lockout.
+	attempt.LockedUntil = now.Add(t.LockoutDuration).Unix()
+
+	locked, err := t.Attempts.Lock(attempt.Key, now.Unix(), attempt.LockedUntil)
+	if err != nil || !locked {
+		return err
+	}
+
+	return t.Audit.Record(&models.AuditEvent{
+		Event:     models.AuditLockout,
+		Subject:   attempt.Key,
+		Details:   fmt.Sprintf("%d failed attempts, locked until %s", attempt.Failures, time.Unix(attempt.LockedUntil, 0).UTC().Format(time.RFC3339)),
+		CreatedAt: now.Unix(),
+	})
+}
+
+// Returns the failures of the key, which are empty if the last failure
+// is older than the lockout duration.
+func (t *LoginThrottle) attempt(key string, now time.Time) (models.LoginAttempt, error) {
+	attempt, err := t.Attempts.Get(key)
+	if err == repositories.ErrNotFound {
+		return models.LoginAttempt{Key: key}, nil
+	} else if err != nil {
+		return attempt, err
+	}
+
+	expired := time.Unix(attempt.LastFailure, 0).Add(t.LockoutDuration).Before(now)
+	if expired && attempt.LockedUntil <= now.Unix() {
+		return models.LoginAttempt{Key: key}, nil
+	}
+
+	return attempt, nil
+}
+
+// Returns the delay after the given number of failures.
+func (t *LoginThrottle) delay(failures int) time.Duration {
+	delay := t.BaseDelay
+	for i := 1; i < failures && delay < t.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > t.MaxDelay {
+		return t.MaxDelay
+	}
+
+	return delay
+}
+
+func userKey(username string) string {
+	return "user:" + username
+}
+
+// Failures are counted case-insensitively, so variants of a username cannot
+// be used to try more passwords.
+func attemptKey(username string) string {
+	return userKey(strings.ToLower(username))
+}
+
+func codeKey(username string) string {
+	return "totp:" + strings.ToLower(username)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func resetKey(email string) string {
+	return "reset:" + strings.ToLower(email)
+}
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kluddizz/maintenance-rest-service/repositories"
+)
+
+// Test if failures delay further attempts exponentially and lock out at the threshold.
+func TestLoginThrottle(t *testing.T) {
+	now := time.Unix(time.Now().Unix(), 0)
+	audit := repositories.NewMemoryAuditRepository()
+
+	throttle := NewLoginThrottle(repositories.NewMemoryLoginAttemptRepository(), audit)
+	throttle.LockoutThreshold = 3
+	throttle.now = func() time.Time { return now }
+
+	if err := throttle.Check("alice", "10.0.0.1"); err != nil {
+		t.Fatalf("Expected first attempt to be allowed but received %v", err)
+	}
+
+	for i, expected := range []time.Duration{time.Second, 2 * time.Second} {
+		throttle.Failure("alice", "10.0.0.1")
+
+		err, _ := throttle.Check("alice", "10.0.0.2").(*ThrottledError)
+		if err == nil || err.RetryAfter != expected {
+			t.Errorf("Expected delay %d to be %s but received %v", i, expected, err)
+		}
+	}
+
+	// Variants of the username share its failures.
+	if err := throttle.Check("Alice", "10.0.0.2"); err == nil {
+		t.Errorf("Expected username variant to be delayed")
+	}
+
+	// Another user from the same address only waits for the address.
+	if err, _ := throttle.Check("bob", "10.0.0.1").(*ThrottledError); err == nil || err.RetryAfter != 2*time.Second {
+		t.Errorf("Expected client address to be delayed but received %v", err)
+	}
+
+	throttle.Failure("alice", "10.0.0.1")
+	if err, _ := throttle.Check("alice", "10.0.0.2").(*ThrottledError); err == nil || !err.Locked {
+		t.Errorf("Expected user to be locked out but received %v", err)
+	}
+
+	if events, _ := audit.List(10); len(events) != 1 {
+		t.Errorf("Expected one lockout event but received %v", events)
+	}
+
+	// The lockout ends after its duration.
+	now = now.Add(throttle.LockoutDuration + time.Second)
+	if err := throttle.Check("alice", "10.0.0.2"); err != nil {
+		t.Errorf("Expected lockout to be over but received %v", err)
+	}
+}
+
+// Test if concurrent failures are counted each and lock out only once.
+func TestLoginThrottleConcurrent(t *testing.T) {
+	attempts := repositories.NewMemoryLoginAttemptRepository()
+	audit := repositories.NewMemoryAuditRepository()
+	throttle := NewLoginThrottle(attempts, audit)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2*throttle.LockoutThreshold; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			throttle.Failure("alice", "10.0.0.1")
+		}()
+	}
+
+	wg.Wait()
+
+	if attempt, _ := attempts.Get(attemptKey("alice")); attempt.Failures != 2*throttle.LockoutThreshold {
+		t.Errorf("Expected %d failures but received %v", 2*throttle.LockoutThreshold, attempt)
+	}
+
+	if events, _ := audit.List(10); len(events) != 1 {
+		t.Errorf("Expected one lockout event but received %v", events)
+	}
+}
+
+// Test if only one of concurrent reservations is allowed and if rejected
+// reservations lock out at the threshold.
+func TestLoginThrottleReserve(t *testing.T) {
+	attempts := repositories.NewMemoryLoginAttemptRepository()
+	audit := repositories.NewMemoryAuditRepository()
+	throttle := NewLoginThrottle(attempts, audit)
+	throttle.LockoutThreshold = 1
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	allowed := 0
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if throttle.Reserve("alice", "10.0.0.1") == nil {
+				mutex.Lock()
+				allowed++
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if allowed != 1 {
+		t.Errorf("Expected %d allowed reservation but received %d", 1, allowed)
+	}
+
+	// The reservation reached the threshold, so its rejection locks out.
+	throttle.Rejected("alice", "10.0.0.1")
+	if err, _ := throttle.Check("alice", "10.0.0.2").(*ThrottledError); err == nil || !err.Locked {
+		t.Errorf("Expected user to be locked out but received %v", err)
+	}
+
+	if attempt, _ := attempts.Get(ipKey("10.0.0.1")); attempt.Failures != 1 {
+		t.Errorf("Expected %d failure of the address but received %v", 1, attempt)
+	}
+
+	// A successful check forgets the reservation.
+	throttle.Success("bob")
+	if err := throttle.Reserve("bob", "10.0.0.3"); err != nil {
+		t.Fatalf("Expected reservation to be allowed but received %v", err)
+	}
+
+	throttle.Success("bob")
+	if err := throttle.Check("bob", "10.0.0.3"); err != nil {
+		t.Errorf("Expected failures to be forgotten but received %v", err)
+	}
+}
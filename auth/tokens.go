@@ -0,0 +1,291 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/kluddizz/maintenance-rest-service/models"
+	"github.com/kluddizz/maintenance-rest-service/repositories"
+)
+
+type (
+	// Issues access tokens together with rotating refresh tokens. Every
+	// refresh token can be used once and is replaced by a new one of the same
+	// family. Using a token twice revokes the whole family.
+	TokenService struct {
+		Signer          Signer
+		Users           repositories.UserRepository
+		RefreshTokens   repositories.RefreshTokenRepository
+		AccessLifetime  time.Duration
+		RefreshLifetime time.Duration
+
+		// Lifetime of access tokens issued to admins impersonating a user,
+		// limited to the access lifetime.
+		ImpersonationLifetime time.Duration
+
+		// Optional list receiving revoked access tokens.
+		Revocations *RevocationList
+
+		// Users of this or a more privileged role only act as viewers until
+		// they enabled TOTP. Disabled if empty.
+		TotpRole models.Role
+		Totp     repositories.TotpRepository
+
+		now func() time.Time
+	}
+)
+
+const (
+	Issuer = "maintenance-rest-service"
+
+	// Audience of access tokens. Tokens for other purposes are signed with
+	// the same keys, so verifiers have to require it.
+	AccessAudience = "maintenance-api"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used")
+	ErrUserDisabled        = errors.New("user is disabled")
+)
+
+// Creates a new token service signing access tokens with the given signer.
+func NewTokenService(signer Signer, users repositories.UserRepository, refreshTokens repositories.RefreshTokenRepository, accessLifetime, refreshLifetime time.Duration) *TokenService {
+	return &TokenService{
+		Signer:          signer,
+		Users:           users,
+		RefreshTokens:   refreshTokens,
+		AccessLifetime:  accessLifetime,
+		RefreshLifetime: refreshLifetime,
+
+		ImpersonationLifetime: 15 * time.Minute,
+
+		now: time.Now,
+	}
+}
+
+// Signs a new access token for the given user.
+func (s *TokenService) AccessToken(user models.UserDb) (string, error) {
+	return s.sign(user, nil, s.AccessLifetime)
+}
+
+// Signs an access token for the user on behalf of an admin, e.g. to
+// reproduce a support case. The claims name the admin and the token cannot
+// be refreshed.
+func (s *TokenService) Impersonate(user models.UserDb, impersonator *models.CustomClaims) (models.ImpersonationToken, error) {
+	if user.Disabled {
+		return models.ImpersonationToken{}, ErrUserDisabled
+	}
+
+	lifetime := min(s.ImpersonationLifetime, s.AccessLifetime)
+	if lifetime <= 0 {
+		lifetime = s.AccessLifetime
+	}
+
+	accessToken, err := s.sign(user, impersonator, lifetime)
+	if err != nil {
+		return models.ImpersonationToken{}, err
+	}
+
+	return models.ImpersonationToken{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(lifetime / time.Second),
+	}, nil
+}
+
+// Signs an access token of the user valid for the given lifetime, which is
+// marked as impersonated if the impersonator is given.
+func (s *TokenService) sign(user models.UserDb, impersonator *models.CustomClaims, lifetime time.Duration) (string, error) {
+	tokenId, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := s.now()
+
+	role, err := s.Role(user)
+	if err != nil {
+		return "", err
+	}
+
+	claims := models.CustomClaims{
+		UserName:      user.UserName,
+		UserId:        user.Id,
+		Role:          role,
+		IssuedAtMicro: now.UnixMicro(),
+		StandardClaims: jwt.StandardClaims{
+			Id:        tokenId,
+			Audience:  AccessAudience,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(lifetime).Unix(),
+			Issuer:    Issuer,
+		},
+	}
+
+	if impersonator != nil {
+		claims.ImpersonatorId = impersonator.UserId
+		claims.ImpersonatorName = impersonator.UserName
+	}
+
+	return s.Signer.Sign(claims)
+}
+
+// Issues an access token and a refresh token starting a new family, e.g.
+// after the user logged in with a password. Returns ErrUserDisabled if the
+// user is disabled.
+func (s *TokenService) Issue(user models.UserDb) (models.TokenPair, error) {
+	familyId, err := randomToken()
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	return s.issue(user, familyId)
+}
+
+// Replaces the given refresh token by a new token pair. If the token has been
+// used before, all tokens of its family are revoked.
+func (s *TokenService) Refresh(refreshToken string) (models.TokenPair, error) {
+	now := s.now().Unix()
+
+	token, err := s.RefreshTokens.FindByHash(HashToken(refreshToken))
+	if err == repositories.ErrNotFound {
+		return models.TokenPair{}, ErrInvalidRefreshToken
+	} else if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	if token.RevokedAt.Valid || token.ExpiresAt <= now {
+		return models.TokenPair{}, ErrInvalidRefreshToken
+	}
+
+	// A token which was already replaced is either replayed by an attacker or
+	// by a client whose token was stolen. Either way the family is burnt.
+	err = s.RefreshTokens.MarkUsed(token.Id, now)
+	if err == repositories.ErrNotFound {
+		if err := s.RefreshTokens.RevokeFamily(token.FamilyId, now); err != nil {
+			return models.TokenPair{}, err
+		}
+
+		return models.TokenPair{}, ErrRefreshTokenReused
+	} else if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	user, err := s.Users.FindById(token.UserId)
+	if err == repositories.ErrNotFound || (err == nil && user.Disabled) {
+		return models.TokenPair{}, ErrInvalidRefreshToken
+	} else if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	return s.issue(user, token.FamilyId)
+}
+
+// Revokes the access token described by the claims and, if given, the family
+// of the user's refresh token.
+func (s *TokenService) Logout(claims *models.CustomClaims, refreshToken string) error {
+	if refreshToken != "" {
+		token, err := s.RefreshTokens.FindByHash(HashToken(refreshToken))
+		if err == nil && token.UserId == claims.UserId {
+			err = s.RefreshTokens.RevokeFamily(token.FamilyId, s.now().Unix())
+		}
+
+		if err != nil && err != repositories.ErrNotFound {
+			return err
+		}
+	}
+
+	if s.Revocations == nil {
+		return nil
+	}
+
+	return s.Revocations.RevokeToken(claims)
+}
+
+// Revokes all access and refresh tokens of the given user, e.g. after the
+// user was deleted or changed the password.
+func (s *TokenService) RevokeUser(userId int) error {
+	if err := s.RefreshTokens.RevokeUser(userId, s.now().Unix()); err != nil {
+		return err
+	}
+
+	if s.Revocations == nil {
+		return nil
+	}
+
+	return s.Revocations.RevokeUser(userId)
+}
+
+// Returns the role granted to the user, which is limited to viewer if the
+// role requires TOTP but the user did not enable it.
+func (s *TokenService) Role(user models.UserDb) (models.Role, error) {
+	if s.TotpRole == "" || s.Totp == nil || !user.Role.Includes(s.TotpRole) {
+		return user.Role, nil
+	}
+
+	secret, err := s.Totp.Get(user.Id)
+	if err == repositories.ErrNotFound || (err == nil && !secret.Confirmed) {
+		return models.RoleViewer, nil
+	}
+
+	return user.Role, err
+}
+
+func (s *TokenService) issue(user models.UserDb, familyId string) (models.TokenPair, error) {
+	if user.Disabled {
+		return models.TokenPair{}, ErrUserDisabled
+	}
+
+	accessToken, err := s.AccessToken(user)
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	refreshToken, err := randomToken()
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	now := s.now()
+	err = s.RefreshTokens.Create(&models.RefreshToken{
+		UserId:    user.Id,
+		FamilyId:  familyId,
+		TokenHash: HashToken(refreshToken),
+		ExpiresAt: now.Add(s.RefreshLifetime).Unix(),
+		CreatedAt: now.Unix(),
+	})
+
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	return models.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(s.AccessLifetime / time.Second),
+	}, nil
+}
+
+// Returns the hex encoded SHA-256 hash of a token, which is stored instead of
+// the token itself.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Generates 32 random bytes encoded as URL safe base64.
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
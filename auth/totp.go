@@ -0,0 +1,316 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"image/png"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/kluddizz/maintenance-rest-service/models"
+	"github.com/kluddizz/maintenance-rest-service/repositories"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+type (
+	// Enrolls users for TOTP codes as second factor and completes logins of
+	// enrolled users. After the password was checked, the login returns a
+	// short-lived challenge token, which is exchanged for tokens together
+	// with a valid code.
+	TotpService struct {
+		Totp repositories.TotpRepository
+
+		// Optional log of enrollments and used recovery codes.
+		Audit repositories.AuditRepository
+
+		Signer   Signer
+		Verifier Verifier
+
+		// Name of the service shown in authenticator apps.
+		Issuer            string
+		ChallengeLifetime time.Duration
+		RecoveryCodeCount int
+
+		now func() time.Time
+	}
+)
+
+// Audience of challenge tokens, which are not accepted as access tokens.
+const ChallengeAudience = "login-challenge"
+
+// Codes are generated like most authenticator apps expect them by default.
+const totpPeriod = 30
+
+var totpOpts = totp.ValidateOpts{
+	Period:    totpPeriod,
+	Digits:    otp.DigitsSix,
+	Algorithm: otp.AlgorithmSHA1,
+}
+
+var (
+	ErrTotpEnabled      = errors.New("TOTP is already enabled")
+	ErrTotpNotEnrolled  = errors.New("TOTP has not been enrolled")
+	ErrInvalidCode      = errors.New("invalid or already used code")
+	ErrInvalidChallenge = errors.New("invalid or expired login challenge")
+)
+
+// Creates a new TOTP service signing challenge tokens with the given keys.
+func NewTotpService(secrets repositories.TotpRepository, keys *KeyManager) *TotpService {
+	return &TotpService{
+		Totp:              secrets,
+		Signer:            keys,
+		Verifier:          keys,
+		Issuer:            "Maintenance Rest Service",
+		ChallengeLifetime: 5 * time.Minute,
+		RecoveryCodeCount: 10,
+		now:               time.Now,
+	}
+}
+
+// Returns whether the user confirmed a secret, so logins require a code.
+func (s *TotpService) Enabled(userId int) (bool, error) {
+	secret, err := s.Totp.Get(userId)
+	if err == repositories.ErrNotFound {
+		return false, nil
+	}
+
+	return secret.Confirmed, err
+}
+
+// Generates a new secret for the user, which replaces any unconfirmed one.
+func (s *TotpService) Enroll(user models.UserDb) (models.TotpEnrollment, error) {
+	enabled, err := s.Enabled(user.Id)
+	if err != nil {
+		return models.TotpEnrollment{}, err
+	} else if enabled {
+		return models.TotpEnrollment{}, ErrTotpEnabled
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      s.Issuer,
+		AccountName: user.UserName,
+		Period:      totpOpts.Period,
+		Digits:      totpOpts.Digits,
+		Algorithm:   totpOpts.Algorithm,
+	})
+
+	if err != nil {
+		return models.TotpEnrollment{}, err
+	}
+
+	err = s.Totp.Save(models.Totp{
+		UserId:    user.Id,
+		Secret:    key.Secret(),
+		CreatedAt: s.now().Unix(),
+	})
+
+	if err != nil {
+		return models.TotpEnrollment{}, err
+	}
+
+	image, err := key.Image(200, 200)
+	if err != nil {
+		return models.TotpEnrollment{}, err
+	}
+
+	var qrCode bytes.Buffer
+	if err := png.Encode(&qrCode, image); err != nil {
+		return models.TotpEnrollment{}, err
+	}
+
+	return models.TotpEnrollment{
+		Secret: key.Secret(),
+		Uri:    key.URL(),
+		QrCode: "data:image/png;base64," + base64.StdEncoding.EncodeToString(qrCode.Bytes()),
+	}, nil
+}
+
+// Enables the enrolled secret once the user proved to have it by a valid
+// code and returns new recovery codes.
+func (s *TotpService) Confirm(user models.UserDb, code string) (models.RecoveryCodes, error) {
+	secret, err := s.Totp.Get(user.Id)
+	if err == repositories.ErrNotFound {
+		return models.RecoveryCodes{}, ErrTotpNotEnrolled
+	} else if err != nil {
+		return models.RecoveryCodes{}, err
+	}
+
+	if secret.Confirmed {
+		return models.RecoveryCodes{}, ErrTotpEnabled
+	}
+
+	if err := s.verify(secret, code); err != nil {
+		return models.RecoveryCodes{}, err
+	}
+
+	if err := s.Totp.Confirm(user.Id); err != nil {
+		return models.RecoveryCodes{}, err
+	}
+
+	if err := s.record(models.AuditTotpEnabled, user); err != nil {
+		return models.RecoveryCodes{}, err
+	}
+
+	return s.RenewRecoveryCodes(user)
+}
+
+// Removes the secret and the recovery codes of the user.
+func (s *TotpService) Disable(user models.UserDb) error {
+	err := s.Totp.Delete(user.Id)
+	if err == repositories.ErrNotFound {
+		return ErrTotpNotEnrolled
+	} else if err != nil {
+		return err
+	}
+
+	return s.record(models.AuditTotpDisabled, user)
+}
+
+// Replaces the recovery codes of the user by new ones.
+func (s *TotpService) RenewRecoveryCodes(user models.UserDb) (models.RecoveryCodes, error) {
+	codes := make([]string, s.RecoveryCodeCount)
+	hashes := make([]string, s.RecoveryCodeCount)
+
+	for i := range codes {
+		buf := make([]byte, 5)
+		if _, err := rand.Read(buf); err != nil {
+			return models.RecoveryCodes{}, err
+		}
+
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(buf))
+		codes[i] = code[:4] + "-" + code[4:]
+		hashes[i] = HashToken(code)
+	}
+
+	if err := s.Totp.ReplaceRecoveryCodes(user.Id, hashes); err != nil {
+		return models.RecoveryCodes{}, err
+	}
+
+	if err := s.record(models.AuditRecoveryCodesRenewed, user); err != nil {
+		return models.RecoveryCodes{}, err
+	}
+
+	return models.RecoveryCodes{RecoveryCodes: codes}, nil
+}
+
+// Checks a TOTP code of a user with enabled TOTP. Every code is accepted
+// once, including those of the previous and the next time step to tolerate
+// clock drift.
+func (s *TotpService) Verify(userId int, code string) error {
+	secret, err := s.Totp.Get(userId)
+	if err == repositories.ErrNotFound || (err == nil && !secret.Confirmed) {
+		return ErrInvalidCode
+	} else if err != nil {
+		return err
+	}
+
+	return s.verify(secret, code)
+}
+
+// Checks a recovery code of the user and marks it as used.
+func (s *TotpService) VerifyRecoveryCode(user models.UserDb, code string) error {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+
+	err := s.Totp.UseRecoveryCode(user.Id, HashToken(code), s.now().Unix())
+	if err == repositories.ErrNotFound {
+		return ErrInvalidCode
+	} else if err != nil {
+		return err
+	}
+
+	return s.record(models.AuditRecoveryCodeUsed, user)
+}
+
+// Signs a challenge token, which proves that the user passed the first
+// factor of the login.
+func (s *TotpService) Challenge(user models.UserDb) (models.LoginChallenge, error) {
+	tokenId, err := randomToken()
+	if err != nil {
+		return models.LoginChallenge{}, err
+	}
+
+	now := s.now()
+	token, err := s.Signer.Sign(models.CustomClaims{
+		UserName: user.UserName,
+		UserId:   user.Id,
+		StandardClaims: jwt.StandardClaims{
+			Id:        tokenId,
+			Audience:  ChallengeAudience,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(s.ChallengeLifetime).Unix(),
+			Issuer:    Issuer,
+		},
+	})
+
+	if err != nil {
+		return models.LoginChallenge{}, err
+	}
+
+	return models.LoginChallenge{
+		MfaRequired:    true,
+		ChallengeToken: token,
+		ExpiresIn:      int64(s.ChallengeLifetime / time.Second),
+	}, nil
+}
+
+// Returns the claims of a valid challenge token.
+func (s *TotpService) VerifyChallenge(token string) (*models.CustomClaims, error) {
+	claims := &models.CustomClaims{}
+
+	if err := s.Verifier.Verify(token, claims); err != nil {
+		return nil, ErrInvalidChallenge
+	}
+
+	if claims.Audience != ChallengeAudience || claims.Issuer != Issuer || claims.UserId == 0 {
+		return nil, ErrInvalidChallenge
+	}
+
+	return claims, nil
+}
+
+// Accepts codes of the current, the previous and the next time step, which
+// are later than the last used one.
+func (s *TotpService) verify(secret models.Totp, code string) error {
+	step := s.now().Unix() / totpPeriod
+
+	for _, candidate := range []int64{step, step - 1, step + 1} {
+		if candidate <= secret.LastUsedStep {
+			continue
+		}
+
+		expected, err := totp.GenerateCodeCustom(secret.Secret, time.Unix(candidate*totpPeriod, 0), totpOpts)
+		if err != nil {
+			return err
+		}
+
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			err := s.Totp.UseStep(secret.UserId, candidate)
+			if err == repositories.ErrNotFound {
+				return ErrInvalidCode
+			}
+
+			return err
+		}
+	}
+
+	return ErrInvalidCode
+}
+
+func (s *TotpService) record(event string, user models.UserDb) error {
+	if s.Audit == nil {
+		return nil
+	}
+
+	return s.Audit.Record(&models.AuditEvent{
+		Event:     event,
+		Subject:   userKey(user.UserName),
+		Actor:     user.UserName,
+		CreatedAt: s.now().Unix(),
+	})
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+	"github.com/kluddizz/maintenance-rest-service/repositories"
+	"github.com/pquerna/otp/totp"
+)
+
+// Test if roles requiring TOTP are limited to viewer until TOTP is enabled.
+func TestTotpRequiredRole(t *testing.T) {
+	now := time.Now()
+	secrets := repositories.NewMemoryTotpRepository()
+	user := models.UserDb{Id: 1, UserName: "operator", Role: models.RoleOperator}
+
+	service := &TotpService{Totp: secrets, Issuer: "test", RecoveryCodeCount: 1, now: func() time.Time { return now }}
+	tokens := &TokenService{Totp: secrets, TotpRole: models.RoleOperator}
+
+	if role, _ := tokens.Role(user); role != models.RoleViewer {
+		t.Errorf("Expected role to be %s but received %s", models.RoleViewer, role)
+	}
+
+	enrollment, err := service.Enroll(user)
+	if err != nil {
+		t.Fatalf("Could not enroll TOTP: %s", err.Error())
+	}
+
+	code, _ := totp.GenerateCode(enrollment.Secret, now)
+	if _, err := service.Confirm(user, code); err != nil {
+		t.Fatalf("Could not confirm TOTP: %s", err.Error())
+	}
+
+	if role, _ := tokens.Role(user); role != models.RoleOperator {
+		t.Errorf("Expected role to be %s but received %s", models.RoleOperator, role)
+	}
+
+	// Codes of earlier time steps are rejected once a later one was used.
+	previous, _ := totp.GenerateCode(enrollment.Secret, now.Add(-30*time.Second))
+	if err := service.Verify(user.Id, previous); err != ErrInvalidCode {
+		t.Errorf("Expected error to be %v but received %v", ErrInvalidCode, err)
+	}
+}
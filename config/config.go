@@ -0,0 +1,853 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+	"gopkg.in/yaml.v3"
+)
+
+// Prefix of all environment variables overriding the configuration.
+const EnvPrefix = "MRS_"
+
+// Modes of verifying client certificates.
+const (
+	ClientAuthNone     = "none"
+	ClientAuthOptional = "optional"
+	ClientAuthRequire  = "require"
+)
+
+type (
+	// The complete configuration of the service.
+	Config struct {
+		Server   ServerConfig   `json:"server" yaml:"server"`
+		Database DatabaseConfig `json:"database" yaml:"database"`
+		Auth     AuthConfig     `json:"auth" yaml:"auth"`
+		Mail     MailConfig     `json:"mail" yaml:"mail"`
+		Log      LogConfig      `json:"log" yaml:"log"`
+	}
+
+	ServerConfig struct {
+		Address           string    `json:"address" yaml:"address"`
+		ReadTimeout       Duration  `json:"readTimeout" yaml:"readTimeout"`
+		ReadHeaderTimeout Duration  `json:"readHeaderTimeout" yaml:"readHeaderTimeout"`
+		WriteTimeout      Duration  `json:"writeTimeout" yaml:"writeTimeout"`
+		IdleTimeout       Duration  `json:"idleTimeout" yaml:"idleTimeout"`
+		ShutdownTimeout   Duration  `json:"shutdownTimeout" yaml:"shutdownTimeout"`
+		TLS               TLSConfig `json:"tls" yaml:"tls"`
+	}
+
+	// Enables TLS if a certificate is configured. Client certificates are
+	// verified against the CA bundle depending on the client auth mode.
+	TLSConfig struct {
+		CertFile       string   `json:"certFile" yaml:"certFile"`
+		KeyFile        string   `json:"keyFile" yaml:"keyFile"`
+		ClientCAFile   string   `json:"clientCAFile" yaml:"clientCAFile"`
+		ClientAuth     string   `json:"clientAuth" yaml:"clientAuth"`
+		ReloadInterval Duration `json:"reloadInterval" yaml:"reloadInterval"`
+	}
+
+	AuthConfig struct {
+		Algorithm     string   `json:"algorithm" yaml:"algorithm"`
+		PrivateKey    string   `json:"privateKey" yaml:"privateKey"`
+		PublicKey     string   `json:"publicKey" yaml:"publicKey"`
+		TokenLifetime Duration `json:"tokenLifetime" yaml:"tokenLifetime"`
+
+		// Lifetime of refresh tokens, which are replaced on every use.
+		RefreshTokenLifetime Duration `json:"refreshTokenLifetime" yaml:"refreshTokenLifetime"`
+
+		// Interval in which revoked tokens are reloaded from the database.
+		RevocationRefresh Duration `json:"revocationRefresh" yaml:"revocationRefresh"`
+
+		// Additional keys for rotation. Once a newer key becomes active, older
+		// keys keep verifying tokens for the rotation overlap.
+		Keys            []KeyConfig `json:"keys" yaml:"keys"`
+		RotationOverlap Duration    `json:"rotationOverlap" yaml:"rotationOverlap"`
+
+		// Accept the subject of a verified client certificate as username.
+		ClientCertIdentity bool `json:"clientCertIdentity" yaml:"clientCertIdentity"`
+
+		// Role of client certificate identities.
+		ClientCertRole string `json:"clientCertRole" yaml:"clientCertRole"`
+
+		// Role of newly registered users.
+		DefaultRole string `json:"defaultRole" yaml:"defaultRole"`
+
+		// Lifetimes of tokens sent by mail.
+		EmailVerificationLifetime Duration `json:"emailVerificationLifetime" yaml:"emailVerificationLifetime"`
+		PasswordResetLifetime     Duration `json:"passwordResetLifetime" yaml:"passwordResetLifetime"`
+
+		// Authenticators asked in order at login, "local" for the users
+		// table and "ldap" for the directory.
+		Authenticators []string `json:"authenticators" yaml:"authenticators"`
+
+		// Ask the next authenticator if one fails, e.g. if the directory is
+		// not available.
+		AuthenticatorFallback bool `json:"authenticatorFallback" yaml:"authenticatorFallback"`
+
+		PasswordPolicy PasswordPolicyConfig `json:"passwordPolicy" yaml:"passwordPolicy"`
+		UsernamePolicy UsernamePolicyConfig `json:"usernamePolicy" yaml:"usernamePolicy"`
+
+		Throttle ThrottleConfig `json:"throttle" yaml:"throttle"`
+		Totp     TotpConfig     `json:"totp" yaml:"totp"`
+		Oidc     OidcConfig     `json:"oidc" yaml:"oidc"`
+		Ldap     LdapConfig     `json:"ldap" yaml:"ldap"`
+	}
+
+	// TOTP as second factor of logins.
+	TotpConfig struct {
+		// Name of the service shown in authenticator apps.
+		Issuer string `json:"issuer" yaml:"issuer"`
+
+		// Users of this or a more privileged role act as viewers until they
+		// enabled TOTP. Empty to make TOTP optional for everyone.
+		RequiredRole string `json:"requiredRole" yaml:"requiredRole"`
+
+		// Time to complete a login with a code after the password was checked.
+		ChallengeLifetime Duration `json:"challengeLifetime" yaml:"challengeLifetime"`
+	}
+
+	// Slows down password guessing. Every failed attempt doubles the delay
+	// before the next attempt for the same username or client address, and
+	// too many failures lock them out. Failures are forgotten after the
+	// lockout duration. A threshold of 0 disables the lockout.
+	ThrottleConfig struct {
+		BaseDelay          Duration `json:"baseDelay" yaml:"baseDelay"`
+		MaxDelay           Duration `json:"maxDelay" yaml:"maxDelay"`
+		LockoutThreshold   int      `json:"lockoutThreshold" yaml:"lockoutThreshold"`
+		IpLockoutThreshold int      `json:"ipLockoutThreshold" yaml:"ipLockoutThreshold"`
+		LockoutDuration    Duration `json:"lockoutDuration" yaml:"lockoutDuration"`
+	}
+
+	// A signing key, used from ActiveFrom on. The algorithm defaults to the
+	// one of the auth section and the id to the key's thumbprint.
+	KeyConfig struct {
+		Id         string    `json:"id" yaml:"id"`
+		Algorithm  string    `json:"algorithm" yaml:"algorithm"`
+		PrivateKey string    `json:"privateKey" yaml:"privateKey"`
+		PublicKey  string    `json:"publicKey" yaml:"publicKey"`
+		ActiveFrom time.Time `json:"activeFrom" yaml:"activeFrom"`
+	}
+
+	LogConfig struct {
+		Level  string `json:"level" yaml:"level"`
+		Format string `json:"format" yaml:"format"`
+	}
+
+	// A time.Duration written as string like "30m" in configuration files.
+	Duration time.Duration
+
+	// A single setting which can be overridden by environment and flags.
+	setting struct {
+		name  string
+		usage string
+		set   func(cfg *Config, value string) error
+	}
+)
+
+// Returns the configuration used when nothing else is specified.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Address:           "localhost:3000",
+			ReadTimeout:       Duration(15 * time.Second),
+			ReadHeaderTimeout: Duration(5 * time.Second),
+			WriteTimeout:      Duration(30 * time.Second),
+			IdleTimeout:       Duration(2 * time.Minute),
+			ShutdownTimeout:   Duration(20 * time.Second),
+			TLS: TLSConfig{
+				ClientAuth:     ClientAuthNone,
+				ReloadInterval: Duration(time.Minute),
+			},
+		},
+		Database: DatabaseConfig{
+			Driver: DriverMySQL,
+			Host:   "127.0.0.1",
+			Port:   3306,
+		},
+		Auth: AuthConfig{
+			Algorithm:            "RS256",
+			PrivateKey:           "./private.key",
+			PublicKey:            "./public.key",
+			TokenLifetime:        Duration(30 * time.Minute),
+			RefreshTokenLifetime: Duration(30 * 24 * time.Hour),
+			RevocationRefresh:    Duration(30 * time.Second),
+			RotationOverlap:      Duration(30 * time.Minute),
+			ClientCertRole:       string(models.RoleViewer),
+			DefaultRole:          string(models.RoleViewer),
+			Throttle: ThrottleConfig{
+				BaseDelay:          Duration(time.Second),
+				MaxDelay:           Duration(time.Minute),
+				LockoutThreshold:   10,
+				IpLockoutThreshold: 50,
+				LockoutDuration:    Duration(15 * time.Minute),
+			},
+			Totp: TotpConfig{
+				Issuer:            "Maintenance Rest Service",
+				ChallengeLifetime: Duration(5 * time.Minute),
+			},
+			Authenticators: []string{AuthenticatorLocal},
+			Ldap: LdapConfig{
+				UserFilter:        "(&(objectClass=person)(uid=%s))",
+				UsernameAttribute: "uid",
+				GroupAttribute:    "memberOf",
+				DefaultRole:       string(models.RoleViewer),
+				Timeout:           Duration(10 * time.Second),
+			},
+			Oidc: OidcConfig{
+				Scopes:        []string{"openid", "profile", "email"},
+				UsernameClaim: "preferred_username",
+				GroupsClaim:   "groups",
+				DefaultRole:   string(models.RoleViewer),
+			},
+			EmailVerificationLifetime: Duration(24 * time.Hour),
+			PasswordResetLifetime:     Duration(time.Hour),
+			PasswordPolicy: PasswordPolicyConfig{
+				MinLength:        8,
+				MaxLength:        72,
+				BreachedMinCount: 1,
+			},
+			UsernamePolicy: UsernamePolicyConfig{
+				MinLength: 3,
+				MaxLength: 64,
+				Pattern:   `^[A-Za-z0-9][A-Za-z0-9._@-]*$`,
+			},
+		},
+		Mail: MailConfig{
+			Mailer: MailerNone,
+			From:   "maintenance@localhost",
+			Smtp: SmtpConfig{
+				Port: 587,
+			},
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "text",
+		},
+	}
+}
+
+// All settings, named after their position in the configuration file.
+var settings = []setting{
+	{"server.address", "address to listen on", func(c *Config, v string) error {
+		c.Server.Address = v
+		return nil
+	}},
+	{"server.readTimeout", "maximum duration for reading a request", func(c *Config, v string) error {
+		return c.Server.ReadTimeout.Set(v)
+	}},
+	{"server.readHeaderTimeout", "maximum duration for reading request headers", func(c *Config, v string) error {
+		return c.Server.ReadHeaderTimeout.Set(v)
+	}},
+	{"server.writeTimeout", "maximum duration for writing a response", func(c *Config, v string) error {
+		return c.Server.WriteTimeout.Set(v)
+	}},
+	{"server.idleTimeout", "maximum duration of idle keep-alive connections", func(c *Config, v string) error {
+		return c.Server.IdleTimeout.Set(v)
+	}},
+	{"server.shutdownTimeout", "maximum duration for draining connections on shutdown", func(c *Config, v string) error {
+		return c.Server.ShutdownTimeout.Set(v)
+	}},
+	{"server.tls.certFile", "path of the TLS certificate", func(c *Config, v string) error {
+		c.Server.TLS.CertFile = v
+		return nil
+	}},
+	{"server.tls.keyFile", "path of the TLS private key", func(c *Config, v string) error {
+		c.Server.TLS.KeyFile = v
+		return nil
+	}},
+	{"server.tls.clientCAFile", "path of the CA bundle verifying client certificates", func(c *Config, v string) error {
+		c.Server.TLS.ClientCAFile = v
+		return nil
+	}},
+	{"server.tls.clientAuth", "client certificate mode (none, optional or require)", func(c *Config, v string) error {
+		c.Server.TLS.ClientAuth = v
+		return nil
+	}},
+	{"server.tls.reloadInterval", "interval of checking the certificate files for changes", func(c *Config, v string) error {
+		return c.Server.TLS.ReloadInterval.Set(v)
+	}},
+	{"database.driver", "database driver (mysql, postgres or sqlite)", func(c *Config, v string) error {
+		c.Database.Driver = v
+		return nil
+	}},
+	{"database.database", "database name or SQLite file path", func(c *Config, v string) error {
+		c.Database.Database = v
+		return nil
+	}},
+	{"database.username", "database user", func(c *Config, v string) error {
+		c.Database.UserName = v
+		return nil
+	}},
+	{"database.password", "database password", func(c *Config, v string) error {
+		c.Database.Password = v
+		return nil
+	}},
+	{"database.host", "database host", func(c *Config, v string) error {
+		c.Database.Host = v
+		return nil
+	}},
+	{"database.port", "database port", func(c *Config, v string) (err error) {
+		c.Database.Port, err = strconv.Atoi(v)
+		return err
+	}},
+	{"database.sslmode", "PostgreSQL SSL mode", func(c *Config, v string) error {
+		c.Database.SSLMode = v
+		return nil
+	}},
+	{"auth.algorithm", "token signing algorithm (RS256 or ES256)", func(c *Config, v string) error {
+		c.Auth.Algorithm = v
+		return nil
+	}},
+	{"auth.privateKey", "path of the token signing key", func(c *Config, v string) error {
+		c.Auth.PrivateKey = v
+		return nil
+	}},
+	{"auth.publicKey", "path of the token verification key", func(c *Config, v string) error {
+		c.Auth.PublicKey = v
+		return nil
+	}},
+	{"auth.tokenLifetime", "lifetime of issued tokens, e.g. 30m", func(c *Config, v string) error {
+		return c.Auth.TokenLifetime.Set(v)
+	}},
+	{"auth.refreshTokenLifetime", "lifetime of refresh tokens, e.g. 720h", func(c *Config, v string) error {
+		return c.Auth.RefreshTokenLifetime.Set(v)
+	}},
+	{"auth.revocationRefresh", "interval in which revoked tokens are reloaded", func(c *Config, v string) error {
+		return c.Auth.RevocationRefresh.Set(v)
+	}},
+	{"auth.rotationOverlap", "duration retired keys keep verifying tokens", func(c *Config, v string) error {
+		return c.Auth.RotationOverlap.Set(v)
+	}},
+	{"auth.clientCertIdentity", "accept client certificate subjects as identity", func(c *Config, v string) (err error) {
+		c.Auth.ClientCertIdentity, err = strconv.ParseBool(v)
+		return err
+	}},
+	{"auth.clientCertRole", "role of client certificate identities", func(c *Config, v string) error {
+		c.Auth.ClientCertRole = v
+		return nil
+	}},
+	{"auth.defaultRole", "role of newly registered users", func(c *Config, v string) error {
+		c.Auth.DefaultRole = v
+		return nil
+	}},
+	{"auth.throttle.baseDelay", "delay after the first failed login", func(c *Config, v string) error {
+		return c.Auth.Throttle.BaseDelay.Set(v)
+	}},
+	{"auth.throttle.maxDelay", "maximal delay between failed logins", func(c *Config, v string) error {
+		return c.Auth.Throttle.MaxDelay.Set(v)
+	}},
+	{"auth.throttle.lockoutThreshold", "failed logins locking out a username", func(c *Config, v string) (err error) {
+		c.Auth.Throttle.LockoutThreshold, err = strconv.Atoi(v)
+		return err
+	}},
+	{"auth.throttle.ipLockoutThreshold", "failed logins locking out a client address", func(c *Config, v string) (err error) {
+		c.Auth.Throttle.IpLockoutThreshold, err = strconv.Atoi(v)
+		return err
+	}},
+	{"auth.throttle.lockoutDuration", "duration of lockouts", func(c *Config, v string) error {
+		return c.Auth.Throttle.LockoutDuration.Set(v)
+	}},
+	{"auth.totp.issuer", "service name shown in authenticator apps", func(c *Config, v string) error {
+		c.Auth.Totp.Issuer = v
+		return nil
+	}},
+	{"auth.totp.requiredRole", "role requiring TOTP to act beyond viewer", func(c *Config, v string) error {
+		c.Auth.Totp.RequiredRole = v
+		return nil
+	}},
+	{"auth.totp.challengeLifetime", "time to complete a login with a TOTP code", func(c *Config, v string) error {
+		return c.Auth.Totp.ChallengeLifetime.Set(v)
+	}},
+	{"auth.emailVerificationLifetime", "lifetime of email verification tokens", func(c *Config, v string) error {
+		return c.Auth.EmailVerificationLifetime.Set(v)
+	}},
+	{"auth.passwordResetLifetime", "lifetime of password reset tokens", func(c *Config, v string) error {
+		return c.Auth.PasswordResetLifetime.Set(v)
+	}},
+	{"auth.passwordPolicy.minLength", "minimal number of characters of passwords", func(c *Config, v string) (err error) {
+		c.Auth.PasswordPolicy.MinLength, err = strconv.Atoi(v)
+		return err
+	}},
+	{"auth.passwordPolicy.maxLength", "maximal number of bytes of passwords (at most 72)", func(c *Config, v string) (err error) {
+		c.Auth.PasswordPolicy.MaxLength, err = strconv.Atoi(v)
+		return err
+	}},
+	{"auth.passwordPolicy.characterClasses", "character classes required in passwords (0-4)", func(c *Config, v string) (err error) {
+		c.Auth.PasswordPolicy.CharacterClasses, err = strconv.Atoi(v)
+		return err
+	}},
+	{"auth.passwordPolicy.allowUsername", "allow passwords containing the username", func(c *Config, v string) (err error) {
+		c.Auth.PasswordPolicy.AllowUsername, err = strconv.ParseBool(v)
+		return err
+	}},
+	{"auth.passwordPolicy.breachedPasswords", "directory of Pwned Passwords range files", func(c *Config, v string) error {
+		c.Auth.PasswordPolicy.BreachedPasswords = v
+		return nil
+	}},
+	{"auth.passwordPolicy.breachedMinCount", "breaches after which a password is rejected", func(c *Config, v string) (err error) {
+		c.Auth.PasswordPolicy.BreachedMinCount, err = strconv.Atoi(v)
+		return err
+	}},
+	{"auth.usernamePolicy.minLength", "minimal number of characters of usernames", func(c *Config, v string) (err error) {
+		c.Auth.UsernamePolicy.MinLength, err = strconv.Atoi(v)
+		return err
+	}},
+	{"auth.usernamePolicy.maxLength", "maximal number of characters of usernames", func(c *Config, v string) (err error) {
+		c.Auth.UsernamePolicy.MaxLength, err = strconv.Atoi(v)
+		return err
+	}},
+	{"auth.usernamePolicy.pattern", "regular expression usernames have to match", func(c *Config, v string) error {
+		c.Auth.UsernamePolicy.Pattern = v
+		return nil
+	}},
+	{"auth.authenticators", "comma separated authenticators asked at login (local, ldap)", func(c *Config, v string) error {
+		c.Auth.Authenticators = strings.Split(v, ",")
+		return nil
+	}},
+	{"auth.authenticatorFallback", "ask the next authenticator if one fails", func(c *Config, v string) (err error) {
+		c.Auth.AuthenticatorFallback, err = strconv.ParseBool(v)
+		return err
+	}},
+	{"auth.ldap.url", "URL of the LDAP directory", func(c *Config, v string) error {
+		c.Auth.Ldap.Url = v
+		return nil
+	}},
+	{"auth.ldap.startTLS", "upgrade LDAP connections with StartTLS", func(c *Config, v string) (err error) {
+		c.Auth.Ldap.StartTLS, err = strconv.ParseBool(v)
+		return err
+	}},
+	{"auth.ldap.caFile", "CA bundle verifying the LDAP directory", func(c *Config, v string) error {
+		c.Auth.Ldap.CAFile = v
+		return nil
+	}},
+	{"auth.ldap.bindDn", "DN of the LDAP service account", func(c *Config, v string) error {
+		c.Auth.Ldap.BindDn = v
+		return nil
+	}},
+	{"auth.ldap.bindPassword", "password of the LDAP service account", func(c *Config, v string) error {
+		c.Auth.Ldap.BindPassword = v
+		return nil
+	}},
+	{"auth.ldap.baseDn", "DN below which users are searched", func(c *Config, v string) error {
+		c.Auth.Ldap.BaseDn = v
+		return nil
+	}},
+	{"auth.ldap.userFilter", "LDAP filter finding a user by name", func(c *Config, v string) error {
+		c.Auth.Ldap.UserFilter = v
+		return nil
+	}},
+	{"auth.ldap.usernameAttribute", "LDAP attribute with the username", func(c *Config, v string) error {
+		c.Auth.Ldap.UsernameAttribute = v
+		return nil
+	}},
+	{"auth.ldap.idAttribute", "LDAP attribute with a stable user id", func(c *Config, v string) error {
+		c.Auth.Ldap.IdAttribute = v
+		return nil
+	}},
+	{"auth.ldap.groupAttribute", "LDAP attribute with the groups of a user", func(c *Config, v string) error {
+		c.Auth.Ldap.GroupAttribute = v
+		return nil
+	}},
+	{"auth.ldap.defaultRole", "role of directory users without mapped group", func(c *Config, v string) error {
+		c.Auth.Ldap.DefaultRole = v
+		return nil
+	}},
+	{"auth.ldap.timeout", "timeout of LDAP requests", func(c *Config, v string) error {
+		return c.Auth.Ldap.Timeout.Set(v)
+	}},
+	{"auth.oidc.issuer", "issuer URL of the OpenID Connect provider", func(c *Config, v string) error {
+		c.Auth.Oidc.Issuer = v
+		return nil
+	}},
+	{"auth.oidc.clientId", "client id at the OpenID Connect provider", func(c *Config, v string) error {
+		c.Auth.Oidc.ClientId = v
+		return nil
+	}},
+	{"auth.oidc.clientSecret", "client secret at the OpenID Connect provider", func(c *Config, v string) error {
+		c.Auth.Oidc.ClientSecret = v
+		return nil
+	}},
+	{"auth.oidc.redirectUrl", "URL of the OpenID Connect callback", func(c *Config, v string) error {
+		c.Auth.Oidc.RedirectUrl = v
+		return nil
+	}},
+	{"auth.oidc.scopes", "comma separated scopes requested from the provider", func(c *Config, v string) error {
+		c.Auth.Oidc.Scopes = strings.Split(v, ",")
+		return nil
+	}},
+	{"auth.oidc.usernameClaim", "ID token claim with the username", func(c *Config, v string) error {
+		c.Auth.Oidc.UsernameClaim = v
+		return nil
+	}},
+	{"auth.oidc.groupsClaim", "ID token claim with the groups", func(c *Config, v string) error {
+		c.Auth.Oidc.GroupsClaim = v
+		return nil
+	}},
+	{"auth.oidc.defaultRole", "role of provider users without mapped group", func(c *Config, v string) error {
+		c.Auth.Oidc.DefaultRole = v
+		return nil
+	}},
+	{"mail.mailer", "mailer (none, log, file or smtp)", func(c *Config, v string) error {
+		c.Mail.Mailer = v
+		return nil
+	}},
+	{"mail.from", "sender address of mails", func(c *Config, v string) error {
+		c.Mail.From = v
+		return nil
+	}},
+	{"mail.file", "file the file mailer appends mails to", func(c *Config, v string) error {
+		c.Mail.File = v
+		return nil
+	}},
+	{"mail.linkUrl", "URL of the frontend linked in mails", func(c *Config, v string) error {
+		c.Mail.LinkUrl = v
+		return nil
+	}},
+	{"mail.smtp.host", "SMTP server host", func(c *Config, v string) error {
+		c.Mail.Smtp.Host = v
+		return nil
+	}},
+	{"mail.smtp.port", "SMTP server port", func(c *Config, v string) (err error) {
+		c.Mail.Smtp.Port, err = strconv.Atoi(v)
+		return err
+	}},
+	{"mail.smtp.username", "SMTP username", func(c *Config, v string) error {
+		c.Mail.Smtp.Username = v
+		return nil
+	}},
+	{"mail.smtp.password", "SMTP password", func(c *Config, v string) error {
+		c.Mail.Smtp.Password = v
+		return nil
+	}},
+	{"log.level", "log level (debug, info, warn or error)", func(c *Config, v string) error {
+		c.Log.Level = v
+		return nil
+	}},
+	{"log.format", "log format (text or json)", func(c *Config, v string) error {
+		c.Log.Format = v
+		return nil
+	}},
+}
+
+// Loads the configuration from defaults, the configuration file, environment
+// variables and command line flags, where later sources override earlier
+// ones. The file is given by -config or MRS_CONFIG and may be JSON or YAML.
+// The arguments left after parsing the flags are returned as well.
+func Load(args []string) (*Config, []string, error) {
+	flags := flag.NewFlagSet("maintenance-rest-service", flag.ContinueOnError)
+	path := flags.String("config", os.Getenv(EnvPrefix+"CONFIG"), "path of the configuration file")
+
+	values := make(map[string]*string)
+	for _, s := range settings {
+		values[s.name] = flags.String(s.name, "", fmt.Sprintf("%s (env %s)", s.usage, envName(s.name)))
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	cfg := Default()
+	if err := cfg.readFile(*path); err != nil {
+		return nil, nil, err
+	}
+
+	// Environment variables override the file.
+	for _, s := range settings {
+		if value, ok := os.LookupEnv(envName(s.name)); ok {
+			if err := s.set(cfg, value); err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", envName(s.name), err)
+			}
+		}
+	}
+
+	// Flags override everything.
+	var err error
+	flags.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.name == f.Name && err == nil {
+				if setErr := s.set(cfg, *values[s.name]); setErr != nil {
+					err = fmt.Errorf("-%s: %w", s.name, setErr)
+				}
+			}
+		}
+	})
+
+	return cfg, flags.Args(), err
+}
+
+// Reads the configuration file. Without an explicit path, the legacy
+// ./database.json is read into the database section if it exists.
+func (cfg *Config) readFile(path string) error {
+	if path == "" {
+		content, err := os.ReadFile("./database.json")
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		if err == nil {
+			err = json.Unmarshal(content, &cfg.Database)
+		}
+
+		if err != nil {
+			return fmt.Errorf("database.json: %w", err)
+		}
+
+		return nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, cfg)
+	default:
+		err = json.Unmarshal(content, cfg)
+	}
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Checks the whole configuration and reports every invalid setting.
+func (cfg *Config) Validate() error {
+	var errs []error
+
+	if cfg.Server.Address == "" {
+		errs = append(errs, errors.New("server.address is required"))
+	}
+
+	if cfg.Server.ReadTimeout < 0 || cfg.Server.ReadHeaderTimeout < 0 ||
+		cfg.Server.WriteTimeout < 0 || cfg.Server.IdleTimeout < 0 {
+		errs = append(errs, errors.New("server timeouts must not be negative"))
+	}
+
+	if cfg.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server.shutdownTimeout must be positive"))
+	}
+
+	if err := cfg.Server.TLS.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if cfg.Auth.ClientCertIdentity && cfg.Server.TLS.ClientAuth == ClientAuthNone {
+		errs = append(errs, errors.New("auth.clientCertIdentity requires server.tls.clientAuth"))
+	}
+
+	if err := cfg.Auth.Throttle.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if !models.Role(cfg.Auth.ClientCertRole).Valid() {
+		errs = append(errs, fmt.Errorf("auth.clientCertRole `%s` is unknown", cfg.Auth.ClientCertRole))
+	}
+
+	if !models.Role(cfg.Auth.DefaultRole).Valid() {
+		errs = append(errs, fmt.Errorf("auth.defaultRole `%s` is unknown", cfg.Auth.DefaultRole))
+	}
+
+	if cfg.Auth.Totp.RequiredRole != "" && !models.Role(cfg.Auth.Totp.RequiredRole).Valid() {
+		errs = append(errs, fmt.Errorf("auth.totp.requiredRole `%s` is unknown", cfg.Auth.Totp.RequiredRole))
+	}
+
+	if cfg.Auth.Totp.Issuer == "" {
+		errs = append(errs, errors.New("auth.totp.issuer must not be empty"))
+	}
+
+	if cfg.Auth.Totp.ChallengeLifetime <= 0 {
+		errs = append(errs, errors.New("auth.totp.challengeLifetime must be positive"))
+	}
+
+	if cfg.Auth.EmailVerificationLifetime <= 0 || cfg.Auth.PasswordResetLifetime <= 0 {
+		errs = append(errs, errors.New("lifetimes of tokens sent by mail must be positive"))
+	}
+
+	if err := cfg.Auth.PasswordPolicy.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := cfg.Auth.UsernamePolicy.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := cfg.Auth.ValidateAuthenticators(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := cfg.Auth.Oidc.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := cfg.Database.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := cfg.Mail.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	for i, key := range cfg.Auth.AllKeys() {
+		name := "auth"
+		if i > 0 {
+			name = fmt.Sprintf("auth.keys[%d]", i-1)
+		}
+
+		if key.Algorithm != "RS256" && key.Algorithm != "ES256" {
+			errs = append(errs, fmt.Errorf("%s.algorithm `%s` is unsupported", name, key.Algorithm))
+		}
+
+		if key.PrivateKey == "" && key.PublicKey == "" {
+			errs = append(errs, fmt.Errorf("%s.privateKey is required", name))
+		}
+
+		// Without activation time, the key would replace the key of the auth
+		// section from the start.
+		if i > 0 && key.ActiveFrom.IsZero() {
+			errs = append(errs, fmt.Errorf("%s.activeFrom is required", name))
+		}
+
+		for _, path := range []string{key.PrivateKey, key.PublicKey} {
+			if _, err := os.Stat(path); path != "" && err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+		}
+	}
+
+	if cfg.Auth.RotationOverlap < 0 {
+		errs = append(errs, errors.New("auth.rotationOverlap must not be negative"))
+	}
+
+	if cfg.Auth.TokenLifetime <= 0 {
+		errs = append(errs, errors.New("auth.tokenLifetime must be positive"))
+	}
+
+	if cfg.Auth.RefreshTokenLifetime <= 0 {
+		errs = append(errs, errors.New("auth.refreshTokenLifetime must be positive"))
+	}
+
+	if cfg.Auth.RevocationRefresh < 0 {
+		errs = append(errs, errors.New("auth.revocationRefresh must not be negative"))
+	}
+
+	switch cfg.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("log.level `%s` is unknown", cfg.Log.Level))
+	}
+
+	switch cfg.Log.Format {
+	case "text", "json":
+	default:
+		errs = append(errs, fmt.Errorf("log.format `%s` is unknown", cfg.Log.Format))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Returns the key of the auth section followed by the additional keys, with
+// their algorithms defaulting to the one of the auth section.
+func (authConfig *AuthConfig) AllKeys() []KeyConfig {
+	keys := []KeyConfig{{
+		Algorithm:  authConfig.Algorithm,
+		PrivateKey: authConfig.PrivateKey,
+		PublicKey:  authConfig.PublicKey,
+	}}
+
+	for _, key := range authConfig.Keys {
+		if key.Algorithm == "" {
+			key.Algorithm = authConfig.Algorithm
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// Returns true if TLS is enabled by a certificate.
+func (tlsConfig *TLSConfig) Enabled() bool {
+	return tlsConfig.CertFile != ""
+}
+
+// Checks whether the TLS settings are complete.
+func (tlsConfig *TLSConfig) Validate() error {
+	if (tlsConfig.CertFile == "") != (tlsConfig.KeyFile == "") {
+		return errors.New("server.tls.certFile and server.tls.keyFile must be set together")
+	}
+
+	switch tlsConfig.ClientAuth {
+	case ClientAuthNone:
+	case ClientAuthOptional, ClientAuthRequire:
+		if !tlsConfig.Enabled() || tlsConfig.ClientCAFile == "" {
+			return errors.New("server.tls.clientAuth requires a certificate and server.tls.clientCAFile")
+		}
+	default:
+		return fmt.Errorf("server.tls.clientAuth `%s` is unknown", tlsConfig.ClientAuth)
+	}
+
+	if tlsConfig.Enabled() && tlsConfig.ReloadInterval <= 0 {
+		return errors.New("server.tls.reloadInterval must be positive")
+	}
+
+	return nil
+}
+
+// Returns the environment variable of a setting, e.g. MRS_SERVER_ADDRESS.
+func envName(name string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(name, ".", "_"))
+}
+
+func (d *Duration) Set(value string) error {
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(duration)
+	return nil
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	return d.Set(string(text))
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// Checks the throttling settings.
+func (throttle *ThrottleConfig) Validate() error {
+	var errs []error
+
+	if throttle.BaseDelay < 0 || throttle.MaxDelay < throttle.BaseDelay {
+		errs = append(errs, errors.New("auth.throttle.maxDelay must not be less than auth.throttle.baseDelay"))
+	}
+
+	if throttle.LockoutThreshold < 0 || throttle.IpLockoutThreshold < 0 {
+		errs = append(errs, errors.New("auth.throttle thresholds must not be negative"))
+	}
+
+	if throttle.LockoutDuration <= 0 {
+		errs = append(errs, errors.New("auth.throttle.lockoutDuration must be positive"))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test if flags override environment variables, which override the file.
+func TestLoadPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte(`
+server:
+  address: file:1
+database:
+  driver: sqlite
+  database: file.db
+auth:
+  tokenLifetime: 10m
+`), 0600)
+
+	t.Setenv("MRS_DATABASE_DATABASE", "env.db")
+	t.Setenv("MRS_SERVER_ADDRESS", "env:2")
+
+	cfg, args, err := Load([]string{"-config", path, "-server.address", "flag:3", "migrate", "up"})
+	if err != nil {
+		t.Fatalf("Could not load configuration: %s", err.Error())
+	}
+
+	if cfg.Server.Address != "flag:3" {
+		t.Errorf("Expected address to be %s but received %s", "flag:3", cfg.Server.Address)
+	}
+
+	if cfg.Database.Database != "env.db" || cfg.Database.Driver != DriverSQLite {
+		t.Errorf("Expected database to be %s (%s) but received %s (%s)", "env.db", DriverSQLite, cfg.Database.Database, cfg.Database.Driver)
+	}
+
+	if time.Duration(cfg.Auth.TokenLifetime) != 10*time.Minute {
+		t.Errorf("Expected token lifetime to be %s but received %s", 10*time.Minute, cfg.Auth.TokenLifetime)
+	}
+
+	// Untouched settings keep their defaults.
+	if cfg.Log.Level != "info" {
+		t.Errorf("Expected log level to be %s but received %s", "info", cfg.Log.Level)
+	}
+
+	if len(args) != 2 || args[0] != "migrate" {
+		t.Errorf("Expected remaining arguments to be %v but received %v", []string{"migrate", "up"}, args)
+	}
+}
+
+// Test if invalid settings are rejected.
+func TestValidate(t *testing.T) {
+	cfg := Default()
+	cfg.Database.Driver = "oracle"
+	cfg.Auth.PrivateKey = filepath.Join(t.TempDir(), "missing.key")
+	cfg.Log.Level = "verbose"
+	cfg.Auth.Keys = []KeyConfig{{Id: "next", PrivateKey: cfg.Auth.PrivateKey}}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatalf("Expected configuration to be invalid")
+	}
+
+	if !strings.Contains(err.Error(), "auth.keys[0].activeFrom is required") {
+		t.Errorf("Expected key without activation time to be rejected but received %s", err.Error())
+	}
+}
@@ -2,17 +2,28 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/url"
+	"strconv"
+)
+
+const (
+	DriverMySQL    = "mysql"
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
 )
 
 type (
 	DatabaseConfig struct {
-		Database string `json:"database"`
-		UserName string `json:"username"`
-		Password string `json:"password"`
-		Host     string `json:"host"`
-		Port     int    `json:"port"`
+		Driver   string `json:"driver" yaml:"driver"`
+		Database string `json:"database" yaml:"database"`
+		UserName string `json:"username" yaml:"username"`
+		Password string `json:"password" yaml:"password"`
+		Host     string `json:"host" yaml:"host"`
+		Port     int    `json:"port" yaml:"port"`
+		SSLMode  string `json:"sslmode" yaml:"sslmode"`
 	}
 )
 
@@ -32,7 +43,46 @@ func ReadDatabaseConfig(path string) (*DatabaseConfig, error) {
 	return &dbConfig, nil
 }
 
+// Returns the configured driver and falls back to MySQL if none is set.
+func (dbConfig *DatabaseConfig) DriverName() string {
+	if dbConfig.Driver == "" {
+		return DriverMySQL
+	}
+
+	return dbConfig.Driver
+}
+
+// Checks whether the settings are sufficient to connect to the database.
+func (dbConfig *DatabaseConfig) Validate() error {
+	switch dbConfig.DriverName() {
+	case DriverSQLite:
+		if dbConfig.Database == "" {
+			return errors.New("database.database must be the path of the SQLite file")
+		}
+	case DriverMySQL, DriverPostgres:
+		if dbConfig.Database == "" || dbConfig.Host == "" {
+			return errors.New("database.database and database.host are required")
+		}
+	default:
+		return fmt.Errorf("database.driver `%s` is unsupported", dbConfig.Driver)
+	}
+
+	return nil
+}
+
+// Returns the data source name for the configured driver. SQLite uses the
+// database field as the path of the database file.
 func (dbConfig *DatabaseConfig) DataSourceName() string {
+	switch dbConfig.DriverName() {
+	case DriverSQLite:
+		return fmt.Sprintf(
+			"file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)",
+			dbConfig.Database,
+		)
+	case DriverPostgres:
+		return dbConfig.postgresDataSourceName()
+	}
+
 	return fmt.Sprintf(
 		"%s:%s@tcp(%s:%d)/%s",
 		dbConfig.UserName,
@@ -42,3 +92,23 @@ func (dbConfig *DatabaseConfig) DataSourceName() string {
 		dbConfig.Database,
 	)
 }
+
+// Builds a PostgreSQL connection URL, escaping the credentials.
+func (dbConfig *DatabaseConfig) postgresDataSourceName() string {
+	dsn := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(dbConfig.UserName, dbConfig.Password),
+		Host:   dbConfig.Host,
+		Path:   "/" + dbConfig.Database,
+	}
+
+	if dbConfig.Port != 0 {
+		dsn.Host += ":" + strconv.Itoa(dbConfig.Port)
+	}
+
+	if dbConfig.SSLMode != "" {
+		dsn.RawQuery = url.Values{"sslmode": {dbConfig.SSLMode}}.Encode()
+	}
+
+	return dsn.String()
+}
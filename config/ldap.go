@@ -0,0 +1,108 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+const (
+	AuthenticatorLocal = "local"
+	AuthenticatorLdap  = "ldap"
+)
+
+type (
+	// Password checks against an LDAP directory such as Active Directory.
+	LdapConfig struct {
+		// ldap:// or ldaps:// URL of the directory.
+		Url      string `json:"url" yaml:"url"`
+		StartTLS bool   `json:"startTLS" yaml:"startTLS"`
+
+		// Optional CA bundle verifying the certificate of the directory.
+		CAFile string `json:"caFile" yaml:"caFile"`
+
+		// Service account searching users, anonymous if empty.
+		BindDn       string `json:"bindDn" yaml:"bindDn"`
+		BindPassword string `json:"bindPassword" yaml:"bindPassword"`
+
+		// Users are searched below the base DN with the filter, in which %s
+		// is replaced by the username.
+		BaseDn     string `json:"baseDn" yaml:"baseDn"`
+		UserFilter string `json:"userFilter" yaml:"userFilter"`
+
+		UsernameAttribute string `json:"usernameAttribute" yaml:"usernameAttribute"`
+		IdAttribute       string `json:"idAttribute" yaml:"idAttribute"`
+		GroupAttribute    string `json:"groupAttribute" yaml:"groupAttribute"`
+
+		// Roles of groups by DN or CN. Users without mapped group get the
+		// default role, or may not login if it is empty.
+		Roles       map[string]string `json:"roles" yaml:"roles"`
+		DefaultRole string            `json:"defaultRole" yaml:"defaultRole"`
+
+		Timeout Duration `json:"timeout" yaml:"timeout"`
+	}
+)
+
+// Checks whether the authenticators are known and configured.
+func (authConfig *AuthConfig) ValidateAuthenticators() error {
+	if len(authConfig.Authenticators) == 0 {
+		return errors.New("auth.authenticators must not be empty")
+	}
+
+	for _, name := range authConfig.Authenticators {
+		switch name {
+		case AuthenticatorLocal:
+		case AuthenticatorLdap:
+			if err := authConfig.Ldap.Validate(); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("auth.authenticators: `%s` is unsupported", name)
+		}
+	}
+
+	return nil
+}
+
+// Checks whether the settings are sufficient to search users.
+func (ldapConfig *LdapConfig) Validate() error {
+	directory, err := url.Parse(ldapConfig.Url)
+	if err != nil || (directory.Scheme != "ldap" && directory.Scheme != "ldaps") || directory.Host == "" {
+		return errors.New("auth.ldap.url must be an ldap:// or ldaps:// URL")
+	}
+
+	if ldapConfig.StartTLS && directory.Scheme == "ldaps" {
+		return errors.New("auth.ldap.startTLS requires an ldap:// URL")
+	}
+
+	if ldapConfig.BaseDn == "" {
+		return errors.New("auth.ldap.baseDn is required")
+	}
+
+	if strings.Count(ldapConfig.UserFilter, "%s") != 1 || strings.Count(ldapConfig.UserFilter, "%") != 1 {
+		return errors.New("auth.ldap.userFilter must contain %s once")
+	}
+
+	if ldapConfig.UsernameAttribute == "" || ldapConfig.GroupAttribute == "" {
+		return errors.New("auth.ldap.usernameAttribute and auth.ldap.groupAttribute must not be empty")
+	}
+
+	for group, role := range ldapConfig.Roles {
+		if !models.Role(role).Valid() {
+			return fmt.Errorf("auth.ldap.roles: role `%s` of group `%s` is unknown", role, group)
+		}
+	}
+
+	if ldapConfig.DefaultRole != "" && !models.Role(ldapConfig.DefaultRole).Valid() {
+		return fmt.Errorf("auth.ldap.defaultRole `%s` is unknown", ldapConfig.DefaultRole)
+	}
+
+	if ldapConfig.Timeout <= 0 {
+		return errors.New("auth.ldap.timeout must be positive")
+	}
+
+	return nil
+}
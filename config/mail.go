@@ -0,0 +1,65 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	MailerNone = "none"
+	MailerLog  = "log"
+	MailerFile = "file"
+	MailerSMTP = "smtp"
+)
+
+type (
+	// Delivery of verification and password reset mails. Without mailer,
+	// emails are not verified and passwords cannot be reset. The log and file
+	// mailers do not send anything and are meant for local development.
+	MailConfig struct {
+		Mailer string `json:"mailer" yaml:"mailer"`
+		From   string `json:"from" yaml:"from"`
+
+		// Path of the file mails are appended to by the file mailer.
+		File string `json:"file" yaml:"file"`
+
+		// Optional URL of a frontend, which mails link to.
+		LinkUrl string `json:"linkUrl" yaml:"linkUrl"`
+
+		Smtp SmtpConfig `json:"smtp" yaml:"smtp"`
+	}
+
+	SmtpConfig struct {
+		Host     string `json:"host" yaml:"host"`
+		Port     int    `json:"port" yaml:"port"`
+		Username string `json:"username" yaml:"username"`
+		Password string `json:"password" yaml:"password"`
+	}
+)
+
+// Checks whether the settings are sufficient to deliver mails.
+func (mailConfig *MailConfig) Validate() error {
+	if mailConfig.Mailer == MailerNone {
+		return nil
+	}
+
+	if mailConfig.From == "" {
+		return errors.New("mail.from is required")
+	}
+
+	switch mailConfig.Mailer {
+	case MailerLog:
+	case MailerFile:
+		if mailConfig.File == "" {
+			return errors.New("mail.file is required by the file mailer")
+		}
+	case MailerSMTP:
+		if mailConfig.Smtp.Host == "" || mailConfig.Smtp.Port <= 0 {
+			return errors.New("mail.smtp.host and mail.smtp.port are required by the SMTP mailer")
+		}
+	default:
+		return fmt.Errorf("mail.mailer `%s` is unsupported", mailConfig.Mailer)
+	}
+
+	return nil
+}
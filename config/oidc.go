@@ -0,0 +1,68 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+type (
+	// Login at an external OpenID Connect provider, enabled by its issuer.
+	OidcConfig struct {
+		Issuer       string `json:"issuer" yaml:"issuer"`
+		ClientId     string `json:"clientId" yaml:"clientId"`
+		ClientSecret string `json:"clientSecret" yaml:"clientSecret"`
+
+		// URL of the callback route, which has to be registered at the
+		// provider, e.g. https://maintenance.example.com/oidc/callback.
+		RedirectUrl string   `json:"redirectUrl" yaml:"redirectUrl"`
+		Scopes      []string `json:"scopes" yaml:"scopes"`
+
+		// Claims of the ID token with username and groups.
+		UsernameClaim string `json:"usernameClaim" yaml:"usernameClaim"`
+		GroupsClaim   string `json:"groupsClaim" yaml:"groupsClaim"`
+
+		// Roles of the groups at the provider. Users without mapped group
+		// get the default role, or may not login if it is empty.
+		Roles       map[string]string `json:"roles" yaml:"roles"`
+		DefaultRole string            `json:"defaultRole" yaml:"defaultRole"`
+	}
+)
+
+// Returns whether users may login at a provider.
+func (oidcConfig *OidcConfig) Enabled() bool {
+	return oidcConfig.Issuer != ""
+}
+
+// Checks whether the settings are sufficient to login at the provider.
+func (oidcConfig *OidcConfig) Validate() error {
+	if !oidcConfig.Enabled() {
+		return nil
+	}
+
+	if oidcConfig.ClientId == "" {
+		return errors.New("auth.oidc.clientId is required")
+	}
+
+	if redirect, err := url.Parse(oidcConfig.RedirectUrl); err != nil || !redirect.IsAbs() {
+		return errors.New("auth.oidc.redirectUrl must be an absolute URL")
+	}
+
+	if oidcConfig.UsernameClaim == "" || oidcConfig.GroupsClaim == "" {
+		return errors.New("auth.oidc.usernameClaim and auth.oidc.groupsClaim must not be empty")
+	}
+
+	for group, role := range oidcConfig.Roles {
+		if !models.Role(role).Valid() {
+			return fmt.Errorf("auth.oidc.roles: role `%s` of group `%s` is unknown", role, group)
+		}
+	}
+
+	if oidcConfig.DefaultRole != "" && !models.Role(oidcConfig.DefaultRole).Valid() {
+		return fmt.Errorf("auth.oidc.defaultRole `%s` is unknown", oidcConfig.DefaultRole)
+	}
+
+	return nil
+}
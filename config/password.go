@@ -0,0 +1,81 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+type (
+	// Rules for passwords chosen at registration, password changes and
+	// resets.
+	PasswordPolicyConfig struct {
+		// Minimal number of characters.
+		MinLength int `json:"minLength" yaml:"minLength"`
+
+		// Maximal number of bytes, at most 72 since bcrypt ignores the rest.
+		MaxLength int `json:"maxLength" yaml:"maxLength"`
+
+		// Number of character classes required, out of lowercase letters,
+		// uppercase letters, digits and other characters.
+		CharacterClasses int `json:"characterClasses" yaml:"characterClasses"`
+
+		// Allow passwords containing the username.
+		AllowUsername bool `json:"allowUsername" yaml:"allowUsername"`
+
+		// Directory of Pwned Passwords range files, whose passwords are
+		// rejected. Disabled if empty.
+		BreachedPasswords string `json:"breachedPasswords" yaml:"breachedPasswords"`
+
+		// Breached passwords seen less often are accepted.
+		BreachedMinCount int `json:"breachedMinCount" yaml:"breachedMinCount"`
+	}
+
+	// Rules for usernames chosen at registration.
+	UsernamePolicyConfig struct {
+		MinLength int    `json:"minLength" yaml:"minLength"`
+		MaxLength int    `json:"maxLength" yaml:"maxLength"`
+		Pattern   string `json:"pattern" yaml:"pattern"`
+	}
+)
+
+// Checks whether the policy can be fulfilled by bcrypt hashed passwords.
+func (policy *PasswordPolicyConfig) Validate() error {
+	var errs []error
+
+	if policy.MinLength < 1 || policy.MaxLength < policy.MinLength || policy.MaxLength > 72 {
+		errs = append(errs, errors.New("auth.passwordPolicy requires 1 <= minLength <= maxLength <= 72"))
+	}
+
+	if policy.CharacterClasses < 0 || policy.CharacterClasses > 4 {
+		errs = append(errs, errors.New("auth.passwordPolicy.characterClasses must be between 0 and 4"))
+	}
+
+	if policy.BreachedPasswords != "" {
+		if info, err := os.Stat(policy.BreachedPasswords); err != nil || !info.IsDir() {
+			errs = append(errs, fmt.Errorf("auth.passwordPolicy.breachedPasswords `%s` is no directory", policy.BreachedPasswords))
+		}
+	}
+
+	if policy.BreachedMinCount < 1 {
+		errs = append(errs, errors.New("auth.passwordPolicy.breachedMinCount must be positive"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Checks whether the pattern compiles and the lengths are consistent.
+func (policy *UsernamePolicyConfig) Validate() error {
+	var errs []error
+
+	if policy.MinLength < 1 || policy.MaxLength < policy.MinLength {
+		errs = append(errs, errors.New("auth.usernamePolicy requires 1 <= minLength <= maxLength"))
+	}
+
+	if _, err := regexp.Compile(policy.Pattern); err != nil {
+		errs = append(errs, fmt.Errorf("auth.usernamePolicy.pattern: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,222 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/kluddizz/maintenance-rest-service/auth"
+	"github.com/kluddizz/maintenance-rest-service/models"
+	"github.com/kluddizz/maintenance-rest-service/repositories"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type (
+	AccountController struct {
+		Accounts *auth.AccountService
+		Users    repositories.UserRepository
+		Tokens   *auth.TokenService
+
+		// Optional throttle of reset requests, whose lockout of a user ends
+		// with a reset.
+		Throttle *auth.LoginThrottle
+
+		// Rules for new passwords.
+		Passwords *auth.PasswordPolicy
+	}
+)
+
+// Creates a new account controller verifying emails and resetting passwords.
+func NewAccountController(accounts *auth.AccountService, users repositories.UserRepository, tokens *auth.TokenService) *AccountController {
+	return &AccountController{
+		Accounts:  accounts,
+		Users:     users,
+		Tokens:    tokens,
+		Passwords: auth.NewPasswordPolicy(),
+	}
+}
+
+// Sends a new verification mail to the current user.
+func (ac AccountController) SendVerification(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+
+	claims, ok := loggedInUser(res, r)
+	if !ok {
+		return
+	}
+
+	user, err := ac.Users.FindById(claims.UserId)
+
+	if err == nil {
+		err = ac.Accounts.SendVerification(user)
+	}
+
+	if err == auth.ErrNoEmail {
+		res.Code = 400
+		res.Content = "No email to verify"
+		res.Send()
+		return
+	}
+
+	if err == auth.ErrEmailVerified {
+		res.Code = 400
+		res.Content = "Email is already verified"
+		res.Send()
+		return
+	}
+
+	if err != nil {
+		log.Printf("Error while sending verification mail: %s", err.Error())
+
+		res.Code = 400
+		res.Content = "Could not send verification mail"
+		res.Send()
+		return
+	}
+
+	// Everything went fine.
+	res.Code = 200
+	res.Content = "Success"
+	res.Send()
+}
+
+// Marks the email of a verification token as verified.
+func (ac AccountController) VerifyEmail(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+	var request models.VerifyEmailRequest
+
+	// Read token from request body
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&request)
+
+	if err != nil {
+		res.Code = 400
+		res.Content = "Error while decoding json body"
+		res.Send()
+		return
+	}
+
+	err = ac.Accounts.VerifyEmail(request.Token)
+
+	if err == auth.ErrInvalidAccountToken {
+		res.Code = 400
+		res.Content = "Invalid or expired token"
+		res.Send()
+		return
+	}
+
+	if err != nil {
+		log.Printf("Error while verifying email: %s", err.Error())
+
+		res.Code = 400
+		res.Content = "Internal error"
+		res.Send()
+		return
+	}
+
+	// Everything went fine.
+	res.Code = 200
+	res.Content = "Success"
+	res.Send()
+}
+
+// Sends a reset token to the users with the given verified email. The
+// response is the same for unknown emails.
+func (ac AccountController) RequestPasswordReset(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+	var request models.PasswordResetRequest
+
+	// Read email from request body
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&request)
+
+	if err != nil || request.Email == "" {
+		res.Code = 400
+		res.Content = "Error while decoding json body"
+		res.Send()
+		return
+	}
+
+	if !allowReset(res, ac.Throttle, request.Email, clientAddress(r)) {
+		return
+	}
+
+	if err := ac.Accounts.RequestReset(request.Email); err != nil {
+		log.Printf("Error while requesting password reset: %s", err.Error())
+	}
+
+	// Everything went fine, or at least nothing the client should know about.
+	res.Code = 200
+	res.Content = "Success"
+	res.Send()
+}
+
+// Sets a new password using a reset token and revokes all tokens of the user.
+func (ac AccountController) ResetPassword(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+	var request models.PasswordResetConfirmation
+
+	// Read token and new password from request body
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&request)
+
+	if err != nil {
+		res.Code = 400
+		res.Content = "Error while decoding json body"
+		res.Send()
+		return
+	}
+
+	user, err := ac.Accounts.VerifyResetToken(request.Token)
+
+	if err == auth.ErrInvalidAccountToken {
+		res.Code = 400
+		res.Content = "Invalid or expired token"
+		res.Send()
+		return
+	}
+
+	if err == nil && !acceptPassword(res, ac.Passwords, user.UserName, request.Password) {
+		return
+	}
+
+	// Hash the password using bcrypt
+	var hashedPassword []byte
+	if err == nil {
+		hashedPassword, err = bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+	}
+
+	if err == nil {
+		err = ac.Accounts.ResetPassword(user, hashedPassword)
+	}
+
+	// The token has been used in the meantime.
+	if err == auth.ErrInvalidAccountToken {
+		res.Code = 400
+		res.Content = "Invalid or expired token"
+		res.Send()
+		return
+	}
+
+	if err == nil {
+		err = ac.Tokens.RevokeUser(user.Id)
+	}
+
+	if err != nil {
+		log.Printf("Error while resetting password: %s", err.Error())
+
+		res.Code = 400
+		res.Content = "Could not reset password"
+		res.Send()
+		return
+	}
+
+	// The lockout protected the old password.
+	completeAttempt(ac.Throttle, user.UserName, clientAddress(r), false)
+
+	// Everything went fine.
+	res.Code = 200
+	res.Content = "Success"
+	res.Send()
+}
@@ -0,0 +1,123 @@
+package controllers_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+// Test if the email given on registration can be verified with the mailed token.
+func TestVerifyEmail(t *testing.T) {
+	h := setup(t)
+	newUser := models.User{UserName: "newuser", Password: "newpassword", Email: "new@example.com"}
+	h.Do("POST", "/register", newUser, "")
+
+	request := models.VerifyEmailRequest{Token: h.MailToken(newUser.Email)}
+
+	// Mailed tokens are not accepted as access tokens.
+	res, _ := h.Do("GET", "/masters", nil, request.Token)
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
+
+	res, _ = h.Do("POST", "/email/verify", request, "")
+	if res.StatusCode != 200 {
+		t.Fatalf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	if stored, _ := h.Users.FindByUsername(newUser.UserName); !stored.EmailVerified {
+		t.Errorf("Expected email of %s to be verified", newUser.UserName)
+	}
+
+	res, _ = h.DoAs(newUser.UserName, "POST", "/users/me/email/verification", nil)
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
+}
+
+// Test if a password can be reset once with the mailed token.
+func TestResetPassword(t *testing.T) {
+	h := setup(t)
+	newUser := models.User{UserName: "newuser", Password: "newpassword", Email: "new@example.com"}
+	h.Do("POST", "/register", newUser, "")
+	h.Do("POST", "/email/verify", models.VerifyEmailRequest{Token: h.MailToken(newUser.Email)}, "")
+	accessToken := h.Token(newUser.UserName)
+
+	// Unknown emails are not revealed.
+	res, _ := h.Do("POST", "/password/reset", models.PasswordResetRequest{Email: "unknown@example.com"}, "")
+	if res.StatusCode != 200 || len(h.Mailbox.Messages) != 1 {
+		t.Errorf("Expected no mail to be sent but received %d %v", res.StatusCode, h.Mailbox.Messages)
+	}
+
+	h.Do("POST", "/password/reset", models.PasswordResetRequest{Email: newUser.Email}, "")
+	confirmation := models.PasswordResetConfirmation{Token: h.MailToken(newUser.Email), Password: "changedpw"}
+
+	for _, expected := range []int{200, 400} {
+		res, _ = h.Do("POST", "/password/reset/confirm", confirmation, "")
+		if res.StatusCode != expected {
+			t.Errorf("Expected status code to be %d but received %d", expected, res.StatusCode)
+		}
+	}
+
+	h.Login(newUser.UserName, confirmation.Password)
+
+	// Existing tokens are revoked with the old password.
+	res, _ = h.Do("GET", "/masters", nil, accessToken)
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
+}
+
+// Test if a reset token is used only once by concurrent confirmations.
+func TestResetPasswordConcurrent(t *testing.T) {
+	h := setup(t)
+	newUser := models.User{UserName: "newuser", Password: "newpassword", Email: "new@example.com"}
+	h.Do("POST", "/register", newUser, "")
+	h.Do("POST", "/email/verify", models.VerifyEmailRequest{Token: h.MailToken(newUser.Email)}, "")
+	h.Do("POST", "/password/reset", models.PasswordResetRequest{Email: newUser.Email}, "")
+	token := h.MailToken(newUser.Email)
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	succeeded := 0
+
+	for _, password := range []string{"firstpw", "secondpw", "thirdpw", "fourthpw"} {
+		wg.Add(1)
+		go func(password string) {
+			defer wg.Done()
+
+			confirmation := models.PasswordResetConfirmation{Token: token, Password: password}
+			if res, _ := h.Do("POST", "/password/reset/confirm", confirmation, ""); res.StatusCode == 200 {
+				mutex.Lock()
+				succeeded++
+				mutex.Unlock()
+			}
+		}(password)
+	}
+
+	wg.Wait()
+
+	if succeeded != 1 {
+		t.Errorf("Expected %d successful reset but received %d", 1, succeeded)
+	}
+}
+
+// Test if repeated reset requests for an email are locked out like failed
+// logins.
+func TestResetPasswordThrottle(t *testing.T) {
+	h := setup(t)
+	request := models.PasswordResetRequest{Email: "new@example.com"}
+
+	for i := 0; i < h.Throttle.LockoutThreshold; i++ {
+		h.Do("POST", "/password/reset", request, "")
+	}
+
+	res, _ := h.Do("POST", "/password/reset", request, "")
+	if res.StatusCode != 429 || res.Header.Get("Retry-After") == "" {
+		t.Errorf("Expected status code to be %d but received %d", 429, res.StatusCode)
+	}
+
+	// Logins from the same address are not locked out yet.
+	h.Login(user.UserName, user.Password)
+}
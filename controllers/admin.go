@@ -0,0 +1,341 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/kluddizz/maintenance-rest-service/auth"
+	"github.com/kluddizz/maintenance-rest-service/middlewares"
+	"github.com/kluddizz/maintenance-rest-service/models"
+	"github.com/kluddizz/maintenance-rest-service/repositories"
+)
+
+// Largest page of users sent at once.
+const maxUserPage = 500
+
+// Sends a page of users whose username, name or email contains the search
+// query parameter. The limit (default 50) and offset query parameters page
+// through the users ordered by id.
+func (uc UserController) ListUsers(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+	query := models.UserQuery{Search: r.URL.Query().Get("search")}
+
+	var ok bool
+	if query.Limit, ok = queryInt(res, r, "limit", 50, 1, maxUserPage); !ok {
+		return
+	}
+
+	if query.Offset, ok = queryInt(res, r, "offset", 0, 0, -1); !ok {
+		return
+	}
+
+	users, total, err := uc.Users.List(query)
+
+	if err != nil {
+		log.Printf("Error while listing users: %s", err.Error())
+
+		res.Code = 400
+		res.Content = "Could not find users"
+		res.Send()
+		return
+	}
+
+	page := models.UserPage{Users: []models.UserProfile{}, Total: total, Limit: query.Limit, Offset: query.Offset}
+	for _, user := range users {
+		page.Users = append(page.Users, user.Profile())
+	}
+
+	// Everything went fine.
+	res.Code = 200
+	res.Content = page
+	res.Send()
+}
+
+// Sends the profile of the user with the given id.
+func (uc UserController) GetUser(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+
+	user, ok := uc.findUser(res, p)
+	if !ok {
+		return
+	}
+
+	// Everything went fine.
+	res.Code = 200
+	res.Content = user.Profile()
+	res.Send()
+}
+
+// Disables or enables the user with the given id. Disabled users cannot
+// login and their tokens and API keys are rejected.
+func (uc UserController) SetUserStatus(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+	var request models.UserStatusRequest
+
+	user, ok := uc.findUser(res, p)
+	if !ok {
+		return
+	}
+
+	// Read status from request body
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&request)
+
+	if err != nil {
+		res.Code = 400
+		res.Content = "Error while decoding json body"
+		res.Send()
+		return
+	}
+
+	claims, _ := middlewares.ClaimsFromContext(r.Context())
+
+	// Nobody would be left to enable the admin again
+	if request.Disabled && claims.UserId == user.Id {
+		res.Code = 400
+		res.Content = "Admins cannot disable themselves"
+		res.Send()
+		return
+	}
+
+	err = uc.Users.SetDisabled(user.Id, request.Disabled)
+
+	if err == nil && request.Disabled {
+		err = uc.Tokens.RevokeUser(user.Id)
+	}
+
+	if err != nil {
+		log.Printf("Error while changing user status: %s", err.Error())
+
+		res.Code = 400
+		res.Content = "Could not change user status"
+		res.Send()
+		return
+	}
+
+	event := models.AuditUserEnabled
+	if request.Disabled {
+		event = models.AuditUserDisabled
+	}
+
+	uc.record(event, user.UserName, claims.UserName, "")
+
+	// Everything went fine.
+	user.Disabled = request.Disabled
+	res.Code = 200
+	res.Content = user.Profile()
+	res.Send()
+}
+
+// Deletes the user with the given id and revokes all of its tokens.
+func (uc UserController) RemoveUser(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+
+	user, ok := uc.findUser(res, p)
+	if !ok {
+		return
+	}
+
+	claims, _ := middlewares.ClaimsFromContext(r.Context())
+
+	// Users delete their own account with their password
+	if claims.UserId == user.Id {
+		res.Code = 400
+		res.Content = "Admins cannot delete themselves"
+		res.Send()
+		return
+	}
+
+	err := uc.Users.Delete(user.Id)
+
+	if err == nil {
+		err = uc.Tokens.RevokeUser(user.Id)
+	}
+
+	if err != nil {
+		log.Printf("Error while deleting user: %s", err.Error())
+
+		res.Code = 400
+		res.Content = "Could not delete user"
+		res.Send()
+		return
+	}
+
+	uc.record(models.AuditUserDeleted, user.UserName, claims.UserName, "")
+
+	// Everything went fine.
+	res.Code = 200
+	res.Content = "Success"
+	res.Send()
+}
+
+// Requires the user with the given id to choose a new password before the
+// next login, e.g. because the password might have leaked. A reset token is
+// mailed to the user and all tokens of the user are revoked. Users without
+// verified email could not receive the token and are refused.
+func (uc UserController) ForcePasswordReset(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+
+	user, ok := uc.findUser(res, p)
+	if !ok {
+		return
+	}
+
+	if uc.Accounts == nil {
+		res.Code = 409
+		res.Content = "Password resets are disabled"
+		res.Send()
+		return
+	}
+
+	if !user.EmailVerified {
+		res.Code = 409
+		res.Content = "User has no verified email to receive a reset token"
+		res.Send()
+		return
+	}
+
+	// The token stays valid until the password changed.
+	err := uc.Accounts.SendReset(user)
+
+	if err == auth.ErrNoLocalPassword {
+		res.Code = 409
+		res.Content = "Password is managed by the identity provider"
+		res.Send()
+		return
+	}
+
+	if err == nil {
+		err = uc.Users.RequirePasswordReset(user.Id)
+	}
+
+	if err == nil {
+		err = uc.Tokens.RevokeUser(user.Id)
+	}
+
+	if err != nil {
+		log.Printf("Error while requiring password reset: %s", err.Error())
+
+		res.Code = 400
+		res.Content = "Could not require password reset"
+		res.Send()
+		return
+	}
+
+	claims, _ := middlewares.ClaimsFromContext(r.Context())
+	uc.record(models.AuditPasswordResetForce, user.UserName, claims.UserName, "")
+
+	// Everything went fine.
+	user.PasswordResetRequired = true
+	res.Code = 200
+	res.Content = user.Profile()
+	res.Send()
+}
+
+// Issues a short-lived access token of the user with the given id to the
+// admin, e.g. to reproduce a support case. The token names the admin in its
+// claims, cannot be refreshed and cannot manage the user's account. Admins
+// cannot be impersonated.
+func (uc UserController) ImpersonateUser(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+
+	user, ok := uc.findUser(res, p)
+	if !ok {
+		return
+	}
+
+	// API keys and impersonation tokens cannot impersonate.
+	claims, ok := loggedInUser(res, r)
+	if !ok {
+		return
+	}
+
+	if claims.UserId == user.Id || user.Role.Can(models.PermissionManageUsers) {
+		res.Code = 403
+		res.Content = "User cannot be impersonated"
+		res.Send()
+		return
+	}
+
+	token, err := uc.Tokens.Impersonate(user, claims)
+
+	if err == auth.ErrUserDisabled {
+		res.Code = 403
+		res.Content = "User is disabled"
+		res.Send()
+		return
+	}
+
+	if err != nil {
+		log.Printf("Error while impersonating user: %s", err.Error())
+
+		res.Code = 400
+		res.Content = "Could not impersonate user"
+		res.Send()
+		return
+	}
+
+	uc.record(models.AuditImpersonation, user.UserName, claims.UserName, "")
+
+	// Everything went fine.
+	res.Code = 200
+	res.Content = token
+	res.Send()
+}
+
+// Returns the user whose id is given as route parameter. If the user cannot
+// be found, the error is sent and false returned.
+func (uc UserController) findUser(res *models.JsonResponse, p httprouter.Params) (models.UserDb, bool) {
+	id, err := strconv.Atoi(p.ByName("id"))
+
+	if err != nil {
+		res.Code = 400
+		res.Content = "Invalid user id"
+		res.Send()
+		return models.UserDb{}, false
+	}
+
+	user, err := uc.Users.FindById(id)
+
+	if err == repositories.ErrNotFound {
+		res.Code = 404
+		res.Content = "User not found"
+		res.Send()
+		return user, false
+	}
+
+	if err != nil {
+		log.Printf("Error while finding user: %s", err.Error())
+
+		res.Code = 400
+		res.Content = "Internal error"
+		res.Send()
+		return user, false
+	}
+
+	return user, true
+}
+
+// Returns the number given as query parameter, or the fallback if it is
+// missing. If the number is out of range, the error is sent and false
+// returned. A negative upper bound means none.
+func queryInt(res *models.JsonResponse, r *http.Request, name string, fallback, lower, upper int) (int, bool) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, true
+	}
+
+	number, err := strconv.Atoi(value)
+
+	if err != nil || number < lower || (upper >= 0 && number > upper) {
+		res.Code = 400
+		res.Content = "Invalid " + name
+		res.Send()
+		return 0, false
+	}
+
+	return number, true
+}
@@ -0,0 +1,178 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+// Test if admins page through users matching a search.
+func TestListUsers(t *testing.T) {
+	h := setup(t)
+	h.CreateUserWithRole("admin", "adminpw", models.RoleAdmin)
+
+	for i := 0; i < 3; i++ {
+		h.CreateUser(fmt.Sprintf("support_%d", i), "supportpw")
+	}
+
+	var page models.UserPage
+	res, response := h.DoAs("admin", "GET", "/admin/users?search=SUPPORT_&limit=2&offset=1", nil)
+	h.Decode(response.Content, &page)
+
+	if res.StatusCode != 200 || page.Total != 3 || len(page.Users) != 2 || page.Users[0].UserName != "support_1" {
+		t.Errorf("Expected second page of support users but received %d %v", res.StatusCode, page)
+	}
+
+	// Underscores are no wildcards.
+	res, response = h.DoAs("admin", "GET", "/admin/users?search=t_", nil)
+	h.Decode(response.Content, &page)
+
+	if res.StatusCode != 200 || page.Total != 3 {
+		t.Errorf("Expected %d users but received %d %v", 3, res.StatusCode, page)
+	}
+
+	res, _ = h.DoAs("admin", "GET", "/admin/users?limit=0", nil)
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
+
+	res, _ = h.DoAs(user.UserName, "GET", "/admin/users", nil)
+	if res.StatusCode != 403 {
+		t.Errorf("Expected status code to be %d but received %d", 403, res.StatusCode)
+	}
+}
+
+// Test if disabled users can neither login nor use their tokens until they
+// are enabled again.
+func TestSetUserStatus(t *testing.T) {
+	h := setup(t)
+	admin := h.CreateUserWithRole("admin", "adminpw", models.RoleAdmin)
+	tokens := h.Login(user.UserName, user.Password)
+	path := fmt.Sprintf("/admin/users/%d/status", user.Id)
+
+	res, _ := h.DoAs("admin", "PUT", path, models.UserStatusRequest{Disabled: true})
+	if res.StatusCode != 200 {
+		t.Fatalf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	res, _ = h.Do("POST", "/login", models.LoginCredentials{UserName: user.UserName, Password: user.Password}, "")
+	if res.StatusCode != 403 {
+		t.Errorf("Expected status code to be %d but received %d", 403, res.StatusCode)
+	}
+
+	// Even tokens issued after the revocation are rejected.
+	res, _ = h.DoAs(user.UserName, "GET", "/masters", nil)
+	if res.StatusCode != 403 {
+		t.Errorf("Expected status code to be %d but received %d", 403, res.StatusCode)
+	}
+
+	res, _ = h.Do("POST", "/token/refresh", models.RefreshRequest{RefreshToken: tokens.RefreshToken}, "")
+	if res.StatusCode != 401 {
+		t.Errorf("Expected status code to be %d but received %d", 401, res.StatusCode)
+	}
+
+	res, _ = h.DoAs("admin", "PUT", fmt.Sprintf("/admin/users/%d/status", admin.Id), models.UserStatusRequest{Disabled: true})
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
+
+	res, _ = h.DoAs("admin", "PUT", path, models.UserStatusRequest{Disabled: false})
+	if res.StatusCode != 200 {
+		t.Fatalf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	h.Login(user.UserName, user.Password)
+
+	if events, _ := h.Audit.List(1); len(events) != 1 || events[0].Event != models.AuditUserEnabled || events[0].Actor != "admin" {
+		t.Errorf("Expected enabled user to be recorded but received %v", events)
+	}
+}
+
+// Test if users have to reset their password after an admin required it.
+func TestForcePasswordReset(t *testing.T) {
+	h := setup(t)
+	h.CreateUserWithRole("admin", "adminpw", models.RoleAdmin)
+	newUser := models.User{UserName: "newuser", Password: "newpassword", Email: "new@example.com"}
+	h.Do("POST", "/register", newUser, "")
+	h.Do("POST", "/email/verify", models.VerifyEmailRequest{Token: h.MailToken(newUser.Email)}, "")
+	stored, _ := h.Users.FindByUsername(newUser.UserName)
+
+	res, _ := h.DoAs("admin", "POST", fmt.Sprintf("/admin/users/%d/password-reset", stored.Id), nil)
+	if res.StatusCode != 200 {
+		t.Fatalf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	res, _ = h.Do("POST", "/login", models.LoginCredentials{UserName: newUser.UserName, Password: newUser.Password}, "")
+	if res.StatusCode != 403 {
+		t.Errorf("Expected status code to be %d but received %d", 403, res.StatusCode)
+	}
+
+	res, _ = h.Do("POST", "/password/reset/confirm", models.PasswordResetConfirmation{Token: h.MailToken(newUser.Email), Password: "resetpassword"}, "")
+	if res.StatusCode != 200 {
+		t.Fatalf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	h.Login(newUser.UserName, "resetpassword")
+
+	// Users could not receive the token without verified email.
+	res, _ = h.DoAs("admin", "POST", fmt.Sprintf("/admin/users/%d/password-reset", user.Id), nil)
+	if res.StatusCode != 409 {
+		t.Errorf("Expected status code to be %d but received %d", 409, res.StatusCode)
+	}
+
+	h.Login(user.UserName, user.Password)
+}
+
+// Test if impersonation tokens name the admin and cannot manage the account.
+func TestImpersonateUser(t *testing.T) {
+	h := setup(t)
+	admin := h.CreateUserWithRole("admin", "adminpw", models.RoleAdmin)
+
+	var token models.ImpersonationToken
+	res, response := h.DoAs("admin", "POST", fmt.Sprintf("/admin/users/%d/impersonate", user.Id), nil)
+	h.Decode(response.Content, &token)
+
+	if res.StatusCode != 200 {
+		t.Fatalf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	claims := &models.CustomClaims{}
+	if err := h.Keys.Verify(token.AccessToken, claims); err != nil || claims.UserId != user.Id || claims.ImpersonatorId != admin.Id || claims.ImpersonatorName != "admin" {
+		t.Errorf("Expected token of %s impersonated by admin but received %v (%v)", user.UserName, claims, err)
+	}
+
+	res, _ = h.Do("GET", "/masters", nil, token.AccessToken)
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	res, _ = h.Do("PUT", "/users/me/password", models.PasswordChange{CurrentPassword: user.Password, NewPassword: "changedpw"}, token.AccessToken)
+	if res.StatusCode != 403 {
+		t.Errorf("Expected status code to be %d but received %d", 403, res.StatusCode)
+	}
+
+	// Admins cannot be impersonated.
+	other := h.CreateUserWithRole("other", "otherpw", models.RoleAdmin)
+
+	res, _ = h.DoAs("admin", "POST", fmt.Sprintf("/admin/users/%d/impersonate", other.Id), nil)
+	if res.StatusCode != 403 {
+		t.Errorf("Expected status code to be %d but received %d", 403, res.StatusCode)
+	}
+
+	if events, _ := h.Audit.List(1); len(events) != 1 || events[0].Event != models.AuditImpersonation {
+		t.Errorf("Expected impersonation to be recorded but received %v", events)
+	}
+	// API keys of admins cannot impersonate either.
+	var key models.CreatedApiKey
+	request := models.ApiKeyRequest{Name: "support", Scopes: []models.Permission{models.PermissionManageUsers}}
+	_, response = h.DoAs("admin", "POST", "/users/me/api-keys", request)
+	h.Decode(response.Content, &key)
+
+	path := fmt.Sprintf("/admin/users/%d/impersonate", user.Id)
+	res, _ = h.DoWithHeader("POST", path, nil, http.Header{"X-Api-Key": {key.Key}})
+	if key.Key == "" || res.StatusCode != 403 {
+		t.Errorf("Expected status code to be %d but received %d", 403, res.StatusCode)
+	}
+}
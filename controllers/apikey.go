@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/kluddizz/maintenance-rest-service/auth"
+	"github.com/kluddizz/maintenance-rest-service/middlewares"
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+type (
+	ApiKeyController struct {
+		Keys *auth.ApiKeyService
+	}
+)
+
+// Creates a new API key controller managing the keys of the current user.
+func NewApiKeyController(keys *auth.ApiKeyService) *ApiKeyController {
+	return &ApiKeyController{
+		Keys: keys,
+	}
+}
+
+// Returns the keys of the current user without the keys themselves.
+func (kc ApiKeyController) GetApiKeys(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+
+	claims, ok := loggedInUser(res, r)
+	if !ok {
+		return
+	}
+
+	keys, err := kc.Keys.Keys.ListByUser(claims.UserId)
+
+	if err != nil {
+		res.Code = 400
+		res.Content = "Could not find API keys"
+		res.Send()
+		return
+	}
+
+	// Everything went fine.
+	res.Code = 200
+	res.Content = keys
+	res.Send()
+}
+
+// Creates a new key for the current user. The key is only sent once.
+func (kc ApiKeyController) CreateApiKey(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+
+	claims, ok := loggedInUser(res, r)
+	if !ok {
+		return
+	}
+
+	// Read the key description from the JSON body.
+	var request models.ApiKeyRequest
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&request)
+
+	if err != nil || request.Name == "" {
+		res.Code = 400
+		res.Content = "Could not parse json body. A name is required."
+		res.Send()
+		return
+	}
+
+	key, err := kc.Keys.Create(claims.UserId, request)
+
+	if err == auth.ErrInvalidScopes {
+		res.Code = 400
+		res.Content = "At least one known scope is required"
+		res.Send()
+		return
+	}
+
+	if err == auth.ErrScopesDenied {
+		res.Code = 403
+		res.Content = "Scopes exceed the permissions of the user"
+		res.Send()
+		return
+	}
+
+	if err != nil {
+		log.Printf("Error while creating API key: %s", err.Error())
+
+		res.Code = 400
+		res.Content = "Could not create API key"
+		res.Send()
+		return
+	}
+
+	// Everything went fine.
+	res.Code = 200
+	res.Content = key
+	res.Send()
+}
+
+// Revokes a key of the current user.
+func (kc ApiKeyController) DeleteApiKey(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+
+	claims, ok := loggedInUser(res, r)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(p.ByName("id"))
+
+	if err == nil {
+		err = kc.Keys.Keys.Delete(id, claims.UserId)
+	}
+
+	if err != nil {
+		res.Code = 400
+		res.Content = fmt.Sprintf("Could not delete API key with id `%s`", p.ByName("id"))
+		res.Send()
+		return
+	}
+
+	// Everything went fine.
+	res.Code = 200
+	res.Content = "Success"
+	res.Send()
+}
+
+// Returns the claims of a user who logged in with a password. Requests
+// authenticated by API keys or client certificates are rejected, so keys
+// cannot be used to create further keys. Admins impersonating the user
+// cannot manage the user's account either.
+func loggedInUser(res *models.JsonResponse, r *http.Request) (*models.CustomClaims, bool) {
+	claims, ok := middlewares.ClaimsFromContext(r.Context())
+
+	if !ok || claims.UserId == 0 || claims.ApiKeyId != 0 {
+		res.Code = 403
+		res.Content = "A login token is required"
+		res.Send()
+		return nil, false
+	}
+
+	if claims.Impersonated() {
+		res.Code = 403
+		res.Content = "Not allowed while impersonating a user"
+		res.Send()
+		return nil, false
+	}
+
+	return claims, true
+}
@@ -0,0 +1,104 @@
+package controllers_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/kluddizz/maintenance-rest-service/app"
+	"github.com/kluddizz/maintenance-rest-service/app/apptest"
+	"github.com/kluddizz/maintenance-rest-service/auth"
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+// Test if API keys authenticate requests within their scopes.
+func TestApiKey(t *testing.T) {
+	h := setup(t)
+	request := models.ApiKeyRequest{Name: "monitoring", Scopes: []models.Permission{models.PermissionReadMasters}}
+
+	res, response := h.DoAs(user.UserName, "POST", "/users/me/api-keys", request)
+	if res.StatusCode != 200 {
+		t.Fatalf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	var key models.CreatedApiKey
+	h.Decode(response.Content, &key)
+
+	header := http.Header{"X-Api-Key": {key.Key}}
+	res, _ = h.DoWithHeader("GET", "/masters", nil, header)
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	// Writing is not within the scopes of the key, although the user may.
+	res, _ = h.DoWithHeader("POST", "/masters", master, http.Header{"Authorization": {"ApiKey " + key.Key}})
+	if res.StatusCode != 403 {
+		t.Errorf("Expected status code to be %d but received %d", 403, res.StatusCode)
+	}
+
+	// Keys must not create further keys.
+	res, _ = h.DoWithHeader("POST", "/users/me/api-keys", request, header)
+	if res.StatusCode != 403 {
+		t.Errorf("Expected status code to be %d but received %d", 403, res.StatusCode)
+	}
+
+	_, response = h.DoAs(user.UserName, "GET", "/users/me/api-keys", nil)
+
+	var keys []models.ApiKey
+	h.Decode(response.Content, &keys)
+
+	if len(keys) != 1 || keys[0].Prefix != key.Prefix || keys[0].LastUsedAt == 0 {
+		t.Errorf("Expected one used key but received %v", keys)
+	}
+
+	res, _ = h.DoAs(user.UserName, "DELETE", fmt.Sprintf("/users/me/api-keys/%d", key.Id), nil)
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	res, _ = h.DoWithHeader("GET", "/masters", nil, header)
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
+}
+
+// Test if keys without known scopes are rejected.
+func TestApiKeyInvalidScopes(t *testing.T) {
+	h := setup(t)
+	request := models.ApiKeyRequest{Name: "monitoring", Scopes: []models.Permission{"everything"}}
+
+	res, _ := h.DoAs(user.UserName, "POST", "/users/me/api-keys", request)
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
+}
+
+// Test if keys of users who did not enable a required TOTP are limited like
+// their tokens.
+func TestApiKeyTotpRequiredRole(t *testing.T) {
+	h := apptest.New(t, func(deps *app.Deps) { deps.TotpRole = models.RoleOperator })
+	operator := h.CreateUserWithRole(user.UserName, user.Password, models.RoleOperator)
+	write := models.ApiKeyRequest{Name: "deploy", Scopes: []models.Permission{models.PermissionWriteMasters}}
+
+	res, _ := h.DoAs(user.UserName, "POST", "/users/me/api-keys", write)
+	if res.StatusCode != 403 {
+		t.Errorf("Expected status code to be %d but received %d", 403, res.StatusCode)
+	}
+
+	read := models.ApiKeyRequest{Name: "monitoring", Scopes: []models.Permission{models.PermissionReadMasters}}
+	res, _ = h.DoAs(user.UserName, "POST", "/users/me/api-keys", read)
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	// Keys created before the role required TOTP are limited as well.
+	key, err := auth.NewApiKeyService(h.ApiKeys, h.Users).Create(operator.Id, write)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, _ = h.DoWithHeader("POST", "/masters", master, http.Header{"X-Api-Key": {key.Key}})
+	if res.StatusCode != 403 {
+		t.Errorf("Expected status code to be %d but received %d", 403, res.StatusCode)
+	}
+}
@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/kluddizz/maintenance-rest-service/models"
+	"github.com/kluddizz/maintenance-rest-service/repositories"
+)
+
+type (
+	AuditController struct {
+		Audit repositories.AuditRepository
+	}
+)
+
+// Creates a new audit controller reading from the given repository.
+func NewAuditController(audit repositories.AuditRepository) *AuditController {
+	return &AuditController{
+		Audit: audit,
+	}
+}
+
+// Returns the latest audit events, at most the number given by the limit
+// query parameter (default 100).
+func (ac AuditController) GetAuditEvents(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+	limit := 100
+
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+
+		if err != nil || limit <= 0 {
+			res.Code = 400
+			res.Content = "Invalid limit"
+			res.Send()
+			return
+		}
+	}
+
+	events, err := ac.Audit.List(limit)
+
+	if err != nil {
+		res.Code = 400
+		res.Content = "Could not find audit events"
+		res.Send()
+		return
+	}
+
+	if events == nil {
+		events = []models.AuditEvent{}
+	}
+
+	// Everything went fine.
+	res.Code = 200
+	res.Content = events
+	res.Send()
+}
@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/kluddizz/maintenance-rest-service/models"
+	"github.com/kluddizz/maintenance-rest-service/version"
+)
+
+type (
+	// Checks whether a dependency of the service is usable.
+	HealthCheck func(ctx context.Context) error
+
+	HealthController struct {
+		Checks map[string]HealthCheck
+	}
+)
+
+// Maximum duration of a single readiness check.
+const healthCheckTimeout = 2 * time.Second
+
+// Creates a new health controller running the given readiness checks.
+func NewHealthController(checks map[string]HealthCheck) *HealthController {
+	return &HealthController{
+		Checks: checks,
+	}
+}
+
+// Reports that the process is alive.
+func (hc HealthController) Liveness(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+	res.Code = 200
+	res.Content = models.HealthStatus{Status: models.HealthOk}
+	res.Send()
+}
+
+// Runs all readiness checks and reports the state of each dependency.
+func (hc HealthController) Readiness(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+	status := models.HealthStatus{
+		Status: models.HealthOk,
+		Checks: make(map[string]string),
+	}
+
+	for name, check := range hc.Checks {
+		ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+		err := check(ctx)
+		cancel()
+
+		if err != nil {
+			status.Status = models.HealthUnavailable
+			status.Checks[name] = err.Error()
+		} else {
+			status.Checks[name] = models.HealthOk
+		}
+	}
+
+	res.Code = 200
+	if status.Status != models.HealthOk {
+		res.Code = 503
+	}
+
+	res.Content = status
+	res.Send()
+}
+
+// Returns the build metadata of the running binary.
+func (hc HealthController) Version(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+	res.Code = 200
+	res.Content = models.BuildInfo{
+		Version:   version.Version,
+		Commit:    version.Commit,
+		BuildDate: version.BuildDate,
+		GoVersion: runtime.Version(),
+	}
+	res.Send()
+}
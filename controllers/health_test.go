@@ -0,0 +1,52 @@
+package controllers_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/kluddizz/maintenance-rest-service/app/apptest"
+	"github.com/kluddizz/maintenance-rest-service/models"
+	"github.com/kluddizz/maintenance-rest-service/utils"
+)
+
+// Test if the liveness and version endpoints work without a token.
+func TestLivenessAndVersion(t *testing.T) {
+	h := apptest.New(t)
+
+	for _, path := range []string{"/healthz", "/version"} {
+		res, _ := h.Do("GET", path, nil, "")
+		if res.StatusCode != 200 {
+			t.Errorf("Expected status code of %s to be %d but received %d", path, 200, res.StatusCode)
+		}
+	}
+}
+
+// Test if the readiness endpoint reports failing dependencies.
+func TestReadiness(t *testing.T) {
+	h := apptest.New(t)
+	h.Checks["database"] = func(ctx context.Context) error {
+		return nil
+	}
+
+	res, _ := h.Do("GET", "/readyz", nil, "")
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	h.Checks["signingKey"] = func(ctx context.Context) error {
+		return errors.New("key missing")
+	}
+
+	res, response := h.Do("GET", "/readyz", nil, "")
+	if res.StatusCode != 503 {
+		t.Errorf("Expected status code to be %d but received %d", 503, res.StatusCode)
+	}
+
+	var status models.HealthStatus
+	utils.MapToStruct(response.Content, &status)
+
+	if status.Checks["database"] != models.HealthOk || status.Checks["signingKey"] != "key missing" {
+		t.Errorf("Expected checks to report the failing key but received %v", status.Checks)
+	}
+}
@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/kluddizz/maintenance-rest-service/auth"
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+type (
+	KeyController struct {
+		Keys *auth.KeyManager
+	}
+)
+
+// Creates a new key controller publishing the public keys of the manager.
+func NewKeyController(keys *auth.KeyManager) *KeyController {
+	return &KeyController{
+		Keys: keys,
+	}
+}
+
+// Publishes the public keys verifying tokens as JSON Web Key Set. The set is
+// sent without the response envelope, so standard JWT libraries can read it.
+func (kc KeyController) GetJWKS(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	set, err := kc.Keys.JWKS()
+
+	if err != nil {
+		res := models.NewJsonResponse(w)
+		res.Code = 500
+		res.Content = "Internal error"
+		res.Send()
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(set)
+}
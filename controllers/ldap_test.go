@@ -0,0 +1,79 @@
+package controllers_test
+
+import (
+	"testing"
+
+	"github.com/kluddizz/maintenance-rest-service/app/apptest"
+	"github.com/kluddizz/maintenance-rest-service/auth"
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+// Test if directory users login with their directory password and receive
+// the role of their groups, while local users still login.
+func TestLdapLogin(t *testing.T) {
+	directory := apptest.NewStubLdap(t)
+	directory.Add("cn=service,dc=example,dc=com", "servicepw", nil)
+	directory.Add("uid=tech,ou=people,dc=example,dc=com", "techpw", map[string][]string{
+		"objectClass": {"person"},
+		"uid":         {"tech"},
+		"mail":        {"tech@example.com"},
+		"memberOf":    {"cn=Operators,ou=groups,dc=example,dc=com"},
+	})
+
+	h := apptest.New(t, apptest.WithLdap(directory, "dc=example,dc=com", func(ldap *auth.LdapAuthenticator) {
+		ldap.BindDn = "cn=service,dc=example,dc=com"
+		ldap.BindPassword = "servicepw"
+		ldap.Roles["operators"] = models.RoleOperator
+	}))
+
+	h.CreateUser("testuser", "testpw")
+
+	tokens := h.Login("tech", "techpw")
+	res, _ := h.Do("POST", "/masters", models.Master{Name: "Directory"}, tokens.AccessToken)
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	if user, err := h.Users.FindByUsername("tech"); err != nil || user.Role != models.RoleOperator || user.Email.String != "tech@example.com" {
+		t.Errorf("Expected provisioned operator but received %v (%v)", user, err)
+	}
+
+	for _, credentials := range []models.LoginCredentials{
+		{UserName: "tech", Password: "wrongpw"},
+		{UserName: "tech", Password: ""},
+		{UserName: "*", Password: "techpw"},
+	} {
+		res, _ = h.Do("POST", "/login", credentials, "")
+		if res.StatusCode != 400 {
+			t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+		}
+	}
+
+	// Local users fall back to the users table, even without directory.
+	h.Login("testuser", "testpw")
+	directory.Close()
+	h.Login("testuser", "testpw")
+
+	res, _ = h.Do("POST", "/login", models.LoginCredentials{UserName: "tech", Password: "techpw"}, "")
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
+}
+
+// Test if directory users without mapped group are denied.
+func TestLdapLoginDenied(t *testing.T) {
+	directory := apptest.NewStubLdap(t)
+	directory.Add("uid=guest,dc=example,dc=com", "guestpw", map[string][]string{
+		"objectClass": {"person"},
+		"uid":         {"guest"},
+	})
+
+	h := apptest.New(t, apptest.WithLdap(directory, "dc=example,dc=com", func(ldap *auth.LdapAuthenticator) {
+		ldap.DefaultRole = ""
+	}))
+
+	res, _ := h.Do("POST", "/login", models.LoginCredentials{UserName: "guest", Password: "guestpw"}, "")
+	if res.StatusCode != 403 {
+		t.Errorf("Expected status code to be %d but received %d", 403, res.StatusCode)
+	}
+}
@@ -1,36 +1,49 @@
 package controllers
 
 import (
-	"database/sql"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/julienschmidt/httprouter"
+	"github.com/kluddizz/maintenance-rest-service/middlewares"
 	"github.com/kluddizz/maintenance-rest-service/models"
+	"github.com/kluddizz/maintenance-rest-service/repositories"
 )
 
 type (
 	MasterController struct {
-		Db *sql.DB
+		Masters repositories.MasterRepository
+		Shares  repositories.MasterShareRepository
+		Users   repositories.UserRepository
 	}
 )
 
-// Creates a new master controller, which handles interaction with the database.
-func NewMasterController(db *sql.DB) *MasterController {
+// Creates a new master controller, which handles interaction with the master storage.
+func NewMasterController(masters repositories.MasterRepository, shares repositories.MasterShareRepository, users repositories.UserRepository) *MasterController {
 	return &MasterController{
-		Db: db,
+		Masters: masters,
+		Shares:  shares,
+		Users:   users,
 	}
 }
 
-// Requests all masters stored in the database.
+// Requests all masters the user owns or has been granted access to. Admins
+// receive all masters stored in the database.
 func (mc MasterController) GetMasters(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	res := models.NewJsonResponse(w)
+	claims, _ := middlewares.ClaimsFromContext(r.Context())
+
+	// Fetch the stored master endpoints.
 	var masters []models.Master
+	var err error
 
-	// Send the select query to the database to fetch stored master endpoints.
-	query, err := mc.Db.Query("SELECT id, name, host, port FROM masters")
-	defer query.Close()
+	if claims.Role.Includes(models.RoleAdmin) {
+		masters, err = mc.Masters.List()
+	} else {
+		masters, err = mc.Masters.ListAccessible(claims.UserId)
+	}
 
 	if err != nil {
 		res.Code = 400
@@ -39,23 +52,6 @@ func (mc MasterController) GetMasters(w http.ResponseWriter, r *http.Request, p
 		return
 	}
 
-	// Fill the list of masters by iterating over all requested rows.
-	for query.Next() {
-		// Create and fill a new master object.
-		var master models.Master
-		err := query.Scan(&master.Id, &master.Name, &master.Host, &master.Port)
-
-		if err != nil {
-			res.Code = 400
-			res.Content = "Internal error"
-			res.Send()
-			return
-		}
-
-		// Append the object to the array.
-		masters = append(masters, master)
-	}
-
 	// Everything was successfull.
 	res.Code = 200
 	res.Content = masters
@@ -65,15 +61,14 @@ func (mc MasterController) GetMasters(w http.ResponseWriter, r *http.Request, p
 // Requests a specific master identified by an id.
 func (mc MasterController) GetMaster(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	res := models.NewJsonResponse(w)
-	master := models.Master{}
 
 	// Request the master endpoint using the given id from the database.
-	err := mc.Db.QueryRow(
-		"SELECT id, name, host, port FROM masters WHERE id = ?",
-		p.ByName("id"),
-	).Scan(
-		&master.Id, &master.Name, &master.Host, &master.Port,
-	)
+	id, err := strconv.Atoi(p.ByName("id"))
+	var master models.Master
+
+	if err == nil {
+		master, err = mc.accessibleMaster(r, id, models.AccessRead)
+	}
 
 	if err != nil {
 		res.Code = 400
@@ -104,11 +99,20 @@ func (mc MasterController) CreateMaster(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
-	// Store the master object into the database.
-	_, err = mc.Db.Query(
-		"INSERT INTO masters (name, host, port) VALUES (?, ?, ?)",
-		m.Name, m.Host, m.Port,
-	)
+	// Masters without owner would only be accessible to admins, so identities
+	// without user, e.g. of client certificates, cannot create them.
+	claims, _ := middlewares.ClaimsFromContext(r.Context())
+
+	if claims.UserId == 0 {
+		res.Code = 403
+		res.Content = "Masters can only be created by users"
+		res.Send()
+		return
+	}
+
+	// Store the master object into the database, owned by the creating user.
+	m.OwnerId = claims.UserId
+	err = mc.Masters.Create(&m)
 
 	if err != nil {
 		res.Code = 400
@@ -140,14 +144,17 @@ func (mc MasterController) UpdateMaster(w http.ResponseWriter, r *http.Request,
 	}
 
 	// Update the master object inside the database using the decoded object.
-  queryRes, err := mc.Db.Exec(
-		"UPDATE masters SET name = ?, host = ?, port = ? WHERE id = ?",
-		m.Name, m.Host, m.Port, p.ByName("id"),
-	)
+	m.Id, err = strconv.Atoi(p.ByName("id"))
 
-  affectedRows, _ := queryRes.RowsAffected()
+	if err == nil {
+		_, err = mc.accessibleMaster(r, m.Id, models.AccessWrite)
+	}
+
+	if err == nil {
+		err = mc.Masters.Update(m)
+	}
 
-	if err != nil || affectedRows == 0 {
+	if err != nil {
 		res.Code = 400
 		res.Content = "Could not update master"
 		res.Send()
@@ -165,14 +172,17 @@ func (mc MasterController) DeleteMaster(w http.ResponseWriter, r *http.Request,
 	res := models.NewJsonResponse(w)
 
 	// Remove the master object from the database using the id.
-  queryRes, err := mc.Db.Exec(
-		"DELETE FROM masters WHERE id = ?",
-		p.ByName("id"),
-	)
+	id, err := strconv.Atoi(p.ByName("id"))
 
-  n, _ := queryRes.RowsAffected()
+	if err == nil {
+		_, err = mc.accessibleMaster(r, id, models.AccessOwner)
+	}
+
+	if err == nil {
+		err = mc.Masters.Delete(id)
+	}
 
-	if err != nil || n == 0 {
+	if err != nil {
 		res.Code = 400
 		res.Content = fmt.Sprintf("Could not delete master with id `%s`", p.ByName("id"))
 		res.Send()
@@ -184,3 +194,145 @@ func (mc MasterController) DeleteMaster(w http.ResponseWriter, r *http.Request,
 	res.Content = "Success"
 	res.Send()
 }
+
+// Returns the users the master has been shared with. Only owners may see them.
+func (mc MasterController) GetShares(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+
+	id, err := strconv.Atoi(p.ByName("id"))
+	var shares []models.MasterShare
+
+	if err == nil {
+		_, err = mc.accessibleMaster(r, id, models.AccessOwner)
+	}
+
+	if err == nil {
+		shares, err = mc.Shares.ListShares(id)
+	}
+
+	if err != nil {
+		res.Code = 400
+		res.Content = fmt.Sprintf("Could not find master with id `%s`", p.ByName("id"))
+		res.Send()
+		return
+	}
+
+	// Everything went fine.
+	res.Code = 200
+	res.Content = shares
+	res.Send()
+}
+
+// Shares the master with another user at read or write level, or changes the
+// level of an existing share. Only owners may share masters.
+func (mc MasterController) ShareMaster(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+	decoder := json.NewDecoder(r.Body)
+
+	// Decode the share from the JSON body.
+	var request models.ShareRequest
+	err := decoder.Decode(&request)
+
+	if err != nil || !request.Access.Shareable() {
+		res.Code = 400
+		res.Content = "Could not parse json body. The access must be read or write."
+		res.Send()
+		return
+	}
+
+	id, err := strconv.Atoi(p.ByName("id"))
+	var master models.Master
+
+	if err == nil {
+		master, err = mc.accessibleMaster(r, id, models.AccessOwner)
+	}
+
+	if err != nil {
+		res.Code = 400
+		res.Content = fmt.Sprintf("Could not find master with id `%s`", p.ByName("id"))
+		res.Send()
+		return
+	}
+
+	user, err := mc.Users.FindByUsername(request.UserName)
+
+	if err != nil || user.Id == master.OwnerId {
+		res.Code = 400
+		res.Content = fmt.Sprintf("Could not share master with user `%s`", request.UserName)
+		res.Send()
+		return
+	}
+
+	err = mc.Shares.Share(models.MasterShare{MasterId: id, UserId: user.Id, Access: request.Access})
+
+	if err != nil {
+		res.Code = 400
+		res.Content = "Could not share master"
+		res.Send()
+		return
+	}
+
+	// Everything went fine.
+	res.Code = 200
+	res.Content = "Success"
+	res.Send()
+}
+
+// Revokes the access of a user to a shared master.
+func (mc MasterController) UnshareMaster(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+
+	id, err := strconv.Atoi(p.ByName("id"))
+	var userId int
+
+	if err == nil {
+		userId, err = strconv.Atoi(p.ByName("userId"))
+	}
+
+	if err == nil {
+		_, err = mc.accessibleMaster(r, id, models.AccessOwner)
+	}
+
+	if err == nil {
+		err = mc.Shares.Unshare(id, userId)
+	}
+
+	if err != nil {
+		res.Code = 400
+		res.Content = fmt.Sprintf("Could not find share of master `%s` with user `%s`", p.ByName("id"), p.ByName("userId"))
+		res.Send()
+		return
+	}
+
+	// Everything went fine.
+	res.Code = 200
+	res.Content = "Success"
+	res.Send()
+}
+
+// Returns the master if the user of the request has at least the given access
+// to it. Otherwise ErrNotFound is returned, so foreign masters stay hidden.
+func (mc MasterController) accessibleMaster(r *http.Request, id int, required models.Access) (models.Master, error) {
+	master, err := mc.Masters.Get(id)
+	if err != nil {
+		return master, err
+	}
+
+	claims, _ := middlewares.ClaimsFromContext(r.Context())
+	access := models.AccessNone
+
+	if claims.Role.Includes(models.RoleAdmin) || (master.OwnerId != 0 && master.OwnerId == claims.UserId) {
+		access = models.AccessOwner
+	} else if claims.UserId != 0 {
+		access, err = mc.Shares.Access(id, claims.UserId)
+		if err != nil && err != repositories.ErrNotFound {
+			return master, err
+		}
+	}
+
+	if !access.Includes(required) {
+		return master, repositories.ErrNotFound
+	}
+
+	return master, nil
+}
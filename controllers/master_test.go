@@ -1,513 +1,279 @@
-package controllers
+package controllers_test
 
 import (
-	"bytes"
-	"database/sql"
-	"encoding/json"
 	"fmt"
-	"net/http"
-	"os"
 	"testing"
+	"time"
 
-	_ "github.com/go-sql-driver/mysql"
-	"github.com/kluddizz/maintenance-rest-service/config"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/kluddizz/maintenance-rest-service/app/apptest"
+	"github.com/kluddizz/maintenance-rest-service/auth"
 	"github.com/kluddizz/maintenance-rest-service/models"
 	"github.com/kluddizz/maintenance-rest-service/utils"
-	"golang.org/x/crypto/bcrypt"
 )
 
 var user = models.User{
-  UserName: "testuser",
-  Password: "testuserpw",
+	UserName: "testuser",
+	Password: "testuserpw",
 }
 
 var master = models.Master{
-  Name: "Test Master",
-  Host: "127.0.0.1",
-  Port: 5050,
+	Name: "Test Master",
+	Host: "127.0.0.1",
+	Port: 5050,
 }
 
-var db *sql.DB
-var token string
-
-func TestMain(m *testing.M) {
-  BeforeAll()
-  code := m.Run()
-  AfterAll()
-  os.Exit(code)
+// Starts the service and registers the test user, who owns the test master.
+func setup(t *testing.T) *apptest.Harness {
+	h := apptest.New(t)
+	user.Id = h.CreateUserWithRole(user.UserName, user.Password, models.RoleOperator).Id
+	master.OwnerId = user.Id
+	return h
 }
 
-func BeforeAll() {
-  // Read the database config
-  dbConfig, err := config.ReadDatabaseConfig("../database.json")
-
-  if err != nil {
-    panic(err.Error())
-  }
-
-  // Try to open a mysql connection
-  db, err = sql.Open("mysql", dbConfig.DataSourceName())
-
-  if err != nil {
-    panic(err.Error())
-  }
-
-  // Make sure the database is clean before starting tests.
-  db.Exec("DELETE FROM masters")
-  db.Exec("DELETE FROM users")
-
-  // Insert the test user to be able to generate valid tokens.
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
-  db.Exec("INSERT INTO users (username, password) VALUES (?, ?)", user.UserName, hashedPassword)
-
-  // Receive token for user.
-  jsonBody, _ := json.Marshal(models.LoginCredentials{UserName: user.UserName, Password: user.Password})
-  resp, _ := http.DefaultClient.Post("http://localhost:3000/login", "application/json", bytes.NewBuffer(jsonBody))
-
-  var response models.JsonResponse
-  json.NewDecoder(resp.Body).Decode(&response)
-  token = response.Content.(string)
+// Test if the route is able to create new masters.
+func TestCreateMaster(t *testing.T) {
+	h := setup(t)
+
+	res, _ := h.DoAs(user.UserName, "POST", "/masters", master)
+
+	// Read out the inserted (?) master for validation.
+	masters, err := h.Masters.List()
+	if err != nil || len(masters) != 1 {
+		t.Fatalf("Expected one stored master but received %v (%v)", masters, err)
+	}
+
+	// Check if the status code is the expected one.
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	// Check if the inserted master had the right values.
+	masterDb := masters[0]
+	if masterDb.Name != master.Name ||
+		masterDb.Host != master.Host ||
+		masterDb.Port != master.Port {
+		t.Errorf("Masters are not equal. Got %+v; want %+v", masterDb, master)
+	}
 }
 
-func AfterAll() {
-  // Remove all generated masters from the database.
-  db.Exec("DELETE FROM masters")
-  db.Exec("DELETE FROM users")
+// Test if the route rejects masters with names that are already taken.
+func TestCreateMasterFail(t *testing.T) {
+	h := setup(t)
+	existing := master
+	h.Masters.Create(&existing)
 
-  // Close the database connection.
-  db.Close()
+	res, _ := h.DoAs(user.UserName, "POST", "/masters", master)
+
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
 }
 
-// Test if the route is able to create new masters.
-func TestCreateMaster(t *testing.T) {
-  // Create json string from master object
-  jsonStr, err := json.Marshal(master)
-  if err != nil {
-    t.Fatal("Json marshalling failed")
-  }
-
-  // Setup new POST request on /masters
-  req, err := http.NewRequest("POST", "http://localhost:3000/masters", bytes.NewBuffer([]byte(jsonStr)))
-  req.Header.Set("Content-Type", "application/json")
-
-  if err != nil {
-    t.Fatal(err.Error())
-  }
-
-  // Send the request
-  req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-  client := &http.Client{}
-  res, err := client.Do(req)
-
-  if err != nil {
-    t.Fatal(err)
-  }
-
-  defer res.Body.Close()
-
-  // Read out the inserted (?) master inside the database for validation later.
-  var masterDb models.Master
-  err = db.QueryRow(
-    "SELECT id, name, host, port FROM masters WHERE name = ?",
-    master.Name,
-  ).Scan(
-    &masterDb.Id, &masterDb.Name, &masterDb.Host, &masterDb.Port,
-  )
-
-  if err != nil {
-    t.Fatalf("Error in the query: %s", err.Error())
-  }
-
-  // Cleanup the database.
-  db.Query("DELETE FROM masters")
-
-  // Check if the status code is the expected one.
-  if res.StatusCode != 200 {
-    t.Errorf("Expected status code to be %d but received %d", 200, res.StatusCode)
-  }
-
-  // Check if the inserted master had the right values.
-  if masterDb.Name != master.Name ||
-     masterDb.Host != master.Host ||
-     masterDb.Port != master.Port {
-    t.Errorf("Masters are not equal. Got %+v; want %+v", masterDb, master)
-  }
+// Test if identities without user, e.g. of client certificates, cannot create
+// masters nobody but admins could access.
+func TestCreateMasterWithoutUser(t *testing.T) {
+	h := setup(t)
+
+	token, _ := h.Keys.Sign(models.CustomClaims{
+		UserName: "client",
+		Role:     models.RoleOperator,
+		StandardClaims: jwt.StandardClaims{
+			Audience:  auth.AccessAudience,
+			Issuer:    auth.Issuer,
+			ExpiresAt: time.Now().Add(time.Minute).Unix(),
+		},
+	})
+
+	res, _ := h.Do("POST", "/masters", master, token)
+	if res.StatusCode != 403 {
+		t.Errorf("Expected status code to be %d but received %d", 403, res.StatusCode)
+	}
+
+	if masters, _ := h.Masters.List(); len(masters) != 0 {
+		t.Errorf("Expected no master to be created but received %v", masters)
+	}
 }
 
 // Test if the route is able to delete a valid master from the database and
 // returns a success code.
 func TestDeleteMaster(t *testing.T) {
-  // Insert a new master directly into the dabase.
-  queryRes, err := db.Exec(
-    "INSERT INTO masters (name, host, port) VALUES (?, ?, ?)",
-    master.Name, master.Host, master.Port,
-  )
-
-  if err != nil {
-    t.Fatalf("Could not insert test master: %s", err.Error())
-  }
-
-  // Read out the created id for the master.
-  insertedId, _ := queryRes.LastInsertId()
-
-  // Prepare the DELETE request on /masters/:id
-  req, err := http.NewRequest(
-    "DELETE",
-    fmt.Sprintf("http://localhost:3000/masters/%d", insertedId),
-    nil,
-  )
-
-  if err != nil {
-    t.Fatal(err.Error())
-  }
-
-  // Send the request
-  req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-  client := &http.Client{}
-  res, err := client.Do(req)
-
-  if err != nil {
-    t.Fatal(err.Error())
-  }
-
-  defer res.Body.Close()
-
-  // Check wheter the master is still present inside the database.
-  var masterExists bool
-  err = db.QueryRow(
-    "SELECT EXISTS(SELECT 1 FROM masters WHERE id = ?)",
-    insertedId,
-  ).Scan(&masterExists)
-
-  if err != nil {
-    t.Fatal(err.Error())
-  }
-
-  // Cleanup the database.
-  db.Query("DELETE FROM masters")
-
-  // Check the expected status code.
-  if res.StatusCode != 200 {
-    t.Errorf("Expected status code to be %d but received %d", 200, res.StatusCode)
-  }
-
-  // Check if the master was still present after the DELETE request.
-  if masterExists {
-    t.Errorf("Expected master existance to be %t but received %t", false, masterExists)
-  }
+	h := setup(t)
+
+	// Insert a new master directly into the store.
+	inserted := master
+	if err := h.Masters.Create(&inserted); err != nil {
+		t.Fatalf("Could not insert test master: %s", err.Error())
+	}
+
+	res, _ := h.DoAs(user.UserName, "DELETE", fmt.Sprintf("/masters/%d", inserted.Id), nil)
+
+	// Check the expected status code.
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	// Check if the master was still present after the DELETE request.
+	if _, err := h.Masters.Get(inserted.Id); err == nil {
+		t.Errorf("Expected master existance to be %t but received %t", false, true)
+	}
 }
 
 // Test if the route returns an error code when invalid master ids are provided.
 func TestDeleteMasterFail(t *testing.T) {
-  // Prepare the invalid DELETE request on /masters/:id by assuming the id = -1
-  // doesn't exist.
-  req, err := http.NewRequest(
-    "DELETE",
-    fmt.Sprintf("http://localhost:3000/masters/%d", -1),
-    nil,
-  )
-
-  if err != nil {
-    t.Fatal(err.Error())
-  }
-
-  // Send the invalid request.
-  req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-  client := &http.Client{}
-  res, err := client.Do(req)
-
-  if err != nil {
-    t.Fatal(err.Error())
-  }
-
-  defer res.Body.Close()
-
-  // Check if the status code is really an error.
-  if res.StatusCode != 400 {
-    t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
-  }
+	h := setup(t)
+
+	// Send the invalid request by assuming the id = -1 doesn't exist.
+	res, _ := h.DoAs(user.UserName, "DELETE", fmt.Sprintf("/masters/%d", -1), nil)
+
+	// Check if the status code is really an error.
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
 }
 
 func TestUpdateMaster(t *testing.T) {
-  // Insert a new master directly into the dabase.
-  queryRes, err := db.Exec(
-    "INSERT INTO masters (name, host, port) VALUES (?, ?, ?)",
-    master.Name, master.Host, master.Port,
-  )
-
-  if err != nil {
-    t.Fatalf("Could not insert test master: %s", err.Error())
-  }
-
-  updatedMaster := master
-  updatedMaster.Name = "Updated Master"
-
-  jsonBody, err := json.Marshal(updatedMaster)
-
-  if err != nil {
-    t.Fatalf("Could not parse json: %s", err.Error())
-  }
-
-  // Read out the created id for the master.
-  insertedId, _ := queryRes.LastInsertId()
-
-  // Prepare the PUT request on /masters/:id
-  req, err := http.NewRequest(
-    "PUT",
-    fmt.Sprintf("http://localhost:3000/masters/%d", insertedId),
-    bytes.NewBuffer(jsonBody),
-  )
-
-  if err != nil {
-    t.Fatal(err.Error())
-  }
-
-  // Send the request
-  req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-  client := &http.Client{}
-  res, err := client.Do(req)
-
-  if err != nil {
-    t.Fatal(err.Error())
-  }
-
-  defer res.Body.Close()
-
-  // Read the updated master from the database.
-  var updatedMasterDb models.Master
-  err = db.QueryRow(
-    "SELECT id, name, host, port FROM masters WHERE id = ?",
-    insertedId,
-  ).Scan(
-    &updatedMasterDb.Id, &updatedMasterDb.Name, &updatedMasterDb.Host, &updatedMasterDb.Port,
-  )
-
-  if err != nil {
-    t.Fatalf("Error while selecting updated master: %s", err.Error())
-  }
-
-  // Cleanup the database.
-  db.Query("DELETE FROM masters")
-
-  // Check the expected status code.
-  if res.StatusCode != 200 {
-    t.Errorf("Expected status code to be %d but received %d", 200, res.StatusCode)
-  }
-
-  if updatedMasterDb.Name != updatedMaster.Name ||
-     updatedMasterDb.Host != master.Host ||
-     updatedMasterDb.Port != master.Port {
-    t.Errorf("Expected updated master to be %v but received %v", updatedMaster, updatedMasterDb)
-  }
+	h := setup(t)
+
+	// Insert a new master directly into the store.
+	inserted := master
+	if err := h.Masters.Create(&inserted); err != nil {
+		t.Fatalf("Could not insert test master: %s", err.Error())
+	}
+
+	updatedMaster := master
+	updatedMaster.Name = "Updated Master"
+
+	res, _ := h.DoAs(user.UserName, "PUT", fmt.Sprintf("/masters/%d", inserted.Id), updatedMaster)
+
+	// Read the updated master from the store.
+	updatedMasterDb, err := h.Masters.Get(inserted.Id)
+	if err != nil {
+		t.Fatalf("Error while selecting updated master: %s", err.Error())
+	}
+
+	// Check the expected status code.
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	if updatedMasterDb.Name != updatedMaster.Name ||
+		updatedMasterDb.Host != master.Host ||
+		updatedMasterDb.Port != master.Port {
+		t.Errorf("Expected updated master to be %v but received %v", updatedMaster, updatedMasterDb)
+	}
 }
 
 func TestUpdateMasterFail(t *testing.T) {
-  // Insert a new master directly into the dabase.
-  queryRes, err := db.Exec(
-    "INSERT INTO masters (name, host, port) VALUES (?, ?, ?)",
-    master.Name, master.Host, master.Port,
-  )
-
-  if err != nil {
-    t.Fatalf("Could not insert test master: %s", err.Error())
-  }
-
-  updatedMaster := master
-  updatedMaster.Name = "Updated Master"
-
-  jsonBody, err := json.Marshal(updatedMaster)
-
-  if err != nil {
-    t.Fatalf("Could not parse json: %s", err.Error())
-  }
-
-  // Read out the created id for the master.
-  insertedId, _ := queryRes.LastInsertId()
-
-  // Prepare the PUT request on /masters/:id
-  req, err := http.NewRequest(
-    "PUT",
-    fmt.Sprintf("http://localhost:3000/masters/%d", -1),
-    bytes.NewBuffer(jsonBody),
-  )
-
-  if err != nil {
-    t.Fatal(err.Error())
-  }
-
-  // Send the request
-  req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-  client := &http.Client{}
-  res, err := client.Do(req)
-
-  if err != nil {
-    t.Fatal(err.Error())
-  }
-
-  defer res.Body.Close()
-
-  // Read the updated master from the database.
-  var updatedMasterDb models.Master
-  err = db.QueryRow(
-    "SELECT id, name, host, port FROM masters WHERE id = ?",
-    insertedId,
-  ).Scan(
-    &updatedMasterDb.Id, &updatedMasterDb.Name, &updatedMasterDb.Host, &updatedMasterDb.Port,
-  )
-
-  if err != nil {
-    t.Fatalf("Error while selecting updated master: %s", err.Error())
-  }
-
-  // Cleanup the database.
-  db.Query("DELETE FROM masters")
-
-  // Check the expected status code.
-  if res.StatusCode != 400 {
-    t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
-  }
-
-  if updatedMasterDb.Name != master.Name ||
-     updatedMasterDb.Host != master.Host ||
-     updatedMasterDb.Port != master.Port {
-    t.Errorf("Expected updated master to be %v but received %v", updatedMaster, updatedMasterDb)
-  }
+	h := setup(t)
+
+	// Insert a new master directly into the store.
+	inserted := master
+	if err := h.Masters.Create(&inserted); err != nil {
+		t.Fatalf("Could not insert test master: %s", err.Error())
+	}
+
+	updatedMaster := master
+	updatedMaster.Name = "Updated Master"
+
+	res, _ := h.DoAs(user.UserName, "PUT", fmt.Sprintf("/masters/%d", -1), updatedMaster)
+
+	// Read the stored master, which must not have changed.
+	updatedMasterDb, err := h.Masters.Get(inserted.Id)
+	if err != nil {
+		t.Fatalf("Error while selecting updated master: %s", err.Error())
+	}
+
+	// Check the expected status code.
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
+
+	if updatedMasterDb.Name != master.Name ||
+		updatedMasterDb.Host != master.Host ||
+		updatedMasterDb.Port != master.Port {
+		t.Errorf("Expected updated master to be %v but received %v", master, updatedMasterDb)
+	}
 }
 
 // Test if we get all the masters stored in the database.
 func TestGetMasters(t *testing.T) {
-  numberMasters := 5
-
-  // Insert some masters into the database.
-  for i := 0; i < numberMasters; i++ {
-    db.Exec(
-      "INSERT INTO masters (name, host, port) VALUES (?, ?, ?)",
-      fmt.Sprintf("Test Master %d", i), master.Host, master.Port,
-    )
-  }
-
-  // Prepare request.
-  req, err := http.NewRequest(
-    "GET",
-    "http://localhost:3000/masters",
-    nil,
-  )
-
-  if err != nil {
-    t.Fatalf("Cannot create HTTP request: %s", err.Error())
-  }
-
-  // Send request.
-  req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-  client := &http.Client{}
-  res, err := client.Do(req)
-
-  if err != nil {
-    t.Fatalf("Error while sending request: %s", err.Error())
-  }
-
-  defer res.Body.Close()
-  
-  // Parse response.
-  var response models.JsonResponse
-  decoder := json.NewDecoder(res.Body)
-  err = decoder.Decode(&response)
-
-  if err != nil {
-    t.Fatalf("Could not parse json: %s", err.Error())
-  }
-
-  // Cleanup database.
-  db.Exec("DELETE FROM masters")
-
-  // Check if request was successful.
-  if res.StatusCode != 200 {
-    t.Fatalf("Expected status code to be %d but received %d", 200, res.StatusCode)
-  }
-
-  // Check if the amount of fetched masters.
-  masters := response.Content.([]interface{})
-  if len(masters) != numberMasters {
-    t.Errorf("Expected number of masters to be %d but received %d", numberMasters, len(masters))
-  }
+	h := setup(t)
+	numberMasters := 5
+
+	// Insert some masters into the store.
+	for i := 0; i < numberMasters; i++ {
+		h.Masters.Create(&models.Master{
+			Name:    fmt.Sprintf("Test Master %d", i),
+			Host:    master.Host,
+			Port:    master.Port,
+			OwnerId: master.OwnerId,
+		})
+	}
+
+	res, response := h.DoAs(user.UserName, "GET", "/masters", nil)
+
+	// Check if request was successful.
+	if res.StatusCode != 200 {
+		t.Fatalf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	// Check if the amount of fetched masters.
+	masters := response.Content.([]interface{})
+	if len(masters) != numberMasters {
+		t.Errorf("Expected number of masters to be %d but received %d", numberMasters, len(masters))
+	}
 }
 
 // Test if we can fetch masters by id.
 func TestGetSingleMaster(t *testing.T) {
-  // Insert one master into the database.
-  queryRes, _ := db.Exec(
-    "INSERT INTO masters (name, host, port) VALUES (?, ?, ?)",
-    master.Name, master.Host, master.Port,
-  )
-
-  // Get the auto generated master id.
-  insertedId, _ := queryRes.LastInsertId()
-
-  // Create new GET request.
-  req, _ := http.NewRequest(
-    "GET",
-    fmt.Sprintf("http://localhost:3000/masters/%d", insertedId),
-    nil,
-  )
-
-  // Send request.
-  req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-  client := &http.Client{}
-  res, err := client.Do(req)
-
-  if err != nil {
-    t.Fatalf("Cannot send request: %s", err.Error())
-  }
-
-  defer res.Body.Close()
-
-  // Parse the response.
-  var response models.JsonResponse
-  decoder := json.NewDecoder(res.Body)
-  decoder.Decode(&response)
-
-  var masterResponse models.Master
-  utils.MapToStruct(response.Content, &masterResponse)
-
-  // Cleanup database.
-  db.Exec("DELETE FROM masters")
-
-  // Check status code.
-  if res.StatusCode != 200 {
-    t.Errorf("Expected status code to be %d but received %d", 200, res.StatusCode)
-  }
-
-  // Check fetched master.
-  if masterResponse.Host != master.Host ||
-     masterResponse.Name != master.Name ||
-     masterResponse.Port != master.Port {
-    t.Errorf("Expected master to be %v but received %v", master, masterResponse)
-  }
+	h := setup(t)
+
+	// Insert one master into the store.
+	inserted := master
+	h.Masters.Create(&inserted)
+
+	res, response := h.DoAs(user.UserName, "GET", fmt.Sprintf("/masters/%d", inserted.Id), nil)
+
+	var masterResponse models.Master
+	utils.MapToStruct(response.Content, &masterResponse)
+
+	// Check status code.
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	// Check fetched master.
+	if masterResponse.Host != master.Host ||
+		masterResponse.Name != master.Name ||
+		masterResponse.Port != master.Port {
+		t.Errorf("Expected master to be %v but received %v", master, masterResponse)
+	}
 }
 
 // Test if we cannot fetch masters by invalid ids.
 func TestGetSingleMasterFail(t *testing.T) {
-  // Create new GET request.
-  req, _ := http.NewRequest(
-    "GET",
-    fmt.Sprintf("http://localhost:3000/masters/%d", -1),
-    nil,
-  )
-
-  // Send request.
-  req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-  client := &http.Client{}
-  res, err := client.Do(req)
-
-  if err != nil {
-    t.Fatalf("Cannot send request: %s", err.Error())
-  }
-
-  defer res.Body.Close()
-
-  // Check status code.
-  if res.StatusCode != 400 {
-    t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
-  }
+	h := setup(t)
+
+	res, _ := h.DoAs(user.UserName, "GET", fmt.Sprintf("/masters/%d", -1), nil)
+
+	// Check status code.
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
 }
 
+// Test if the master routes reject requests without a valid token.
+func TestMastersRequireToken(t *testing.T) {
+	h := setup(t)
+
+	res, _ := h.Do("GET", "/masters", nil, "")
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
+
+	res, _ = h.Do("GET", "/masters", nil, "invalid")
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
+}
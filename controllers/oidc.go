@@ -0,0 +1,158 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/kluddizz/maintenance-rest-service/auth"
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+type (
+	OidcController struct {
+		Oidc   *auth.OidcService
+		Tokens *auth.TokenService
+
+		// Optional second factor, required from users who enabled it.
+		Totp *auth.TotpService
+	}
+)
+
+// Name of the cookie keeping the signed state between redirects.
+const oidcStateCookie = "oidc_state"
+
+// Creates a new controller logging users in at an OpenID Connect provider.
+func NewOidcController(oidc *auth.OidcService, tokens *auth.TokenService) *OidcController {
+	return &OidcController{
+		Oidc:   oidc,
+		Tokens: tokens,
+	}
+}
+
+// Redirects the client to the login page of the provider.
+func (oc OidcController) Login(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+
+	url, state, err := oc.Oidc.Start(r.Context())
+
+	if err != nil {
+		log.Printf("Error while starting OpenID Connect login: %s", err.Error())
+
+		res.Code = 400
+		res.Content = "Identity provider not available"
+		res.Send()
+		return
+	}
+
+	setStateCookie(w, r, state, int(oc.Oidc.StateLifetime.Seconds()))
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+// Completes the login with the authorization code of the provider and sends
+// an access and a refresh token if succeeded.
+func (oc OidcController) Callback(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+	query := r.URL.Query()
+
+	// The state can only be used once.
+	setStateCookie(w, r, "", -1)
+
+	if query.Get("error") != "" {
+		res.Code = 401
+		res.Content = "Login failed at identity provider"
+		res.Send()
+		return
+	}
+
+	cookie, err := r.Cookie(oidcStateCookie)
+
+	var user models.UserDb
+	if err == nil {
+		user, err = oc.Oidc.Finish(r.Context(), cookie.Value, query.Get("state"), query.Get("code"))
+	} else {
+		err = auth.ErrInvalidOidcState
+	}
+
+	if err == auth.ErrInvalidOidcState {
+		res.Code = 400
+		res.Content = "Invalid login state"
+		res.Send()
+		return
+	}
+
+	if err == nil && user.Disabled {
+		res.Code = 403
+		res.Content = "User is disabled"
+		res.Send()
+		return
+	}
+
+	if err == auth.ErrLoginDenied {
+		res.Code = 403
+		res.Content = "No role has been assigned to the user"
+		res.Send()
+		return
+	}
+
+	if err != nil {
+		log.Printf("Error while completing OpenID Connect login: %s", err.Error())
+
+		res.Code = 401
+		res.Content = "Login failed at identity provider"
+		res.Send()
+		return
+	}
+
+	// Users with a second factor have to complete the login with a code
+	if oc.Totp != nil {
+		challenge, err := loginChallenge(oc.Totp, user)
+
+		if err != nil {
+			log.Printf("Error while creating login challenge: %s", err.Error())
+
+			res.Code = 400
+			res.Content = "Internal error"
+			res.Send()
+			return
+		}
+
+		if challenge != nil {
+			res.Code = 200
+			res.Content = challenge
+			res.Send()
+			return
+		}
+	}
+
+	// Create tokens
+	tokens, err := oc.Tokens.Issue(user)
+
+	if err != nil {
+		log.Printf("Error while issuing tokens: %s", err.Error())
+
+		res.Code = 400
+		res.Content = "Internal error"
+		res.Send()
+		return
+	}
+
+	// Everything went fine.
+	res.Code = 200
+	res.Content = tokens
+	res.Response.Header().Set("Authorization", "Bearer "+tokens.AccessToken)
+	res.Send()
+}
+
+// Sets the state cookie, which is removed if max age is negative.
+func setStateCookie(w http.ResponseWriter, r *http.Request, state string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/oidc",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
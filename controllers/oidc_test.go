@@ -0,0 +1,170 @@
+package controllers_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/kluddizz/maintenance-rest-service/app/apptest"
+	"github.com/kluddizz/maintenance-rest-service/auth"
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+// Test if users of a provider are created on their first login and receive
+// the role of their groups on every login.
+func TestOidcLogin(t *testing.T) {
+	idp := apptest.NewStubIdp(t)
+	idp.Subject = "1234"
+	idp.Claims["preferred_username"] = "alice"
+	idp.Claims["email"] = "alice@example.com"
+	idp.Claims["email_verified"] = true
+	idp.Claims["groups"] = []string{"staff", "maintainers"}
+
+	h := apptest.New(t, apptest.WithOidc(idp, func(oidc *auth.OidcService) {
+		oidc.Roles["maintainers"] = models.RoleOperator
+	}))
+
+	// A local user with the same name keeps its account.
+	h.CreateUser("alice", "alicepw")
+
+	res, response := h.OidcLogin()
+	if res.StatusCode != 200 {
+		t.Fatalf("Expected status code to be %d but received %d: %v", 200, res.StatusCode, response.Content)
+	}
+
+	var tokens models.TokenPair
+	h.Decode(response.Content, &tokens)
+
+	identity, err := h.Identities.Find(idp.Issuer(), idp.Subject)
+	if err != nil {
+		t.Fatalf("Expected identity to be linked but received %s", err.Error())
+	}
+
+	user, _ := h.Users.FindById(identity.UserId)
+	if user.UserName == "alice" || user.Role != models.RoleOperator || !user.EmailVerified {
+		t.Errorf("Expected provisioned operator with verified email but received %v", user)
+	}
+
+	res, _ = h.Do("POST", "/masters", models.Master{Name: "Provisioned"}, tokens.AccessToken)
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	// Users without mapped group get the default role on their next login.
+	idp.Claims["groups"] = []string{"staff"}
+	h.OidcLogin()
+
+	if user, _ = h.Users.FindById(identity.UserId); user.Role != models.RoleViewer {
+		t.Errorf("Expected role to be %s but received %s", models.RoleViewer, user.Role)
+	}
+
+	res, _ = h.Do("POST", "/masters", models.Master{Name: "Revoked"}, tokens.AccessToken)
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
+
+	// Provisioned users cannot login with a password.
+	res, _ = h.Do("POST", "/login", models.LoginCredentials{UserName: user.UserName, Password: ""}, "")
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
+}
+
+// Test if logins are denied without mapped group or with a forged state.
+func TestOidcLoginDenied(t *testing.T) {
+	idp := apptest.NewStubIdp(t)
+	h := apptest.New(t, apptest.WithOidc(idp, func(oidc *auth.OidcService) {
+		oidc.DefaultRole = ""
+	}))
+
+	res, _ := h.OidcLogin()
+	if res.StatusCode != 403 {
+		t.Errorf("Expected status code to be %d but received %d", 403, res.StatusCode)
+	}
+
+	res, _ = h.Do("GET", "/oidc/callback?code=forged&state=forged", nil, "")
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
+
+	if _, err := h.Identities.Find(idp.Issuer(), idp.Subject); err == nil {
+		t.Errorf("Expected no user to be provisioned")
+	}
+}
+
+// Test if provisioned users cannot set a local password to login with.
+func TestOidcUserPassword(t *testing.T) {
+	idp := apptest.NewStubIdp(t)
+	idp.Claims["email"] = "alice@example.com"
+	idp.Claims["email_verified"] = true
+	h := apptest.New(t, apptest.WithOidc(idp, nil))
+
+	var tokens models.TokenPair
+	_, response := h.OidcLogin()
+	h.Decode(response.Content, &tokens)
+	identity, _ := h.Identities.Find(idp.Issuer(), idp.Subject)
+
+	res, _ := h.Do("POST", "/password/reset", models.PasswordResetRequest{Email: "alice@example.com"}, "")
+	if res.StatusCode != 200 || len(h.Mailbox.Messages) != 0 {
+		t.Errorf("Expected no reset mail but received %d %v", res.StatusCode, h.Mailbox.Messages)
+	}
+
+	// Tokens for the empty password hash are rejected as well.
+	token, _ := h.Keys.Sign(models.AccountClaims{
+		UserId:   identity.UserId,
+		Password: auth.HashToken("")[:16],
+		StandardClaims: jwt.StandardClaims{
+			Audience:  auth.ResetAudience,
+			Issuer:    auth.Issuer,
+			ExpiresAt: time.Now().Add(time.Hour).Unix(),
+		},
+	})
+
+	res, _ = h.Do("POST", "/password/reset/confirm", models.PasswordResetConfirmation{Token: token, Password: "localpassword"}, "")
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
+
+	res, _ = h.Do("PUT", "/users/me/password", models.PasswordChange{NewPassword: "localpassword"}, tokens.AccessToken)
+	if res.StatusCode != 403 {
+		t.Errorf("Expected status code to be %d but received %d", 403, res.StatusCode)
+	}
+
+	// Local users linked to a provider are provisioned users as well.
+	local := h.CreateUser("linked", "linkedpw")
+	h.Identities.Create(models.Identity{Issuer: idp.Issuer(), Subject: "5678", UserId: local.Id})
+
+	res, _ = h.DoAs("linked", "PUT", "/users/me/password", models.PasswordChange{CurrentPassword: "linkedpw", NewPassword: "localpassword"})
+	if res.StatusCode != 403 {
+		t.Errorf("Expected status code to be %d but received %d", 403, res.StatusCode)
+	}
+}
+
+// Test if subjects whose user has been deleted are provisioned again instead
+// of failing to login.
+func TestOidcLoginDeletedUser(t *testing.T) {
+	idp := apptest.NewStubIdp(t)
+	h := apptest.New(t, apptest.WithOidc(idp, nil))
+
+	h.OidcLogin()
+	identity, _ := h.Identities.Find(idp.Issuer(), idp.Subject)
+
+	// The link is left behind, as by databases ignoring the foreign key.
+	if err := h.Users.Delete(identity.UserId); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	res, response := h.OidcLogin()
+	if res.StatusCode != 200 {
+		t.Fatalf("Expected status code to be %d but received %d: %v", 200, res.StatusCode, response.Content)
+	}
+
+	relinked, err := h.Identities.Find(idp.Issuer(), idp.Subject)
+	if err != nil || relinked.UserId == identity.UserId {
+		t.Errorf("Expected subject to be linked to a new user but received %v", relinked)
+	}
+
+	if _, err := h.Users.FindById(relinked.UserId); err != nil {
+		t.Errorf("Expected provisioned user but received %s", err.Error())
+	}
+}
@@ -0,0 +1,229 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/mail"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/kluddizz/maintenance-rest-service/auth"
+	"github.com/kluddizz/maintenance-rest-service/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Sends the profile of the current user.
+func (uc UserController) GetProfile(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+
+	user, ok := uc.currentUser(res, r)
+	if !ok {
+		return
+	}
+
+	// Everything went fine.
+	res.Code = 200
+	res.Content = user.Profile()
+	res.Send()
+}
+
+// Changes first name, last name or email of the current user. A changed
+// email has to be verified again.
+func (uc UserController) UpdateProfile(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+	var update models.ProfileUpdate
+
+	user, ok := uc.currentUser(res, r)
+	if !ok {
+		return
+	}
+
+	// Read changed fields from request body
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&update)
+
+	if err != nil {
+		res.Code = 400
+		res.Content = "Error while decoding json body"
+		res.Send()
+		return
+	}
+
+	profile := models.User{
+		Id:        user.Id,
+		FirstName: user.FirstName.String,
+		LastName:  user.LastName.String,
+		Email:     user.Email.String,
+	}
+
+	if update.FirstName != nil {
+		profile.FirstName = *update.FirstName
+	}
+
+	if update.LastName != nil {
+		profile.LastName = *update.LastName
+	}
+
+	if update.Email != nil {
+		profile.Email = *update.Email
+	}
+
+	if profile.Email != "" {
+		if address, err := mail.ParseAddress(profile.Email); err != nil || address.Address != profile.Email {
+			res.Code = 400
+			res.Content = "Invalid email"
+			res.Send()
+			return
+		}
+	}
+
+	err = uc.Users.UpdateProfile(&profile)
+
+	if err == nil {
+		user, err = uc.Users.FindById(user.Id)
+	}
+
+	if err != nil {
+		log.Printf("Error while updating profile: %s", err.Error())
+
+		res.Code = 400
+		res.Content = "Could not update profile"
+		res.Send()
+		return
+	}
+
+	// The update succeeded even if the mail could not be sent, it can be
+	// requested again.
+	if uc.Accounts != nil && user.Email.String != "" && !user.EmailVerified {
+		if err := uc.Accounts.SendVerification(user); err != nil {
+			log.Printf("Error while sending verification mail: %s", err.Error())
+		}
+	}
+
+	// Everything went fine.
+	res.Code = 200
+	res.Content = user.Profile()
+	res.Send()
+}
+
+// Replaces the password of the current user, who has to send the current
+// password. All tokens of the user are revoked and a new token pair is sent.
+func (uc UserController) ChangePassword(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+	var change models.PasswordChange
+
+	user, ok := uc.currentUser(res, r)
+	if !ok {
+		return
+	}
+
+	// Read current and new password from request body
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&change)
+
+	if err != nil {
+		res.Code = 400
+		res.Content = "Error while decoding json body"
+		res.Send()
+		return
+	}
+
+	if !uc.localPassword(res, user) {
+		return
+	}
+
+	ip := clientAddress(r)
+	if !reserveAttempt(res, uc.Throttle, user.UserName, ip) {
+		return
+	}
+
+	err = bcrypt.CompareHashAndPassword(user.Password, []byte(change.CurrentPassword))
+	completeAttempt(uc.Throttle, user.UserName, ip, err != nil)
+
+	if err != nil {
+		res.Code = 400
+		res.Content = "Wrong current password"
+		res.Send()
+		return
+	}
+
+	if !acceptPassword(res, uc.Passwords, user.UserName, change.NewPassword) {
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(change.NewPassword), bcrypt.DefaultCost)
+
+	if err == nil {
+		err = uc.Users.SetPassword(user.Id, hashedPassword)
+	}
+
+	// Sessions with the old password end
+	if err == nil {
+		err = uc.Tokens.RevokeUser(user.Id)
+	}
+
+	var tokens models.TokenPair
+	if err == nil {
+		tokens, err = uc.Tokens.Issue(user)
+	}
+
+	if err != nil {
+		log.Printf("Error while changing password: %s", err.Error())
+
+		res.Code = 400
+		res.Content = "Could not change password"
+		res.Send()
+		return
+	}
+
+	uc.record(models.AuditPasswordChange, user.UserName, user.UserName, "")
+
+	// Everything went fine.
+	res.Code = 200
+	res.Content = tokens
+	res.Response.Header().Set("Authorization", "Bearer "+tokens.AccessToken)
+	res.Send()
+}
+
+// Returns the stored user of the login token.
+func (uc UserController) currentUser(res *models.JsonResponse, r *http.Request) (models.UserDb, bool) {
+	claims, ok := loggedInUser(res, r)
+	if !ok {
+		return models.UserDb{}, false
+	}
+
+	user, err := uc.Users.FindById(claims.UserId)
+
+	if err != nil {
+		res.Code = 404
+		res.Content = "User not found"
+		res.Send()
+		return user, false
+	}
+
+	return user, true
+}
+
+// Returns whether the user has a local password. Users of identity providers
+// manage their password there, so the error is sent and false returned.
+func (uc UserController) localPassword(res *models.JsonResponse, user models.UserDb) bool {
+	local, err := auth.LocalPassword(uc.Identities, user)
+
+	if err != nil {
+		log.Printf("Error while finding identities: %s", err.Error())
+
+		res.Code = 400
+		res.Content = "Internal error"
+		res.Send()
+		return false
+	}
+
+	if !local {
+		res.Code = 403
+		res.Content = "Password is managed by the identity provider"
+		res.Send()
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,84 @@
+package controllers_test
+
+import (
+	"testing"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+// Test if users read and change their profile, and a changed email has to be
+// verified again.
+func TestProfile(t *testing.T) {
+	h := setup(t)
+	newUser := models.User{UserName: "newuser", Password: "newpassword", FirstName: "New", Email: "new@example.com"}
+	h.Do("POST", "/register", newUser, "")
+	h.Do("POST", "/email/verify", models.VerifyEmailRequest{Token: h.MailToken(newUser.Email)}, "")
+
+	var profile models.UserProfile
+	res, response := h.DoAs(newUser.UserName, "GET", "/users/me", nil)
+	h.Decode(response.Content, &profile)
+
+	if res.StatusCode != 200 || profile.FirstName != newUser.FirstName || !profile.EmailVerified {
+		t.Errorf("Expected verified profile of %s but received %d %v", newUser.UserName, res.StatusCode, profile)
+	}
+
+	lastName, email := "User", "changed@example.com"
+	res, response = h.DoAs(newUser.UserName, "PATCH", "/users/me", models.ProfileUpdate{LastName: &lastName, Email: &email})
+	h.Decode(response.Content, &profile)
+
+	if res.StatusCode != 200 || profile.FirstName != newUser.FirstName || profile.LastName != lastName || profile.EmailVerified {
+		t.Errorf("Expected changed unverified profile but received %d %v", res.StatusCode, profile)
+	}
+
+	if token := h.MailToken(email); token == "" {
+		t.Errorf("Expected verification mail to be sent to %s", email)
+	}
+
+	invalid := "not an email"
+	res, _ = h.DoAs(newUser.UserName, "PATCH", "/users/me", models.ProfileUpdate{Email: &invalid})
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
+
+	// Unknown users and API keys have no profile.
+	res, _ = h.DoAs("unknown", "GET", "/users/me", nil)
+	if res.StatusCode != 403 {
+		t.Errorf("Expected status code to be %d but received %d", 403, res.StatusCode)
+	}
+}
+
+// Test if the password changes only with the current password and ends all
+// other sessions.
+func TestChangePassword(t *testing.T) {
+	h := setup(t)
+	oldTokens := h.Login(user.UserName, user.Password)
+
+	res, _ := h.Do("PUT", "/users/me/password", models.PasswordChange{CurrentPassword: "wrongpw", NewPassword: "changedpw"}, oldTokens.AccessToken)
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
+
+	res, response := h.Do("PUT", "/users/me/password", models.PasswordChange{CurrentPassword: user.Password, NewPassword: "changedpw"}, oldTokens.AccessToken)
+	if res.StatusCode != 200 {
+		t.Fatalf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	var tokens models.TokenPair
+	h.Decode(response.Content, &tokens)
+
+	res, _ = h.Do("GET", "/users/me", nil, oldTokens.AccessToken)
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
+
+	res, _ = h.Do("GET", "/users/me", nil, tokens.AccessToken)
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	h.Login(user.UserName, "changedpw")
+
+	if events, _ := h.Audit.List(1); len(events) != 1 || events[0].Event != models.AuditPasswordChange {
+		t.Errorf("Expected password change to be recorded but received %v", events)
+	}
+}
@@ -0,0 +1,103 @@
+package controllers_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/kluddizz/maintenance-rest-service/auth"
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+// Test if viewers may read masters but not change them.
+func TestViewerPermissions(t *testing.T) {
+	h := setup(t)
+	viewer := h.CreateUser("viewer", "viewerpw")
+	h.DoAs(user.UserName, "POST", "/masters", master)
+
+	// Even write access does not allow viewers to change masters.
+	h.Masters.Share(models.MasterShare{MasterId: 1, UserId: viewer.Id, Access: models.AccessWrite})
+
+	res, _ := h.DoAs("viewer", "GET", "/masters/1", nil)
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	res, _ = h.DoAs("viewer", "PUT", "/masters/1", master)
+	if res.StatusCode != 403 {
+		t.Errorf("Expected status code to be %d but received %d", 403, res.StatusCode)
+	}
+}
+
+// Test if only admins may change roles and if changed roles apply at once.
+func TestSetUserRole(t *testing.T) {
+	h := setup(t)
+	h.CreateUserWithRole("admin", "adminpw", models.RoleAdmin)
+	viewer := h.CreateUser("viewer", "viewerpw")
+	viewerToken := h.Token("viewer")
+	path := fmt.Sprintf("/admin/users/%d/role", viewer.Id)
+
+	res, _ := h.DoAs(user.UserName, "PUT", path, models.RoleRequest{Role: models.RoleAdmin})
+	if res.StatusCode != 403 {
+		t.Errorf("Expected status code to be %d but received %d", 403, res.StatusCode)
+	}
+
+	res, _ = h.DoAs("admin", "PUT", path, models.RoleRequest{Role: "superuser"})
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
+
+	res, _ = h.DoAs("admin", "PUT", path, models.RoleRequest{Role: models.RoleOperator})
+	if res.StatusCode != 200 {
+		t.Fatalf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	if stored, _ := h.Users.FindById(viewer.Id); stored.Role != models.RoleOperator {
+		t.Errorf("Expected role to be %s but received %s", models.RoleOperator, stored.Role)
+	}
+
+	// Tokens carrying the old role are revoked.
+	res, _ = h.Do("GET", "/masters", nil, viewerToken)
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
+}
+
+// Test if admins can neither demote themselves nor the last enabled admin.
+func TestDemoteAdmin(t *testing.T) {
+	h := setup(t)
+	admin := h.CreateUserWithRole("admin", "adminpw", models.RoleAdmin)
+	other := h.CreateUserWithRole("other", "otherpw", models.RoleAdmin)
+	demotion := models.RoleRequest{Role: models.RoleOperator}
+
+	res, response := h.DoAs("admin", "PUT", fmt.Sprintf("/admin/users/%d/role", admin.Id), demotion)
+	if res.StatusCode != 400 || response.Content != "Admins cannot demote themselves" {
+		t.Errorf("Expected status code to be %d but received %d %v", 400, res.StatusCode, response.Content)
+	}
+
+	res, _ = h.DoAs("admin", "PUT", fmt.Sprintf("/admin/users/%d/role", other.Id), demotion)
+	if res.StatusCode != 200 {
+		t.Fatalf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	// Admins of client certificates have no user, so they might demote it.
+	token, _ := h.Keys.Sign(models.CustomClaims{
+		UserName: "client",
+		Role:     models.RoleAdmin,
+		StandardClaims: jwt.StandardClaims{
+			Audience:  auth.AccessAudience,
+			Issuer:    auth.Issuer,
+			ExpiresAt: time.Now().Add(time.Minute).Unix(),
+		},
+	})
+
+	res, response = h.Do("PUT", fmt.Sprintf("/admin/users/%d/role", admin.Id), demotion, token)
+	if res.StatusCode != 400 || response.Content != "The last admin cannot be demoted" {
+		t.Errorf("Expected status code to be %d but received %d %v", 400, res.StatusCode, response.Content)
+	}
+
+	if stored, _ := h.Users.FindById(admin.Id); stored.Role != models.RoleAdmin {
+		t.Errorf("Expected role to be %s but received %s", models.RoleAdmin, stored.Role)
+	}
+}
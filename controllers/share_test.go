@@ -0,0 +1,90 @@
+package controllers_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+// Test if masters are only visible to their owners until they are shared.
+func TestMasterOwnership(t *testing.T) {
+	h := setup(t)
+	h.CreateUserWithRole("other", "otherpw", models.RoleOperator)
+	h.DoAs(user.UserName, "POST", "/masters", master)
+
+	stored, _ := h.Masters.Get(1)
+	if stored.OwnerId != user.Id {
+		t.Errorf("Expected owner to be %d but received %d", user.Id, stored.OwnerId)
+	}
+
+	_, response := h.DoAs("other", "GET", "/masters", nil)
+	if response.Content != nil {
+		t.Errorf("Expected no masters but received %v", response.Content)
+	}
+
+	res, _ := h.DoAs("other", "GET", "/masters/1", nil)
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
+
+	res, _ = h.DoAs("other", "DELETE", "/masters/1", nil)
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
+}
+
+// Test if shared masters can be read or written depending on the access level.
+func TestShareMaster(t *testing.T) {
+	h := setup(t)
+	other := h.CreateUserWithRole("other", "otherpw", models.RoleOperator)
+	h.DoAs(user.UserName, "POST", "/masters", master)
+
+	res, _ := h.DoAs(user.UserName, "PUT", "/masters/1/shares", models.ShareRequest{UserName: "other", Access: models.AccessRead})
+	if res.StatusCode != 200 {
+		t.Fatalf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	res, _ = h.DoAs("other", "GET", "/masters/1", nil)
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	updated := models.Master{Name: "Updated Master", Host: master.Host, Port: master.Port}
+	res, _ = h.DoAs("other", "PUT", "/masters/1", updated)
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
+
+	// Only the owner may manage shares.
+	res, _ = h.DoAs("other", "PUT", "/masters/1/shares", models.ShareRequest{UserName: "other", Access: models.AccessWrite})
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
+
+	h.DoAs(user.UserName, "PUT", "/masters/1/shares", models.ShareRequest{UserName: "other", Access: models.AccessWrite})
+
+	res, _ = h.DoAs("other", "PUT", "/masters/1", updated)
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	_, response := h.DoAs(user.UserName, "GET", "/masters/1/shares", nil)
+
+	var shares []models.MasterShare
+	h.Decode(response.Content, &shares)
+
+	if len(shares) != 1 || shares[0].UserId != other.Id || shares[0].Access != models.AccessWrite {
+		t.Errorf("Expected one write share but received %v", shares)
+	}
+
+	res, _ = h.DoAs(user.UserName, "DELETE", fmt.Sprintf("/masters/1/shares/%d", other.Id), nil)
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	res, _ = h.DoAs("other", "GET", "/masters/1", nil)
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
+}
@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/kluddizz/maintenance-rest-service/auth"
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+// Sends an error if the username or the client address has to wait before
+// the next password may be checked, and otherwise counts the attempt as failed
+// until completeAttempt records its result. A nil throttle allows everything.
+func reserveAttempt(res *models.JsonResponse, throttle *auth.LoginThrottle, username, ip string) bool {
+	if throttle == nil {
+		return true
+	}
+
+	return allow(res, throttle.Reserve(username, ip), "Too many failed login attempts")
+}
+
+// Sends an error if reset mails to the email or requests of the client
+// address have to wait, and otherwise counts the request. A nil throttle
+// allows everything.
+func allowReset(res *models.JsonResponse, throttle *auth.LoginThrottle, email, ip string) bool {
+	if throttle == nil {
+		return true
+	}
+
+	if !allow(res, throttle.CheckReset(email, ip), "Too many password reset requests") {
+		return false
+	}
+
+	if err := throttle.ResetRequested(email, ip); err != nil {
+		log.Printf("Error while recording reset request: %s", err.Error())
+	}
+
+	return true
+}
+
+// Sends the error returned by a throttle check with the given content if the
+// request has to wait.
+func allow(res *models.JsonResponse, err error, content string) bool {
+	if throttled, ok := err.(*auth.ThrottledError); ok {
+		res.Code = 429
+		res.Content = content
+		res.Response.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+		res.Send()
+		return false
+	}
+
+	if err != nil {
+		log.Printf("Error while checking login attempts: %s", err.Error())
+
+		res.Code = 400
+		res.Content = "Internal error"
+		res.Send()
+		return false
+	}
+
+	return true
+}
+
+// Records the result of a password check reserved by reserveAttempt.
+func completeAttempt(throttle *auth.LoginThrottle, username, ip string, failed bool) {
+	if throttle == nil {
+		return
+	}
+
+	var err error
+	if failed {
+		err = throttle.Rejected(username, ip)
+	} else {
+		err = throttle.Success(username)
+	}
+
+	if err != nil {
+		log.Printf("Error while recording login attempt: %s", err.Error())
+	}
+}
+
+// Sends an error like reserveAttempt if the username or the client address
+// has to wait before the next TOTP or recovery code may be checked.
+func reserveCode(res *models.JsonResponse, throttle *auth.LoginThrottle, username, ip string) bool {
+	if throttle == nil {
+		return true
+	}
+
+	return allow(res, throttle.ReserveCode(username, ip), "Too many failed login attempts")
+}
+
+// Records the result of a code check reserved by reserveCode.
+func completeCode(throttle *auth.LoginThrottle, username, ip string, failed bool) {
+	if throttle == nil {
+		return
+	}
+
+	var err error
+	if failed {
+		err = throttle.CodeRejected(username, ip)
+	} else {
+		err = throttle.CodeSuccess(username)
+	}
+
+	if err != nil {
+		log.Printf("Error while recording code attempt: %s", err.Error())
+	}
+}
+
+// Returns the address of the client without port.
+func clientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
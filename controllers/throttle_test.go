@@ -0,0 +1,103 @@
+package controllers_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+	"github.com/pquerna/otp/totp"
+)
+
+// Test if repeated failed logins lock the user out until an admin unlocks it.
+func TestLoginLockout(t *testing.T) {
+	h := setup(t)
+	h.Throttle.LockoutThreshold = 3
+	h.CreateUserWithRole("admin", "adminpw", models.RoleAdmin)
+	stored, _ := h.Users.FindByUsername(user.UserName)
+
+	credentials := models.LoginCredentials{UserName: user.UserName, Password: "wrong"}
+	for i := 0; i < 3; i++ {
+		h.Do("POST", "/login", credentials, "")
+	}
+
+	// Even the correct password is rejected while locked out.
+	credentials.Password = user.Password
+	res, response := h.Do("POST", "/login", credentials, "")
+
+	if res.StatusCode != 429 {
+		t.Errorf("Expected status code to be %d but received %d", 429, res.StatusCode)
+	}
+
+	if res.Header.Get("Retry-After") == "" {
+		t.Errorf("Expected Retry-After header to be set")
+	}
+
+	if response.Content != "Too many failed login attempts" {
+		t.Errorf("Expected content to be %q but received %q", "Too many failed login attempts", response.Content)
+	}
+
+	path := fmt.Sprintf("/admin/users/%d/lockout", stored.Id)
+	res, _ = h.DoAs("admin", "DELETE", path, nil)
+	if res.StatusCode != 200 {
+		t.Fatalf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	h.Login(user.UserName, user.Password)
+
+	// Both the lockout and the unlock are audited.
+	var events []models.AuditEvent
+	res, response = h.DoAs("admin", "GET", "/admin/audit", nil)
+	h.Decode(response.Content, &events)
+
+	if len(events) != 2 || events[0].Event != models.AuditUnlock || events[1].Event != models.AuditLockout {
+		t.Errorf("Expected unlock and lockout events but received %v", events)
+	}
+
+	if len(events) > 0 && events[0].Actor != "admin" {
+		t.Errorf("Expected actor to be %q but received %q", "admin", events[0].Actor)
+	}
+}
+
+// Test if failed codes lock the codes out even after the password has been
+// checked successfully.
+func TestTotpLockout(t *testing.T) {
+	h := setup(t)
+	h.Throttle.LockoutThreshold = 2
+	token := h.Token(user.UserName)
+
+	var enrollment models.TotpEnrollment
+	_, response := h.Do("POST", "/users/me/totp", nil, token)
+	h.Decode(response.Content, &enrollment)
+
+	code, _ := totp.GenerateCode(enrollment.Secret, time.Now())
+	if res, _ := h.Do("POST", "/users/me/totp/confirm", models.TotpRequest{Code: code}, token); res.StatusCode != 200 {
+		t.Fatalf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	credentials := models.LoginCredentials{UserName: user.UserName, Password: user.Password}
+	for i := 0; i < 2; i++ {
+		var challenge models.LoginChallenge
+		_, response = h.Do("POST", "/login", credentials, "")
+		h.Decode(response.Content, &challenge)
+
+		request := models.ChallengeRequest{ChallengeToken: challenge.ChallengeToken, Code: "000000"}
+		if res, _ := h.Do("POST", "/login/totp", request, ""); res.StatusCode != 400 {
+			t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+		}
+	}
+
+	var challenge models.LoginChallenge
+	res, response := h.Do("POST", "/login", credentials, "")
+	h.Decode(response.Content, &challenge)
+
+	if res.StatusCode != 200 || challenge.ChallengeToken == "" {
+		t.Fatalf("Expected login challenge but received %d %v", res.StatusCode, response.Content)
+	}
+
+	code, _ = totp.GenerateCode(enrollment.Secret, time.Now().Add(30*time.Second))
+	request := models.ChallengeRequest{ChallengeToken: challenge.ChallengeToken, Code: code}
+	if res, _ := h.Do("POST", "/login/totp", request, ""); res.StatusCode != 429 {
+		t.Errorf("Expected status code to be %d but received %d", 429, res.StatusCode)
+	}
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/kluddizz/maintenance-rest-service/auth"
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+type (
+	TokenController struct {
+		Tokens *auth.TokenService
+	}
+)
+
+// Creates a new token controller renewing tokens using the given token service.
+func NewTokenController(tokens *auth.TokenService) *TokenController {
+	return &TokenController{
+		Tokens: tokens,
+	}
+}
+
+// Exchanges a refresh token for a new access and refresh token. The used
+// refresh token becomes invalid.
+func (tc TokenController) RefreshToken(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+	var request models.RefreshRequest
+
+	// Read refresh token from request body
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&request)
+
+	if err != nil || request.RefreshToken == "" {
+		res.Code = 400
+		res.Content = "Error while decoding json body"
+		res.Send()
+		return
+	}
+
+	tokens, err := tc.Tokens.Refresh(request.RefreshToken)
+
+	if err == auth.ErrInvalidRefreshToken || err == auth.ErrRefreshTokenReused {
+		if err == auth.ErrRefreshTokenReused {
+			log.Println("Refresh token reused, revoked its token family")
+		}
+
+		res.Code = 401
+		res.Content = "Invalid refresh token"
+		res.Send()
+		return
+	}
+
+	if err != nil {
+		log.Printf("Error while refreshing tokens: %s", err.Error())
+
+		res.Code = 400
+		res.Content = "Internal error"
+		res.Send()
+		return
+	}
+
+	// Everything went fine.
+	res.Code = 200
+	res.Content = tokens
+	res.Response.Header().Set("Authorization", "Bearer "+tokens.AccessToken)
+	res.Send()
+}
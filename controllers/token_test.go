@@ -0,0 +1,63 @@
+package controllers_test
+
+import (
+	"testing"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+// Test if a refresh token is exchanged for a new, usable token pair.
+func TestRefreshToken(t *testing.T) {
+	h := setup(t)
+	tokens := h.Login(user.UserName, user.Password)
+
+	res, response := h.Do("POST", "/token/refresh", models.RefreshRequest{RefreshToken: tokens.RefreshToken}, "")
+	if res.StatusCode != 200 {
+		t.Fatalf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	var renewed models.TokenPair
+	h.Decode(response.Content, &renewed)
+
+	if renewed.RefreshToken == "" || renewed.RefreshToken == tokens.RefreshToken {
+		t.Errorf("Expected a new refresh token but received %q", renewed.RefreshToken)
+	}
+
+	res, _ = h.Do("GET", "/masters", nil, renewed.AccessToken)
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+}
+
+// Test if replaying a used refresh token revokes the whole token family.
+func TestRefreshTokenReuse(t *testing.T) {
+	h := setup(t)
+	tokens := h.Login(user.UserName, user.Password)
+
+	_, response := h.Do("POST", "/token/refresh", models.RefreshRequest{RefreshToken: tokens.RefreshToken}, "")
+
+	var renewed models.TokenPair
+	h.Decode(response.Content, &renewed)
+
+	// Replay the first refresh token.
+	res, _ := h.Do("POST", "/token/refresh", models.RefreshRequest{RefreshToken: tokens.RefreshToken}, "")
+	if res.StatusCode != 401 {
+		t.Errorf("Expected status code to be %d but received %d", 401, res.StatusCode)
+	}
+
+	// The token issued by the legitimate refresh must be revoked as well.
+	res, _ = h.Do("POST", "/token/refresh", models.RefreshRequest{RefreshToken: renewed.RefreshToken}, "")
+	if res.StatusCode != 401 {
+		t.Errorf("Expected status code to be %d but received %d", 401, res.StatusCode)
+	}
+}
+
+// Test if unknown refresh tokens are rejected.
+func TestRefreshTokenInvalid(t *testing.T) {
+	h := setup(t)
+
+	res, _ := h.Do("POST", "/token/refresh", models.RefreshRequest{RefreshToken: "invalid"}, "")
+	if res.StatusCode != 401 {
+		t.Errorf("Expected status code to be %d but received %d", 401, res.StatusCode)
+	}
+}
@@ -0,0 +1,319 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/kluddizz/maintenance-rest-service/auth"
+	"github.com/kluddizz/maintenance-rest-service/models"
+	"github.com/kluddizz/maintenance-rest-service/repositories"
+)
+
+type (
+	TotpController struct {
+		Totp   *auth.TotpService
+		Users  repositories.UserRepository
+		Tokens *auth.TokenService
+
+		// Optional throttling of failed code checks.
+		Throttle *auth.LoginThrottle
+	}
+)
+
+// Creates a new TOTP controller enrolling users and completing their logins.
+func NewTotpController(totp *auth.TotpService, users repositories.UserRepository, tokens *auth.TokenService) *TotpController {
+	return &TotpController{
+		Totp:   totp,
+		Users:  users,
+		Tokens: tokens,
+	}
+}
+
+// Generates a new secret for the current user, which has to be confirmed
+// before it is used for logins.
+func (tc TotpController) EnrollTotp(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+
+	user, ok := tc.currentUser(res, r)
+	if !ok {
+		return
+	}
+
+	enrollment, err := tc.Totp.Enroll(user)
+
+	if err == auth.ErrTotpEnabled {
+		res.Code = 400
+		res.Content = "TOTP is already enabled"
+		res.Send()
+		return
+	}
+
+	if err != nil {
+		log.Printf("Error while enrolling TOTP: %s", err.Error())
+
+		res.Code = 400
+		res.Content = "Internal error"
+		res.Send()
+		return
+	}
+
+	// Everything went fine.
+	res.Code = 200
+	res.Content = enrollment
+	res.Send()
+}
+
+// Enables the enrolled secret of the current user and sends the recovery
+// codes, which are only shown once.
+func (tc TotpController) ConfirmTotp(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+
+	user, ok := tc.currentUser(res, r)
+	if !ok {
+		return
+	}
+
+	request, ok := decodeTotpRequest(res, r)
+	if !ok || !reserveCode(res, tc.Throttle, user.UserName, clientAddress(r)) {
+		return
+	}
+
+	codes, err := tc.Totp.Confirm(user, request.Code)
+
+	if err == auth.ErrTotpNotEnrolled {
+		res.Code = 400
+		res.Content = "TOTP has not been enrolled"
+		res.Send()
+		return
+	}
+
+	if err == auth.ErrTotpEnabled {
+		res.Code = 400
+		res.Content = "TOTP is already enabled"
+		res.Send()
+		return
+	}
+
+	if !tc.codeAccepted(res, r, user, err) {
+		return
+	}
+
+	// Everything went fine.
+	res.Code = 200
+	res.Content = codes
+	res.Send()
+}
+
+// Disables TOTP for the current user, who has to send a valid TOTP or
+// recovery code.
+func (tc TotpController) DisableTotp(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+
+	user, ok := tc.currentUser(res, r)
+	if !ok {
+		return
+	}
+
+	request, ok := decodeTotpRequest(res, r)
+	if !ok || !reserveCode(res, tc.Throttle, user.UserName, clientAddress(r)) {
+		return
+	}
+
+	err := tc.Totp.Verify(user.Id, request.Code)
+
+	if err == auth.ErrInvalidCode {
+		err = tc.Totp.VerifyRecoveryCode(user, request.Code)
+	}
+
+	if err == nil {
+		err = tc.Totp.Disable(user)
+	}
+
+	if !tc.codeAccepted(res, r, user, err) {
+		return
+	}
+
+	// Everything went fine.
+	res.Code = 200
+	res.Content = "Success"
+	res.Send()
+}
+
+// Replaces the recovery codes of the current user, who has to send a valid
+// TOTP code.
+func (tc TotpController) RenewRecoveryCodes(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+
+	user, ok := tc.currentUser(res, r)
+	if !ok {
+		return
+	}
+
+	request, ok := decodeTotpRequest(res, r)
+	if !ok || !reserveCode(res, tc.Throttle, user.UserName, clientAddress(r)) {
+		return
+	}
+
+	var codes models.RecoveryCodes
+	err := tc.Totp.Verify(user.Id, request.Code)
+
+	if err == nil {
+		codes, err = tc.Totp.RenewRecoveryCodes(user)
+	}
+
+	if !tc.codeAccepted(res, r, user, err) {
+		return
+	}
+
+	// Everything went fine.
+	res.Code = 200
+	res.Content = codes
+	res.Send()
+}
+
+// Completes the login challenge of a user with a TOTP or recovery code and
+// sends an access and a refresh token if succeeded.
+func (tc TotpController) CompleteLogin(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+	var request models.ChallengeRequest
+
+	// Read challenge and code from request body
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&request)
+
+	if err != nil {
+		res.Code = 400
+		res.Content = "Error while decoding json body"
+		res.Send()
+		return
+	}
+
+	claims, err := tc.Totp.VerifyChallenge(request.ChallengeToken)
+
+	var user models.UserDb
+	if err == nil {
+		user, err = tc.Users.FindById(claims.UserId)
+	}
+
+	if err != nil {
+		res.Code = 401
+		res.Content = "Invalid login challenge"
+		res.Send()
+		return
+	}
+
+	if !reserveCode(res, tc.Throttle, user.UserName, clientAddress(r)) {
+		return
+	}
+
+	if request.RecoveryCode != "" {
+		err = tc.Totp.VerifyRecoveryCode(user, request.RecoveryCode)
+	} else {
+		err = tc.Totp.Verify(user.Id, request.Code)
+	}
+
+	if !tc.codeAccepted(res, r, user, err) {
+		return
+	}
+
+	// Create tokens
+	tokens, err := tc.Tokens.Issue(user)
+
+	if err == auth.ErrUserDisabled {
+		res.Code = 403
+		res.Content = "User is disabled"
+		res.Send()
+		return
+	}
+
+	if err != nil {
+		log.Printf("Error while issuing tokens: %s", err.Error())
+
+		res.Code = 400
+		res.Content = "Internal error"
+		res.Send()
+		return
+	}
+
+	// Everything went fine.
+	res.Code = 200
+	res.Content = tokens
+	res.Response.Header().Set("Authorization", "Bearer "+tokens.AccessToken)
+	res.Send()
+}
+
+// Returns the stored user of the login token.
+func (tc TotpController) currentUser(res *models.JsonResponse, r *http.Request) (models.UserDb, bool) {
+	claims, ok := loggedInUser(res, r)
+	if !ok {
+		return models.UserDb{}, false
+	}
+
+	user, err := tc.Users.FindById(claims.UserId)
+
+	if err != nil {
+		res.Code = 400
+		res.Content = "Could not find user"
+		res.Send()
+		return user, false
+	}
+
+	return user, true
+}
+
+// Records the result of a code check and sends an error if the check failed.
+func (tc TotpController) codeAccepted(res *models.JsonResponse, r *http.Request, user models.UserDb, err error) bool {
+	if err == auth.ErrInvalidCode {
+		completeCode(tc.Throttle, user.UserName, clientAddress(r), true)
+
+		res.Code = 400
+		res.Content = "Invalid code"
+		res.Send()
+		return false
+	}
+
+	if err != nil {
+		log.Printf("Error while checking TOTP code: %s", err.Error())
+
+		res.Code = 400
+		res.Content = "Internal error"
+		res.Send()
+		return false
+	}
+
+	completeCode(tc.Throttle, user.UserName, clientAddress(r), false)
+	return true
+}
+
+func decodeTotpRequest(res *models.JsonResponse, r *http.Request) (models.TotpRequest, bool) {
+	var request models.TotpRequest
+
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&request)
+
+	if err != nil || request.Code == "" {
+		res.Code = 400
+		res.Content = "Error while decoding json body"
+		res.Send()
+		return request, false
+	}
+
+	return request, true
+}
+
+// Returns a login challenge if the user enabled TOTP, otherwise nil.
+func loginChallenge(totp *auth.TotpService, user models.UserDb) (*models.LoginChallenge, error) {
+	enabled, err := totp.Enabled(user.Id)
+	if err != nil || !enabled {
+		return nil, err
+	}
+
+	challenge, err := totp.Challenge(user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &challenge, nil
+}
@@ -0,0 +1,85 @@
+package controllers_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+	"github.com/pquerna/otp/totp"
+)
+
+// Test if enrolled users complete their login with a TOTP or recovery code.
+func TestTotpLogin(t *testing.T) {
+	h := setup(t)
+	token := h.Token(user.UserName)
+
+	var enrollment models.TotpEnrollment
+	res, response := h.Do("POST", "/users/me/totp", nil, token)
+	h.Decode(response.Content, &enrollment)
+
+	if res.StatusCode != 200 || enrollment.Secret == "" {
+		t.Fatalf("Expected enrollment but received %d %v", res.StatusCode, response.Content)
+	}
+
+	code, _ := totp.GenerateCode(enrollment.Secret, time.Now())
+	res, _ = h.Do("POST", "/users/me/totp/confirm", models.TotpRequest{Code: "000000"}, token)
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
+
+	var codes models.RecoveryCodes
+	res, response = h.Do("POST", "/users/me/totp/confirm", models.TotpRequest{Code: code}, token)
+	h.Decode(response.Content, &codes)
+
+	if res.StatusCode != 200 || len(codes.RecoveryCodes) != 10 {
+		t.Fatalf("Expected recovery codes but received %d %v", res.StatusCode, response.Content)
+	}
+
+	// The password alone only yields a challenge, which is no access token.
+	var challenge models.LoginChallenge
+	credentials := models.LoginCredentials{UserName: user.UserName, Password: user.Password}
+	_, response = h.Do("POST", "/login", credentials, "")
+	h.Decode(response.Content, &challenge)
+
+	if !challenge.MfaRequired || challenge.ChallengeToken == "" {
+		t.Fatalf("Expected login challenge but received %v", response.Content)
+	}
+
+	res, _ = h.Do("GET", "/masters", nil, challenge.ChallengeToken)
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
+
+	// Codes are only accepted once.
+	request := models.ChallengeRequest{ChallengeToken: challenge.ChallengeToken, Code: code}
+	res, _ = h.Do("POST", "/login/totp", request, "")
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
+
+	request.Code, _ = totp.GenerateCode(enrollment.Secret, time.Now().Add(30*time.Second))
+	res, response = h.Do("POST", "/login/totp", request, "")
+
+	var tokens models.TokenPair
+	h.Decode(response.Content, &tokens)
+
+	if res.StatusCode != 200 || tokens.AccessToken == "" {
+		t.Errorf("Expected tokens but received %d %v", res.StatusCode, response.Content)
+	}
+
+	request = models.ChallengeRequest{ChallengeToken: challenge.ChallengeToken, RecoveryCode: codes.RecoveryCodes[0]}
+	for _, expected := range []int{200, 400} {
+		res, _ = h.Do("POST", "/login/totp", request, "")
+		if res.StatusCode != expected {
+			t.Errorf("Expected status code to be %d but received %d", expected, res.StatusCode)
+		}
+	}
+
+	// Disabling TOTP restores the login with password only.
+	res, _ = h.Do("DELETE", "/users/me/totp", models.TotpRequest{Code: codes.RecoveryCodes[1]}, token)
+	if res.StatusCode != 200 {
+		t.Fatalf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	h.Login(user.UserName, user.Password)
+}
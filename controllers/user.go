@@ -1,29 +1,66 @@
 package controllers
 
 import (
-	"database/sql"
 	"encoding/json"
-	"io/ioutil"
+	"errors"
+	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/julienschmidt/httprouter"
+	"github.com/kluddizz/maintenance-rest-service/auth"
+	"github.com/kluddizz/maintenance-rest-service/middlewares"
 	"github.com/kluddizz/maintenance-rest-service/models"
+	"github.com/kluddizz/maintenance-rest-service/repositories"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type (
 	UserController struct {
-		Db *sql.DB
+		Users  repositories.UserRepository
+		Tokens *auth.TokenService
+
+		// Checks passwords at login.
+		Authenticator auth.Authenticator
+
+		// Optional throttling of failed password checks.
+		Throttle *auth.LoginThrottle
+
+		// Optional second factor. Users who enabled TOTP receive a login
+		// challenge instead of tokens.
+		Totp *auth.TotpService
+
+		// Optional verification of emails given on registration.
+		Accounts *auth.AccountService
+
+		// Optional links to identity providers, whose users have no local
+		// password.
+		Identities repositories.IdentityRepository
+
+		// Optional log of changes to accounts.
+		Audit repositories.AuditRepository
+
+		// Rules for passwords and usernames chosen by users.
+		Passwords *auth.PasswordPolicy
+		Usernames *auth.UsernamePolicy
+
+		// Role of newly registered users.
+		DefaultRole models.Role
 	}
 )
 
-// Creates a new instance of the user controller structure.
-func NewUserController(db *sql.DB) *UserController {
+// Creates a new instance of the user controller structure, which issues tokens
+// using the given token service and checks the stored password hashes.
+func NewUserController(users repositories.UserRepository, tokens *auth.TokenService) *UserController {
 	return &UserController{
-		Db: db,
+		Users:         users,
+		Tokens:        tokens,
+		Authenticator: auth.NewLocalAuthenticator(users),
+		Passwords:     auth.NewPasswordPolicy(),
+		Usernames:     auth.NewUsernamePolicy(),
+		DefaultRole:   models.RoleViewer,
 	}
 }
 
@@ -43,6 +80,20 @@ func (uc UserController) CreateUser(w http.ResponseWriter, r *http.Request, p ht
 		return
 	}
 
+	// Users must not choose their own role
+	user.Role = uc.DefaultRole
+
+	if err := uc.Usernames.Validate(user.UserName); err != nil {
+		res.Code = 400
+		res.Content = err.Error()
+		res.Send()
+		return
+	}
+
+	if !acceptPassword(res, uc.Passwords, user.UserName, user.Password) {
+		return
+	}
+
 	// Hash the password using bcrypt
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 
@@ -56,10 +107,7 @@ func (uc UserController) CreateUser(w http.ResponseWriter, r *http.Request, p ht
 	}
 
 	// Insert the user into the database
-	_, err = uc.Db.Query(
-		"INSERT INTO users (username, password, firstName, lastName, email) VALUES (?, ?, ?, ?, ?)",
-		user.UserName, hashedPassword, user.FirstName, user.LastName, user.Email,
-	)
+	err = uc.Users.Create(&user, hashedPassword)
 
 	if err != nil {
 		log.Println(err.Error())
@@ -70,78 +118,181 @@ func (uc UserController) CreateUser(w http.ResponseWriter, r *http.Request, p ht
 		return
 	}
 
+	// The registration succeeded even if the mail could not be sent, it can
+	// be requested again.
+	if uc.Accounts != nil && user.Email != "" {
+		created, err := uc.Users.FindById(user.Id)
+
+		if err == nil {
+			err = uc.Accounts.SendVerification(created)
+		}
+
+		if err != nil {
+			log.Printf("Error while sending verification mail: %s", err.Error())
+		}
+	}
+
 	// Everything went fine.
 	res.Code = 200
 	res.Content = "Success"
 	res.Send()
 }
 
-// Tries to login an user using the given login credentials and send a token if succeeded.
+// Tries to login an user using the given login credentials and send an access
+// and a refresh token if succeeded.
 func (uc UserController) LoginUser(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	res := models.NewJsonResponse(w)
 	var loginCredentials models.LoginCredentials
-	privateKey, err := ioutil.ReadFile("./private.key")
+
+	// Read credentials from request body
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&loginCredentials)
 
 	if err != nil {
 		res.Code = 400
-		res.Content = "Internal error"
+		res.Content = "Error while decoding json body"
 		res.Send()
 		return
 	}
 
-	// Read credentials from request body
-	decoder := json.NewDecoder(r.Body)
-	err = decoder.Decode(&loginCredentials)
+	// Check the credentials
+	user, ok := uc.checkPassword(res, r, loginCredentials)
+
+	if !ok {
+		return
+	}
+
+	// An admin required a new password, which is set with a reset token
+	if user.PasswordResetRequired {
+		res.Code = 403
+		res.Content = "Password has to be reset"
+		res.Send()
+		return
+	}
+
+	// Users with a second factor have to complete the login with a code
+	if uc.Totp != nil {
+		challenge, err := uc.challenge(user)
+
+		if err != nil {
+			log.Printf("Error while creating login challenge: %s", err.Error())
+
+			res.Code = 400
+			res.Content = "Internal error"
+			res.Send()
+			return
+		}
+
+		if challenge != nil {
+			res.Code = 200
+			res.Content = challenge
+			res.Send()
+			return
+		}
+	}
+
+	// Create tokens
+	tokens, err := uc.Tokens.Issue(user)
+
+	if err == auth.ErrUserDisabled {
+		res.Code = 403
+		res.Content = "User is disabled"
+		res.Send()
+		return
+	}
 
 	if err != nil {
+		log.Printf("Error while issuing tokens: %s", err.Error())
+
 		res.Code = 400
-		res.Content = "Error while decoding json body"
+		res.Content = "Internal error"
 		res.Send()
 		return
 	}
 
-	var user models.UserDb
+	// Everything went fine.
+	res.Code = 200
+	res.Content = tokens
+	res.Response.Header().Set("Authorization", "Bearer "+tokens.AccessToken)
+	res.Send()
+}
+
+// Deletes an user and removes it from the database.
+func (uc UserController) DeleteUser(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+	var loginCredentials models.LoginCredentials
 
-	// Check if the user exists
-	err = uc.Db.QueryRow(
-		"SELECT id, username, password, firstname, lastname, email FROM users WHERE username = ?",
-		loginCredentials.UserName,
-	).Scan(
-		&user.Id, &user.UserName, &user.Password, &user.FirstName, &user.LastName, &user.Email,
-	)
+	// Parse user from the request body
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&loginCredentials)
 
 	if err != nil {
-    log.Printf("Error while selecting user: %s", err.Error())
-
 		res.Code = 400
 		res.Content = "Internal error"
 		res.Send()
 		return
 	}
 
-	// Compare passwords
-	err = bcrypt.CompareHashAndPassword(user.Password, []byte(loginCredentials.Password))
+	// Check the credentials
+	user, ok := uc.checkPassword(res, r, loginCredentials)
+
+	if !ok {
+		return
+	}
+
+	// Remove user from database
+	err = uc.Users.Delete(user.Id)
 
 	if err != nil {
 		res.Code = 400
-		res.Content = "Wrong login credentials"
+		res.Content = "Could not delete user"
 		res.Send()
 		return
 	}
 
-	// Create token
-	claims := models.CustomClaims{
-		UserName: loginCredentials.UserName,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(time.Minute * 30).Unix(),
-			Issuer:    "maintenance-rest-service",
-		},
+	// Tokens of the user must not outlive it
+	err = uc.Tokens.RevokeUser(user.Id)
+
+	if err != nil {
+		log.Printf("Error while revoking tokens: %s", err.Error())
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString(privateKey)
+	// Everything went fine
+	res.Code = 200
+	res.Content = "Success"
+	res.Send()
+}
+
+// Revokes the token used for the request. If a refresh token is sent within
+// the body, its family is revoked as well.
+func (uc UserController) LogoutUser(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+	var request models.RefreshRequest
+
+	// The body is optional
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&request)
+
+	if err != nil && err != io.EOF {
+		res.Code = 400
+		res.Content = "Error while decoding json body"
+		res.Send()
+		return
+	}
+
+	claims, _ := middlewares.ClaimsFromContext(r.Context())
+	err = uc.Tokens.Logout(claims, request.RefreshToken)
+
+	if err == auth.ErrNoTokenId {
+		res.Code = 400
+		res.Content = "Token cannot be revoked"
+		res.Send()
+		return
+	}
 
 	if err != nil {
+		log.Printf("Error while revoking tokens: %s", err.Error())
+
 		res.Code = 400
 		res.Content = "Internal error"
 		res.Send()
@@ -150,69 +301,227 @@ func (uc UserController) LoginUser(w http.ResponseWriter, r *http.Request, p htt
 
 	// Everything went fine.
 	res.Code = 200
-	res.Content = signedToken
-	res.Response.Header().Set("Authorization", "Bearer "+signedToken)
+	res.Content = "Success"
 	res.Send()
 }
 
-// Deletes an user and removes it from the database.
-func (uc UserController) DeleteUser(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+// Changes the role of the user with the given id. Existing tokens of the user
+// are revoked, so the new role applies immediately.
+func (uc UserController) SetUserRole(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 	res := models.NewJsonResponse(w)
-	var loginCredentials models.LoginCredentials
+	var request models.RoleRequest
 
-	// Parse user from the request body
+	user, ok := uc.findUser(res, p)
+	if !ok {
+		return
+	}
+
+	// Read role from request body
 	decoder := json.NewDecoder(r.Body)
-	err := decoder.Decode(&loginCredentials)
+	err := decoder.Decode(&request)
 
-	if err != nil {
+	if err != nil || !request.Role.Valid() {
 		res.Code = 400
-		res.Content = "Internal error"
+		res.Content = "Invalid role"
 		res.Send()
 		return
 	}
 
-	var user models.UserDb
+	claims, _ := middlewares.ClaimsFromContext(r.Context())
 
-	// Check if the user exists
-	err = uc.Db.QueryRow(
-		"SELECT id, username, password, firstname, lastname, email FROM users WHERE username = ?",
-		loginCredentials.UserName,
-	).Scan(
-		&user.Id, &user.UserName, &user.Password, &user.FirstName, &user.LastName, &user.Email,
-	)
+	if user.Role == models.RoleAdmin && request.Role != models.RoleAdmin && !uc.allowDemotion(res, claims, user) {
+		return
+	}
+
+	err = uc.Users.SetRole(user.Id, request.Role)
+
+	if err == nil {
+		err = uc.Tokens.RevokeUser(user.Id)
+	}
 
 	if err != nil {
+		log.Printf("Error while changing role: %s", err.Error())
+
 		res.Code = 400
-		res.Content = "Wrong login credentials"
+		res.Content = "Could not change role"
 		res.Send()
 		return
 	}
 
-	// Compare passwords
-	err = bcrypt.CompareHashAndPassword(user.Password, []byte(loginCredentials.Password))
+	uc.record(models.AuditRoleChanged, user.UserName, claims.UserName, string(user.Role)+" to "+string(request.Role))
+
+	// Everything went fine.
+	res.Code = 200
+	res.Content = "Success"
+	res.Send()
+}
+
+// Sends an error if demoting the admin would leave nobody to manage users,
+// because admins demote themselves or the admin is the last enabled one.
+func (uc UserController) allowDemotion(res *models.JsonResponse, claims *models.CustomClaims, user models.UserDb) bool {
+	if claims.UserId == user.Id {
+		res.Code = 400
+		res.Content = "Admins cannot demote themselves"
+		res.Send()
+		return false
+	}
+
+	admins, err := uc.Users.CountRole(models.RoleAdmin)
+
+	if err != nil {
+		log.Printf("Error while counting admins: %s", err.Error())
+
+		res.Code = 400
+		res.Content = "Could not change role"
+		res.Send()
+		return false
+	}
+
+	if admins <= 1 && !user.Disabled {
+		res.Code = 400
+		res.Content = "The last admin cannot be demoted"
+		res.Send()
+		return false
+	}
+
+	return true
+}
+
+// Returns a login challenge if the user enabled TOTP, otherwise nil.
+func (uc UserController) challenge(user models.UserDb) (*models.LoginChallenge, error) {
+	return loginChallenge(uc.Totp, user)
+}
+
+// Checks the credentials while throttling failed attempts per username and
+// client address. If the check fails, the error is sent and false returned.
+func (uc UserController) checkPassword(res *models.JsonResponse, r *http.Request, credentials models.LoginCredentials) (models.UserDb, bool) {
+	ip := clientAddress(r)
+
+	if !reserveAttempt(res, uc.Throttle, credentials.UserName, ip) {
+		return models.UserDb{}, false
+	}
+
+	// Check if the user exists and compare passwords
+	user, err := uc.Authenticator.Authenticate(r.Context(), credentials.UserName, credentials.Password)
+
+	if err == auth.ErrLoginDenied {
+		res.Code = 403
+		res.Content = "No role has been assigned to the user"
+		res.Send()
+		return user, false
+	}
+
+	if err != nil && err != auth.ErrInvalidCredentials {
+		log.Printf("Error while checking password: %s", err.Error())
+
+		res.Code = 400
+		res.Content = "Internal error"
+		res.Send()
+		return user, false
+	}
+
+	completeAttempt(uc.Throttle, credentials.UserName, ip, err != nil)
 
 	if err != nil {
 		res.Code = 400
 		res.Content = "Wrong login credentials"
 		res.Send()
+		return user, false
+	}
+
+	// Only reveal the status to users knowing the password
+	if user.Disabled {
+		res.Code = 403
+		res.Content = "User is disabled"
+		res.Send()
+		return user, false
+	}
+
+	return user, true
+}
+
+// Lifts the lockout of a user after too many failed logins.
+func (uc UserController) UnlockUser(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	res := models.NewJsonResponse(w)
+
+	id, err := strconv.Atoi(p.ByName("id"))
+
+	if err != nil {
+		res.Code = 400
+		res.Content = "Invalid user id"
+		res.Send()
 		return
 	}
 
-	// Remove user from database
-	_, err = uc.Db.Query(
-		"DELETE FROM users WHERE id = ?",
-		user.Id,
-	)
+	user, err := uc.Users.FindById(id)
+
+	if err == repositories.ErrNotFound {
+		res.Code = 404
+		res.Content = "User not found"
+		res.Send()
+		return
+	}
+
+	if err == nil && uc.Throttle != nil {
+		claims, _ := middlewares.ClaimsFromContext(r.Context())
+		err = uc.Throttle.Unlock(user.UserName, claims.UserName)
+	}
 
 	if err != nil {
+		log.Printf("Error while unlocking user: %s", err.Error())
+
 		res.Code = 400
-		res.Content = "Could not delete user"
+		res.Content = "Could not unlock user"
 		res.Send()
 		return
 	}
 
-	// Everything went fine
+	// Everything went fine.
 	res.Code = 200
 	res.Content = "Success"
 	res.Send()
 }
+
+// Records an event about the user with the given name. A failure is only
+// logged, since the change already happened.
+func (uc UserController) record(event, username, actor, details string) {
+	if uc.Audit == nil {
+		return
+	}
+
+	err := uc.Audit.Record(&models.AuditEvent{
+		Event:     event,
+		Subject:   "user:" + username,
+		Actor:     actor,
+		Details:   details,
+		CreatedAt: time.Now().Unix(),
+	})
+
+	if err != nil {
+		log.Printf("Error while recording audit event: %s", err.Error())
+	}
+}
+
+// Checks a new password of the user against the policy. If the password is
+// rejected, the violations are sent and false returned.
+func acceptPassword(res *models.JsonResponse, policy *auth.PasswordPolicy, username, password string) bool {
+	err := policy.Validate(username, password)
+	if err == nil {
+		return true
+	}
+
+	var violation *auth.PolicyError
+	if errors.As(err, &violation) {
+		res.Code = 400
+		res.Content = violation.Error()
+		res.Send()
+		return false
+	}
+
+	log.Printf("Error while checking password: %s", err.Error())
+
+	res.Code = 400
+	res.Content = "Internal error"
+	res.Send()
+	return false
+}
@@ -0,0 +1,130 @@
+package controllers_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kluddizz/maintenance-rest-service/auth"
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+// Test if registered users are able to login and receive a usable token.
+func TestRegisterAndLogin(t *testing.T) {
+	h := setup(t)
+	newUser := models.User{UserName: "newuser", Password: "newpassword", Email: "new@example.com"}
+
+	res, _ := h.Do("POST", "/register", newUser, "")
+	if res.StatusCode != 200 {
+		t.Fatalf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	tokens := h.Login(newUser.UserName, newUser.Password)
+	if tokens.RefreshToken == "" {
+		t.Errorf("Expected login to return a refresh token")
+	}
+
+	// The received token must be accepted by protected routes.
+	res, _ = h.Do("GET", "/masters", nil, tokens.AccessToken)
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	claims := &models.CustomClaims{}
+	if err := h.Keys.Verify(tokens.AccessToken, claims); err != nil || claims.Audience != auth.AccessAudience {
+		t.Errorf("Expected audience to be %s but received %s (%v)", auth.AccessAudience, claims.Audience, err)
+	}
+
+	// Tokens signed with the same keys need the audience of access tokens.
+	claims.Audience = ""
+	claims.ExpiresAt = time.Now().Add(time.Minute).Unix()
+	token, _ := h.Keys.Sign(claims)
+
+	res, _ = h.Do("GET", "/masters", nil, token)
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
+}
+
+// Test if registrations violating the username or password policy are
+// rejected with a description of the violations.
+func TestRegisterPolicy(t *testing.T) {
+	h := setup(t)
+
+	for _, newUser := range []models.User{
+		{UserName: "new user", Password: "newpassword"},
+		{UserName: "newuser", Password: ""},
+		{UserName: "newuser", Password: "NewUser2024"},
+	} {
+		res, response := h.Do("POST", "/register", newUser, "")
+		content, _ := response.Content.(string)
+
+		if res.StatusCode != 400 || !strings.HasPrefix(content, "Invalid") {
+			t.Errorf("Expected %v to be rejected but received %d %v", newUser, res.StatusCode, response.Content)
+		}
+	}
+
+	if _, err := h.Users.FindByUsername("newuser"); err == nil {
+		t.Errorf("Expected no user to be created")
+	}
+}
+
+// Test if logins with a wrong password are rejected.
+func TestLoginFail(t *testing.T) {
+	h := setup(t)
+
+	credentials := models.LoginCredentials{UserName: user.UserName, Password: "wrong"}
+	res, response := h.Do("POST", "/login", credentials, "")
+
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
+
+	if response.Content != "Wrong login credentials" {
+		t.Errorf("Expected content to be %q but received %q", "Wrong login credentials", response.Content)
+	}
+}
+
+// Test if users can delete themselves using their credentials.
+func TestDeleteUser(t *testing.T) {
+	h := setup(t)
+
+	token := h.Token(user.UserName)
+	credentials := models.LoginCredentials{UserName: user.UserName, Password: user.Password}
+	res, _ := h.Do("DELETE", "/users", credentials, token)
+
+	if res.StatusCode != 200 {
+		t.Errorf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	if _, err := h.Users.FindByUsername(user.UserName); err == nil {
+		t.Errorf("Expected user %s to be deleted", user.UserName)
+	}
+
+	// The token of the deleted user must be rejected.
+	res, response := h.Do("GET", "/masters", nil, token)
+	if res.StatusCode != 400 || response.Content != "Token has been revoked" {
+		t.Errorf("Expected status code to be %d but received %d (%v)", 400, res.StatusCode, response.Content)
+	}
+}
+
+// Test if logging out revokes the access token and the refresh token.
+func TestLogout(t *testing.T) {
+	h := setup(t)
+	tokens := h.Login(user.UserName, user.Password)
+
+	res, _ := h.Do("POST", "/logout", models.RefreshRequest{RefreshToken: tokens.RefreshToken}, tokens.AccessToken)
+	if res.StatusCode != 200 {
+		t.Fatalf("Expected status code to be %d but received %d", 200, res.StatusCode)
+	}
+
+	res, _ = h.Do("GET", "/masters", nil, tokens.AccessToken)
+	if res.StatusCode != 400 {
+		t.Errorf("Expected status code to be %d but received %d", 400, res.StatusCode)
+	}
+
+	res, _ = h.Do("POST", "/token/refresh", models.RefreshRequest{RefreshToken: tokens.RefreshToken}, "")
+	if res.StatusCode != 401 {
+		t.Errorf("Expected status code to be %d but received %d", 401, res.StatusCode)
+	}
+}
@@ -0,0 +1,38 @@
+// Package mail delivers notifications like verification and password reset
+// mails. Mailers are exchangeable, so mails can be logged or written to a
+// file instead of being sent during local development.
+package mail
+
+import (
+	"errors"
+	"strings"
+)
+
+type (
+	// Delivers mails to their recipient.
+	Mailer interface {
+		Send(message Message) error
+	}
+
+	// A plain text mail.
+	Message struct {
+		To      string
+		Subject string
+		Body    string
+
+		// Parts of the body left out where mails are not delivered to their
+		// recipient, e.g. tokens in the log.
+		Secrets []string
+	}
+)
+
+var ErrInvalidHeader = errors.New("mail headers must not contain line breaks")
+
+// Returns an error if the recipient or subject could inject further headers.
+func (m Message) Validate() error {
+	if strings.ContainsAny(m.To+m.Subject, "\r\n") {
+		return ErrInvalidHeader
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package mail
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// Test if written mails contain their headers and reject injected headers.
+func TestWriterMailer(t *testing.T) {
+	var buf bytes.Buffer
+	mailer := &WriterMailer{Writer: &buf, From: "service@example.com"}
+
+	if err := mailer.Send(Message{To: "user@example.com", Subject: "Hello", Body: "Line\nLine"}); err != nil {
+		t.Fatalf("Could not write mail: %s", err.Error())
+	}
+
+	for _, expected := range []string{"From: service@example.com\r\n", "To: user@example.com\r\n", "\r\n\r\nLine\r\nLine"} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("Expected mail to contain %q but received %q", expected, buf.String())
+		}
+	}
+
+	if err := mailer.Send(Message{To: "user@example.com\r\nBcc: other@example.com"}); err != ErrInvalidHeader {
+		t.Errorf("Expected error to be %v but received %v", ErrInvalidHeader, err)
+	}
+}
+
+// Test if the log mailer leaves out the secrets of mails.
+func TestLogMailerRedact(t *testing.T) {
+	var buf bytes.Buffer
+	mailer := NewLogMailer("service@example.com")
+	mailer.Writer = &buf
+
+	message := Message{To: "user@example.com", Subject: "Reset", Body: "Token: secret-token", Secrets: []string{"secret-token"}}
+	if err := mailer.Send(message); err != nil {
+		t.Fatalf("Could not write mail: %s", err.Error())
+	}
+
+	if strings.Contains(buf.String(), "secret-token") || !strings.Contains(buf.String(), "Token: [redacted]") {
+		t.Errorf("Expected token to be redacted but received %q", buf.String())
+	}
+}
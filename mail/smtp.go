@@ -0,0 +1,64 @@
+package mail
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// Sends mails through an SMTP server. STARTTLS is used if the server
+	// supports it; credentials are only sent over encrypted connections.
+	SmtpMailer struct {
+		Host     string
+		Port     int
+		Username string
+		Password string
+		From     string
+	}
+)
+
+// Creates a new mailer sending mails from the given address.
+func NewSmtpMailer(host string, port int, username, password, from string) *SmtpMailer {
+	return &SmtpMailer{
+		Host:     host,
+		Port:     port,
+		Username: username,
+		Password: password,
+		From:     from,
+	}
+}
+
+// Sends the message to its recipient.
+func (m *SmtpMailer) Send(message Message) error {
+	if err := message.Validate(); err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	return smtp.SendMail(addr, auth, m.From, []string{message.To}, format(m.From, message))
+}
+
+// Formats the message with its headers as sent to the server.
+func format(from string, message Message) []byte {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", message.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", message.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+
+	return []byte(b.String())
+}
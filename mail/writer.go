@@ -0,0 +1,67 @@
+package mail
+
+import (
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+type (
+	// Writes mails to a writer instead of sending them, e.g. to the log or to
+	// a file during local development.
+	WriterMailer struct {
+		Writer io.Writer
+		From   string
+
+		// Replace the secrets of mails, e.g. since the log is read by others
+		// than the recipients.
+		Redact bool
+
+		mutex sync.Mutex
+	}
+)
+
+// Creates a new mailer writing all mails with redacted secrets to the log.
+func NewLogMailer(from string) *WriterMailer {
+	return &WriterMailer{
+		Writer: log.Writer(),
+		From:   from,
+		Redact: true,
+	}
+}
+
+// Creates a new mailer appending all mails to the given file.
+func NewFileMailer(path string, from string) (*WriterMailer, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WriterMailer{
+		Writer: file,
+		From:   from,
+	}, nil
+}
+
+// Writes the message with its headers, followed by an empty line.
+func (m *WriterMailer) Send(message Message) error {
+	if err := message.Validate(); err != nil {
+		return err
+	}
+
+	if m.Redact {
+		for _, secret := range message.Secrets {
+			if secret != "" {
+				message.Body = strings.ReplaceAll(message.Body, secret, "[redacted]")
+			}
+		}
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	_, err := m.Writer.Write(append(format(m.From, message), "\r\n\r\n"...))
+	return err
+}
@@ -2,52 +2,187 @@ package middlewares
 
 import (
 	"context"
-	"fmt"
-	"io/ioutil"
+	"log"
 	"net/http"
 	"strings"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/julienschmidt/httprouter"
+	"github.com/kluddizz/maintenance-rest-service/auth"
 	"github.com/kluddizz/maintenance-rest-service/models"
 )
 
-func AuthMiddleWare(next httprouter.Handle) httprouter.Handle {
-  return func(w http.ResponseWriter, req *http.Request, p httprouter.Params) {
-    res := models.NewJsonResponse(w)
-    authHeader := req.Header.Get("authorization")
-
-    if authHeader != "" {
-      bearerToken := strings.Split(authHeader, " ")
-
-      if len(bearerToken) == 2 {
-        token, err := jwt.Parse(bearerToken[1], func(token *jwt.Token) (interface{}, error) {
-          if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-            return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
-          }
-
-          privateKey, err := ioutil.ReadFile("./private.key")
-
-          if err != nil {
-            return nil, fmt.Errorf("Error while reading private key")
-          }
-
-          return []byte(privateKey), nil
-        })
-
-        if err == nil && token.Valid {
-          ctx := context.WithValue(req.Context(), "auth", token.Claims)
-          next(w, req.WithContext(ctx), p)
-        } else {
-          res.Code = 400
-          res.Content = "Invalid authorization token"
-          res.Send()
-        }
-      }
-    } else {
-      res.Code = 400
-      res.Content = "An authorization header is required"
-      res.Send()
-    }
-  }
+type (
+	Auth struct {
+		Verifier auth.Verifier
+
+		// Optional check rejecting revoked tokens.
+		Revocations auth.RevocationChecker
+
+		// Optional check rejecting tokens and API keys of disabled users.
+		Users auth.UserStatusChecker
+
+		// Optional verifier of API keys sent as `X-API-Key` header or as
+		// `Authorization: ApiKey <key>`.
+		ApiKeys auth.ApiKeyVerifier
+
+		// Accept the subject of a verified client certificate as username
+		// when no authorization header is sent.
+		ClientCertIdentity bool
+
+		// Role granted to client certificate identities.
+		ClientCertRole models.Role
+	}
+
+	contextKey string
+)
+
+// Key of the claims of the authenticated user inside the request context.
+const authKey contextKey = "auth"
+
+// Creates a new authentication middleware verifying tokens with the given verifier.
+func NewAuth(verifier auth.Verifier) *Auth {
+	return &Auth{
+		Verifier: verifier,
+	}
+}
+
+// Returns the claims of the authenticated user stored by AuthMiddleWare.
+func ClaimsFromContext(ctx context.Context) (*models.CustomClaims, bool) {
+	claims, ok := ctx.Value(authKey).(*models.CustomClaims)
+	return claims, ok
+}
+
+// Stores the claims of the authenticated user in the context.
+func ContextWithClaims(ctx context.Context, claims *models.CustomClaims) context.Context {
+	return context.WithValue(ctx, authKey, claims)
+}
+
+func (a *Auth) AuthMiddleWare(next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, p httprouter.Params) {
+		res := models.NewJsonResponse(w)
+		authHeader := req.Header.Get("authorization")
+
+		if apiKey := a.apiKey(req); apiKey != "" {
+			claims, err := a.ApiKeys.VerifyApiKey(apiKey)
+
+			if err == auth.ErrInvalidApiKey {
+				res.Code = 400
+				res.Content = "Invalid API key"
+				res.Send()
+			} else if err != nil {
+				log.Printf("Error while verifying API key: %s", err.Error())
+
+				res.Code = 500
+				res.Content = "Internal error"
+				res.Send()
+			} else {
+				a.serve(next, res, req, p, claims)
+			}
+		} else if authHeader != "" {
+			bearerToken := strings.Split(authHeader, " ")
+			claims := &models.CustomClaims{}
+
+			// Tokens of other audiences are meant for a single purpose, e.g.
+			// login challenges or password resets.
+			if len(bearerToken) != 2 || a.Verifier.Verify(bearerToken[1], claims) != nil || claims.Audience != auth.AccessAudience || claims.Issuer != auth.Issuer {
+				res.Code = 400
+				res.Content = "Invalid authorization token"
+				res.Send()
+				return
+			}
+
+			if revoked, err := a.isRevoked(claims); err != nil {
+				log.Printf("Error while checking token revocation: %s", err.Error())
+
+				res.Code = 500
+				res.Content = "Internal error"
+				res.Send()
+			} else if revoked {
+				res.Code = 400
+				res.Content = "Token has been revoked"
+				res.Send()
+			} else {
+				a.serve(next, res, req, p, claims)
+			}
+		} else if claims, ok := a.clientCertClaims(req); ok {
+			a.serve(next, res, req, p, claims)
+		} else {
+			res.Code = 400
+			res.Content = "An authorization header is required"
+			res.Send()
+		}
+	}
+}
+
+// Passes the request with the claims to the next handler unless the user has
+// been disabled.
+func (a *Auth) serve(next httprouter.Handle, res *models.JsonResponse, req *http.Request, p httprouter.Params, claims *models.CustomClaims) {
+	disabled := false
+
+	if a.Users != nil {
+		var err error
+		disabled, err = a.Users.IsDisabled(claims)
+
+		if err != nil {
+			log.Printf("Error while checking user status: %s", err.Error())
+
+			res.Code = 500
+			res.Content = "Internal error"
+			res.Send()
+			return
+		}
+	}
+
+	if disabled {
+		res.Code = 403
+		res.Content = "User is disabled"
+		res.Send()
+		return
+	}
+
+	next(res.Response, req.WithContext(ContextWithClaims(req.Context(), claims)), p)
+}
+
+// Returns the API key sent with the request, if API keys are accepted.
+func (a *Auth) apiKey(req *http.Request) string {
+	if a.ApiKeys == nil {
+		return ""
+	}
+
+	if key := req.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+
+	scheme, key, _ := strings.Cut(req.Header.Get("authorization"), " ")
+	if strings.EqualFold(scheme, "ApiKey") {
+		return key
+	}
+
+	return ""
+}
+
+// Returns whether the verified token has been revoked.
+func (a *Auth) isRevoked(claims *models.CustomClaims) (bool, error) {
+	if a.Revocations == nil {
+		return false, nil
+	}
+
+	return a.Revocations.IsRevoked(claims)
+}
+
+// Returns claims for the subject of a verified client certificate. They carry
+// no user id and token id, since certificate identities are no users, so
+// disabling users and revoking tokens does not apply to them. Their access
+// ends with the certificate or the trust in its CA.
+func (a *Auth) clientCertClaims(req *http.Request) (*models.CustomClaims, bool) {
+	if !a.ClientCertIdentity || req.TLS == nil || len(req.TLS.VerifiedChains) == 0 {
+		return nil, false
+	}
+
+	subject := req.TLS.VerifiedChains[0][0].Subject.CommonName
+	if subject == "" {
+		return nil, false
+	}
+
+	return &models.CustomClaims{UserName: subject, Role: a.ClientCertRole}, true
 }
@@ -0,0 +1,41 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+// Only passes requests of users having the given role or a more privileged
+// one. Must be wrapped by AuthMiddleWare, which stores the claims.
+func RequireRole(role models.Role, next httprouter.Handle) httprouter.Handle {
+	return require(func(claims *models.CustomClaims) bool {
+		return claims.Role.Includes(role)
+	}, next)
+}
+
+// Only passes requests of users whose role grants the given permission. For
+// API keys, the permission must also be within the key's scopes. Must be
+// wrapped by AuthMiddleWare, which stores the claims.
+func RequirePermission(permission models.Permission, next httprouter.Handle) httprouter.Handle {
+	return require(func(claims *models.CustomClaims) bool {
+		return claims.Can(permission)
+	}, next)
+}
+
+func require(allowed func(claims *models.CustomClaims) bool, next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, p httprouter.Params) {
+		claims, ok := ClaimsFromContext(req.Context())
+
+		if !ok || !allowed(claims) {
+			res := models.NewJsonResponse(w)
+			res.Code = 403
+			res.Content = "Insufficient permissions"
+			res.Send()
+			return
+		}
+
+		next(w, req, p)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/kluddizz/maintenance-rest-service/config"
+	"github.com/kluddizz/maintenance-rest-service/migrations"
+	"github.com/kluddizz/maintenance-rest-service/repositories"
+)
+
+// Handles `migrate [up|down|status] [steps]`. Up applies all pending
+// migrations by default, down reverts the latest one.
+func migrate(dbConfig *config.DatabaseConfig, args []string) error {
+	db, dialect, err := repositories.Open(dbConfig)
+	if err != nil {
+		return err
+	}
+
+	defer db.Close()
+
+	migrator, err := migrations.New(db, dialect)
+	if err != nil {
+		return err
+	}
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	steps := 0
+	if command == "down" {
+		steps = 1
+	}
+
+	if len(args) > 1 {
+		steps, err = strconv.Atoi(args[1])
+		if err != nil || steps < 1 {
+			return fmt.Errorf("invalid number of steps `%s`", args[1])
+		}
+	}
+
+	var done []migrations.Migration
+
+	switch command {
+	case "up":
+		done, err = migrator.Up(steps)
+		for _, migration := range done {
+			fmt.Printf("applied %04d_%s\n", migration.Version, migration.Name)
+		}
+	case "down":
+		done, err = migrator.Down(steps)
+		for _, migration := range done {
+			fmt.Printf("reverted %04d_%s\n", migration.Version, migration.Name)
+		}
+	case "status":
+		var status []migrations.Status
+		status, err = migrator.Status()
+		for _, s := range status {
+			state := "pending"
+			if s.Applied {
+				state = "applied"
+			}
+
+			fmt.Printf("%04d_%s %s\n", s.Version, s.Name, state)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command `%s`", command)
+	}
+
+	return err
+}
@@ -0,0 +1,322 @@
+package migrations
+
+import (
+	"bytes"
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/kluddizz/maintenance-rest-service/repositories"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+type (
+	// A single versioned schema change with its up and down statements.
+	Migration struct {
+		Version int
+		Name    string
+		Up      string
+		Down    string
+	}
+
+	// Reports whether a migration has been applied to the database.
+	Status struct {
+		Migration
+		Applied bool
+	}
+
+	// Applies and reverts migrations on a database of the given dialect.
+	Migrator struct {
+		Db         *sql.DB
+		Dialect    repositories.Dialect
+		Migrations []Migration
+	}
+
+	// Column types used by the migration templates.
+	columnTypes struct {
+		// Name of the driver, for statements which differ between dialects
+		// beyond their column types.
+		Driver string
+
+		Serial string
+		String string
+		Text   string
+		Bytes  string
+		Bool   string
+	}
+)
+
+// Column types of each supported driver.
+var dialectTypes = map[string]columnTypes{
+	"mysql": {
+		Driver: "mysql",
+		Serial: "INT AUTO_INCREMENT PRIMARY KEY",
+		String: "VARCHAR(255)",
+		Text:   "TEXT",
+		Bytes:  "VARBINARY(255)",
+		Bool:   "BOOLEAN",
+	},
+	"sqlite": {
+		Driver: "sqlite",
+		Serial: "INTEGER PRIMARY KEY AUTOINCREMENT",
+		String: "TEXT",
+		Text:   "TEXT",
+		Bytes:  "BLOB",
+		Bool:   "BOOLEAN",
+	},
+	"postgres": {
+		Driver: "postgres",
+		Serial: "SERIAL PRIMARY KEY",
+		String: "VARCHAR(255)",
+		Text:   "TEXT",
+		Bytes:  "BYTEA",
+		Bool:   "BOOLEAN",
+	},
+}
+
+// Creates a new migrator holding the embedded migrations rendered for the
+// dialect of the database.
+func New(db *sql.DB, dialect repositories.Dialect) (*Migrator, error) {
+	types, ok := dialectTypes[dialect.DriverName()]
+	if !ok {
+		return nil, fmt.Errorf("no migrations for driver `%s`", dialect.DriverName())
+	}
+
+	migrations, err := load(types)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		Db:         db,
+		Dialect:    dialect,
+		Migrations: migrations,
+	}, nil
+}
+
+// Applies the given number of pending migrations, or all of them if steps is
+// not positive, and returns the applied migrations.
+func (m *Migrator) Up(steps int) ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range m.Migrations {
+		if applied[migration.Version] {
+			continue
+		}
+
+		if steps > 0 && len(done) == steps {
+			break
+		}
+
+		err := m.run(migration.Up,
+			"INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+			migration.Version, migration.Name, time.Now().Unix(),
+		)
+
+		if err != nil {
+			return done, fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// Reverts the given number of applied migrations, starting with the latest
+// one, and returns the reverted migrations.
+func (m *Migrator) Down(steps int) ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(m.Migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := m.Migrations[i]
+		if !applied[migration.Version] {
+			continue
+		}
+
+		err := m.run(migration.Down,
+			"DELETE FROM schema_migrations WHERE version = ?",
+			migration.Version,
+		)
+
+		if err != nil {
+			return done, fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// Returns every known migration together with its state.
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var status []Status
+	for _, migration := range m.Migrations {
+		status = append(status, Status{
+			Migration: migration,
+			Applied:   applied[migration.Version],
+		})
+	}
+
+	return status, nil
+}
+
+// Creates the schema_migrations table if needed and returns the applied versions.
+func (m *Migrator) applied() (map[int]bool, error) {
+	_, err := m.Db.Exec(
+		"CREATE TABLE IF NOT EXISTS schema_migrations (" +
+			"version BIGINT PRIMARY KEY, " +
+			"name VARCHAR(255) NOT NULL, " +
+			"applied_at BIGINT NOT NULL)",
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := m.Db.Query("SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+
+		applied[version] = true
+	}
+
+	return applied, rows.Err()
+}
+
+// Executes the statements of a migration and records the change inside one
+// transaction. MySQL commits DDL statements implicitly, so there only data
+// changes are rolled back. Its migrations therefore contain at most one DDL
+// statement, which either fails as a whole or is followed by the record.
+func (m *Migrator) run(statements string, record string, args ...interface{}) error {
+	tx, err := m.Db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, statement := range split(statements) {
+		if _, err := tx.Exec(statement); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(m.Dialect.Rebind(record), args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Reads and renders the embedded migration files, ordered by version.
+func load(types columnTypes) ([]Migration, error) {
+	entries, err := files.ReadDir("sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		// File names follow the pattern <version>_<name>.<up|down>.sql
+		name := strings.TrimSuffix(entry.Name(), ".sql")
+		direction := path.Ext(name)
+		name = strings.TrimSuffix(name, direction)
+
+		parts := strings.SplitN(name, "_", 2)
+		version, err := strconv.Atoi(parts[0])
+		if err != nil || len(parts) != 2 {
+			return nil, fmt.Errorf("invalid migration file name `%s`", entry.Name())
+		}
+
+		content, err := render(path.Join("sql", entry.Name()), types)
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: parts[1]}
+			byVersion[version] = migration
+		}
+
+		switch direction {
+		case ".up":
+			migration.Up = content
+		case ".down":
+			migration.Down = content
+		default:
+			return nil, fmt.Errorf("invalid migration direction in `%s`", entry.Name())
+		}
+	}
+
+	var migrations []Migration
+	for _, migration := range byVersion {
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Renders a migration template using the column types of a dialect.
+func render(name string, types columnTypes) (string, error) {
+	tmpl, err := template.ParseFS(files, name)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, types); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// Splits a migration file into its single statements.
+func split(statements string) []string {
+	var result []string
+	for _, statement := range strings.Split(statements, ";") {
+		if statement = strings.TrimSpace(statement); statement != "" {
+			result = append(result, statement)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,245 @@
+package migrations
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kluddizz/maintenance-rest-service/config"
+	"github.com/kluddizz/maintenance-rest-service/repositories"
+)
+
+// Test if all migrations can be applied and reverted again.
+func TestUpAndDown(t *testing.T) {
+	testUpAndDown(t, &config.DatabaseConfig{
+		Driver:   config.DriverSQLite,
+		Database: filepath.Join(t.TempDir(), "test.db"),
+	})
+}
+
+// Test if all migrations can be applied and reverted on the MySQL database
+// of the config file named by TEST_MYSQL_CONFIG.
+func TestUpAndDownMySQL(t *testing.T) {
+	testUpAndDown(t, databaseConfig(t, "TEST_MYSQL_CONFIG", config.DriverMySQL))
+}
+
+// Test if all migrations can be applied and reverted on the PostgreSQL
+// database of the config file named by TEST_POSTGRES_CONFIG.
+func TestUpAndDownPostgres(t *testing.T) {
+	testUpAndDown(t, databaseConfig(t, "TEST_POSTGRES_CONFIG", config.DriverPostgres))
+}
+
+// Test if deleting a user deletes the rows that belong to it.
+func TestDeleteUserCascades(t *testing.T) {
+	testDeleteUserCascades(t, &config.DatabaseConfig{
+		Driver:   config.DriverSQLite,
+		Database: filepath.Join(t.TempDir(), "test.db"),
+	})
+}
+
+// Test if deleting a user deletes the rows that belong to it on the MySQL
+// database of the config file named by TEST_MYSQL_CONFIG.
+func TestDeleteUserCascadesMySQL(t *testing.T) {
+	testDeleteUserCascades(t, databaseConfig(t, "TEST_MYSQL_CONFIG", config.DriverMySQL))
+}
+
+// Test if deleting a user deletes the rows that belong to it on the
+// PostgreSQL database of the config file named by TEST_POSTGRES_CONFIG.
+func TestDeleteUserCascadesPostgres(t *testing.T) {
+	testDeleteUserCascades(t, databaseConfig(t, "TEST_POSTGRES_CONFIG", config.DriverPostgres))
+}
+
+// Test if every dialect has both directions of every migration, if MySQL
+// migrations contain at most one DDL statement, which MySQL cannot roll back,
+// and if the MySQL foreign key of master owners is dropped with its column.
+func TestRenderDialects(t *testing.T) {
+	for driver, types := range dialectTypes {
+		migrations, err := load(types)
+		if err != nil {
+			t.Fatalf("Could not load migrations for %s: %s", driver, err.Error())
+		}
+
+		for _, migration := range migrations {
+			if len(split(migration.Up)) == 0 || len(split(migration.Down)) == 0 {
+				t.Errorf("Expected migration %d to have up and down statements for %s", migration.Version, driver)
+			}
+
+			for _, statements := range []string{migration.Up, migration.Down} {
+				if driver == "mysql" && ddlStatements(statements) > 1 {
+					t.Errorf("Expected migration %d to have at most one DDL statement for mysql", migration.Version)
+				}
+			}
+
+			if migration.Version != 7 {
+				continue
+			}
+
+			down := split(migration.Down)
+			dropsKey := len(down) == 1 && strings.Contains(down[0], "DROP FOREIGN KEY masters_owner_id_fk")
+
+			if dropsKey != (driver == "mysql") {
+				t.Errorf("Expected foreign key to be dropped only for mysql but received %v for %s", down, driver)
+			}
+
+			if !strings.Contains(migration.Up, "masters_owner_id_fk") {
+				t.Errorf("Expected foreign key to be named for %s", driver)
+			}
+		}
+	}
+}
+
+// Applies all migrations, applies them again and reverts all of them.
+func testUpAndDown(t *testing.T, dbConfig *config.DatabaseConfig) {
+	db, dialect, err := repositories.Open(dbConfig)
+
+	if err != nil {
+		t.Fatalf("Could not open database: %s", err.Error())
+	}
+
+	defer db.Close()
+
+	migrator, err := New(db, dialect)
+	if err != nil {
+		t.Fatalf("Could not load migrations: %s", err.Error())
+	}
+
+	applied, err := migrator.Up(0)
+	if err != nil {
+		t.Fatalf("Could not apply migrations: %s", err.Error())
+	}
+
+	if len(applied) != len(migrator.Migrations) {
+		t.Errorf("Expected %d applied migrations but received %d", len(migrator.Migrations), len(applied))
+	}
+
+	// Applying again must be a no-op.
+	if applied, _ := migrator.Up(0); len(applied) != 0 {
+		t.Errorf("Expected %d applied migrations but received %d", 0, len(applied))
+	}
+
+	reverted, err := migrator.Down(len(migrator.Migrations))
+	if err != nil {
+		t.Fatalf("Could not revert migrations: %s", err.Error())
+	}
+
+	if len(reverted) != len(migrator.Migrations) {
+		t.Errorf("Expected %d reverted migrations but received %d", len(migrator.Migrations), len(reverted))
+	}
+
+	status, err := migrator.Status()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, s := range status {
+		if s.Applied {
+			t.Errorf("Expected migration %d to be reverted", s.Version)
+		}
+	}
+}
+
+// Applies all migrations, deletes a user with dependent rows in every table
+// referencing users and reverts the migrations again.
+func testDeleteUserCascades(t *testing.T, dbConfig *config.DatabaseConfig) {
+	db, dialect, err := repositories.Open(dbConfig)
+
+	if err != nil {
+		t.Fatalf("Could not open database: %s", err.Error())
+	}
+
+	defer db.Close()
+
+	migrator, err := New(db, dialect)
+	if err != nil {
+		t.Fatalf("Could not load migrations: %s", err.Error())
+	}
+
+	if _, err := migrator.Up(0); err != nil {
+		t.Fatalf("Could not apply migrations: %s", err.Error())
+	}
+
+	defer func() {
+		if _, err := migrator.Down(len(migrator.Migrations)); err != nil {
+			t.Errorf("Could not revert migrations: %s", err.Error())
+		}
+	}()
+
+	userId, err := dialect.Insert(db, "INSERT INTO users (username, password) VALUES (?, ?)", "cascade", []byte("password"))
+	if err != nil {
+		t.Fatalf("Could not create user: %s", err.Error())
+	}
+
+	masterId, err := dialect.Insert(db, "INSERT INTO masters (name, host, port) VALUES (?, ?, ?)", "cascade", "localhost", 8080)
+	if err != nil {
+		t.Fatalf("Could not create master: %s", err.Error())
+	}
+
+	dependents := []struct {
+		table string
+		query string
+		args  []interface{}
+	}{
+		{"refresh_tokens", "INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, created_at) VALUES (?, ?, ?, ?, ?)", []interface{}{userId, "family", "hash", 0, 0}},
+		{"master_shares", "INSERT INTO master_shares (master_id, user_id, access) VALUES (?, ?, ?)", []interface{}{masterId, userId, "read"}},
+		{"api_keys", "INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, created_at) VALUES (?, ?, ?, ?, ?, ?)", []interface{}{userId, "key", "prefix", "hash", "", 0}},
+		{"user_totp", "INSERT INTO user_totp (user_id, secret, confirmed, last_used_step, created_at) VALUES (?, ?, ?, ?, ?)", []interface{}{userId, "secret", true, 0, 0}},
+		{"recovery_codes", "INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)", []interface{}{userId, "hash"}},
+		{"user_identities", "INSERT INTO user_identities (issuer, subject, user_id) VALUES (?, ?, ?)", []interface{}{"issuer", "subject", userId}},
+	}
+
+	for _, d := range dependents {
+		if _, err := db.Exec(dialect.Rebind(d.query), d.args...); err != nil {
+			t.Fatalf("Could not insert into %s: %s", d.table, err.Error())
+		}
+	}
+
+	if _, err := db.Exec(dialect.Rebind("DELETE FROM users WHERE id = ?"), userId); err != nil {
+		t.Fatalf("Could not delete user: %s", err.Error())
+	}
+
+	for _, d := range dependents {
+		var count int
+		row := db.QueryRow(dialect.Rebind("SELECT COUNT(*) FROM "+d.table+" WHERE user_id = ?"), userId)
+
+		if err := row.Scan(&count); err != nil {
+			t.Fatalf("Could not count rows of %s: %s", d.table, err.Error())
+		}
+
+		if count != 0 {
+			t.Errorf("Expected %d rows in %s but received %d", 0, d.table, count)
+		}
+	}
+}
+
+// Returns the number of statements changing the schema.
+func ddlStatements(statements string) int {
+	count := 0
+	for _, statement := range split(statements) {
+		keyword := strings.ToUpper(strings.Fields(statement)[0])
+		if keyword == "CREATE" || keyword == "ALTER" || keyword == "DROP" {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Reads the database config file named by the environment variable. The test
+// is skipped without it, since it needs an empty database of the driver.
+func databaseConfig(t *testing.T, variable, driver string) *config.DatabaseConfig {
+	t.Helper()
+
+	path := os.Getenv(variable)
+	if path == "" {
+		t.Skipf("%s is not set", variable)
+	}
+
+	dbConfig, err := config.ReadDatabaseConfig(path)
+	if err != nil {
+		t.Fatalf("Could not read database config: %s", err.Error())
+	}
+
+	dbConfig.Driver = driver
+	return dbConfig
+}
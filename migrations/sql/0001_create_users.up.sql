@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS users (
+	id {{.Serial}},
+	username {{.String}} NOT NULL UNIQUE,
+	password {{.Bytes}} NOT NULL,
+	firstName {{.String}},
+	lastName {{.String}},
+	email {{.String}}
+);
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS masters (
+	id {{.Serial}},
+	name {{.String}} NOT NULL UNIQUE,
+	host {{.String}} NOT NULL,
+	port INTEGER NOT NULL
+);
@@ -0,0 +1,15 @@
+CREATE TABLE refresh_tokens (
+	id {{.Serial}},
+	user_id INTEGER NOT NULL,
+	family_id {{.String}} NOT NULL,
+	token_hash {{.String}} NOT NULL UNIQUE,
+	expires_at BIGINT NOT NULL,
+	created_at BIGINT NOT NULL,
+	used_at BIGINT,
+	revoked_at BIGINT,
+	FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE{{if eq .Driver "mysql"}},
+	INDEX refresh_tokens_family_id (family_id){{end}}
+);
+{{if ne .Driver "mysql"}}
+CREATE INDEX refresh_tokens_family_id ON refresh_tokens (family_id);
+{{end}}
\ No newline at end of file
@@ -0,0 +1,4 @@
+CREATE TABLE revoked_tokens (
+	token_id {{.String}} NOT NULL PRIMARY KEY,
+	expires_at BIGINT NOT NULL
+);
@@ -0,0 +1,4 @@
+CREATE TABLE user_revocations (
+	user_id INTEGER NOT NULL PRIMARY KEY,
+	revoked_before BIGINT NOT NULL
+);
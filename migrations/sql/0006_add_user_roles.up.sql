@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN role {{.String}} NOT NULL DEFAULT 'viewer';
+
+UPDATE users SET role = 'operator';
@@ -0,0 +1,5 @@
+{{if eq .Driver "mysql"}}
+ALTER TABLE masters DROP FOREIGN KEY masters_owner_id_fk, DROP COLUMN owner_id;
+{{else}}
+ALTER TABLE masters DROP COLUMN owner_id;
+{{end}}
\ No newline at end of file
@@ -0,0 +1,5 @@
+{{if eq .Driver "sqlite"}}
+ALTER TABLE masters ADD COLUMN owner_id INTEGER CONSTRAINT masters_owner_id_fk REFERENCES users (id) ON DELETE SET NULL;
+{{else}}
+ALTER TABLE masters ADD COLUMN owner_id INTEGER, ADD CONSTRAINT masters_owner_id_fk FOREIGN KEY (owner_id) REFERENCES users (id) ON DELETE SET NULL;
+{{end}}
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE master_shares;
@@ -0,0 +1,11 @@
+CREATE TABLE master_shares (
+	master_id INTEGER NOT NULL,
+	user_id INTEGER NOT NULL,
+	access {{.String}} NOT NULL,
+	PRIMARY KEY (master_id, user_id),
+	FOREIGN KEY (master_id) REFERENCES masters (id) ON DELETE CASCADE,
+	FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+{{if ne .Driver "mysql"}}
+CREATE INDEX master_shares_user_id ON master_shares (user_id);
+{{end}}
\ No newline at end of file
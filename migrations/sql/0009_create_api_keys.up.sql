@@ -0,0 +1,15 @@
+CREATE TABLE api_keys (
+	id {{.Serial}},
+	user_id INTEGER NOT NULL,
+	name {{.String}} NOT NULL,
+	prefix {{.String}} NOT NULL,
+	key_hash {{.String}} NOT NULL UNIQUE,
+	scopes {{.Text}} NOT NULL,
+	created_at BIGINT NOT NULL,
+	expires_at BIGINT,
+	last_used_at BIGINT,
+	FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+{{if ne .Driver "mysql"}}
+CREATE INDEX api_keys_user_id ON api_keys (user_id);
+{{end}}
\ No newline at end of file
@@ -0,0 +1,6 @@
+CREATE TABLE login_attempts (
+	attempt_key {{.String}} NOT NULL PRIMARY KEY,
+	failures INTEGER NOT NULL,
+	last_failure BIGINT NOT NULL,
+	locked_until BIGINT NOT NULL
+);
@@ -0,0 +1,8 @@
+CREATE TABLE audit_events (
+	id {{.Serial}},
+	event {{.String}} NOT NULL,
+	subject {{.String}} NOT NULL,
+	actor {{.String}},
+	details {{.Text}},
+	created_at BIGINT NOT NULL
+);
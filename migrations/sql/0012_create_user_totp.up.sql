@@ -0,0 +1,8 @@
+CREATE TABLE user_totp (
+	user_id INTEGER NOT NULL PRIMARY KEY,
+	secret {{.String}} NOT NULL,
+	confirmed {{.Bool}} NOT NULL,
+	last_used_step BIGINT NOT NULL,
+	created_at BIGINT NOT NULL,
+	FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
@@ -0,0 +1,10 @@
+CREATE TABLE recovery_codes (
+	id {{.Serial}},
+	user_id INTEGER NOT NULL,
+	code_hash {{.String}} NOT NULL,
+	used_at BIGINT,
+	FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+{{if ne .Driver "mysql"}}
+CREATE INDEX recovery_codes_user_id ON recovery_codes (user_id);
+{{end}}
\ No newline at end of file
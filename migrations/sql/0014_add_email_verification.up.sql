@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN email_verified {{.Bool}} NOT NULL DEFAULT FALSE;
@@ -0,0 +1,10 @@
+CREATE TABLE user_identities (
+	issuer {{.String}} NOT NULL,
+	subject {{.String}} NOT NULL,
+	user_id INTEGER NOT NULL,
+	PRIMARY KEY (issuer, subject),
+	FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+{{if ne .Driver "mysql"}}
+CREATE INDEX user_identities_user_id ON user_identities (user_id);
+{{end}}
\ No newline at end of file
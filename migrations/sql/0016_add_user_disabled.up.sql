@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN disabled {{.Bool}} NOT NULL DEFAULT FALSE;
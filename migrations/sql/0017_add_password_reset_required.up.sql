@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN password_reset_required {{.Bool}} NOT NULL DEFAULT FALSE;
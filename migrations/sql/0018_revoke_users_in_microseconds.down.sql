@@ -0,0 +1 @@
+UPDATE user_revocations SET revoked_before = (revoked_before + 999999) {{if eq .Driver "mysql"}}DIV{{else}}/{{end}} 1000000;
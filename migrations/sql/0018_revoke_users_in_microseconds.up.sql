@@ -0,0 +1 @@
+UPDATE user_revocations SET revoked_before = revoked_before * 1000000;
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"github.com/dgrijalva/jwt-go"
+)
+
+type (
+	// Claims of tokens sent by mail. Verification tokens are bound to the
+	// email, reset tokens to the password, so they become invalid once the
+	// email or the password changed.
+	AccountClaims struct {
+		UserId   int    `json:"uid"`
+		Email    string `json:"email,omitempty"`
+		Password string `json:"pwd,omitempty"`
+		jwt.StandardClaims
+	}
+
+	VerifyEmailRequest struct {
+		Token string `json:"token"`
+	}
+
+	PasswordResetRequest struct {
+		Email string `json:"email"`
+	}
+
+	PasswordResetConfirmation struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+)
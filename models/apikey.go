@@ -0,0 +1,31 @@
+package models
+
+type (
+	// A long-lived key authenticating automation clients as its user. Only the
+	// hash of the key is stored, the prefix identifies it in listings.
+	ApiKey struct {
+		Id         int          `json:"id"`
+		UserId     int          `json:"-"`
+		Name       string       `json:"name"`
+		Prefix     string       `json:"prefix"`
+		KeyHash    string       `json:"-"`
+		Scopes     []Permission `json:"scopes"`
+		CreatedAt  int64        `json:"createdAt"`
+		ExpiresAt  int64        `json:"expiresAt,omitempty"`
+		LastUsedAt int64        `json:"lastUsedAt,omitempty"`
+	}
+
+	// A newly created key, which is the only time the key itself is shown.
+	CreatedApiKey struct {
+		ApiKey
+		Key string `json:"key"`
+	}
+
+	ApiKeyRequest struct {
+		Name   string       `json:"name"`
+		Scopes []Permission `json:"scopes"`
+
+		// Unix time the key expires at, 0 for keys that do not expire.
+		ExpiresAt int64 `json:"expiresAt"`
+	}
+)
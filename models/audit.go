@@ -0,0 +1,44 @@
+package models
+
+type (
+	// A security relevant event, e.g. a lockout after failed logins.
+	AuditEvent struct {
+		Id        int    `json:"id"`
+		Event     string `json:"event"`
+		Subject   string `json:"subject"`
+		Actor     string `json:"actor,omitempty"`
+		Details   string `json:"details,omitempty"`
+		CreatedAt int64  `json:"createdAt"`
+	}
+
+	// Failed logins of a username or a client address.
+	LoginAttempt struct {
+		Key         string
+		Failures    int
+		LastFailure int64
+		LockedUntil int64
+	}
+)
+
+const (
+	AuditLockout = "lockout"
+	AuditUnlock  = "unlock"
+
+	AuditTotpEnabled          = "totp_enabled"
+	AuditTotpDisabled         = "totp_disabled"
+	AuditRecoveryCodeUsed     = "recovery_code_used"
+	AuditRecoveryCodesRenewed = "recovery_codes_renewed"
+
+	AuditEmailVerified  = "email_verified"
+	AuditPasswordReset  = "password_reset"
+	AuditPasswordChange = "password_changed"
+
+	AuditUserProvisioned = "user_provisioned"
+	AuditRoleChanged     = "role_changed"
+
+	AuditUserDisabled       = "user_disabled"
+	AuditUserEnabled        = "user_enabled"
+	AuditUserDeleted        = "user_deleted"
+	AuditPasswordResetForce = "password_reset_forced"
+	AuditImpersonation      = "impersonation"
+)
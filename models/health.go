@@ -0,0 +1,20 @@
+package models
+
+const (
+	HealthOk          = "ok"
+	HealthUnavailable = "unavailable"
+)
+
+type (
+	HealthStatus struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks,omitempty"`
+	}
+
+	BuildInfo struct {
+		Version   string `json:"version"`
+		Commit    string `json:"commit"`
+		BuildDate string `json:"buildDate"`
+		GoVersion string `json:"goVersion"`
+	}
+)
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"github.com/dgrijalva/jwt-go"
+)
+
+type (
+	// Links a user to the subject of an external identity provider.
+	Identity struct {
+		Issuer  string
+		Subject string
+		UserId  int
+	}
+
+	// Claims of the cookie keeping the state of a login at an identity
+	// provider until the provider redirects back.
+	OidcStateClaims struct {
+		State    string `json:"state"`
+		Nonce    string `json:"nonce"`
+		Verifier string `json:"verifier"`
+		jwt.StandardClaims
+	}
+)
@@ -2,9 +2,12 @@ package models
 
 type (
 	Master struct {
-		Id   int `json:"id"`
+		Id   int    `json:"id"`
 		Name string `json:"name"`
 		Host string `json:"host"`
 		Port int    `json:"port"`
+
+		// Id of the user who created the master, 0 if unknown.
+		OwnerId int `json:"ownerId,omitempty"`
 	}
 )
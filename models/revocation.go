@@ -0,0 +1,17 @@
+package models
+
+type (
+	// An access token which has been revoked before it expired, identified
+	// by its `jti` claim.
+	RevokedToken struct {
+		TokenId   string
+		ExpiresAt int64
+	}
+
+	// Invalidates all access tokens of a user issued before the given time in
+	// microseconds.
+	UserRevocation struct {
+		UserId        int
+		RevokedBefore int64
+	}
+)
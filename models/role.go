@@ -0,0 +1,86 @@
+package models
+
+type (
+	// The role of a user, which grants a fixed set of permissions. Every role
+	// includes the permissions of the roles below it.
+	Role string
+
+	Permission string
+
+	RoleRequest struct {
+		Role Role `json:"role"`
+	}
+)
+
+const (
+	RoleViewer   Role = "viewer"
+	RoleOperator Role = "operator"
+	RoleAdmin    Role = "admin"
+)
+
+const (
+	PermissionReadMasters  Permission = "masters:read"
+	PermissionWriteMasters Permission = "masters:write"
+	PermissionManageUsers  Permission = "users:manage"
+)
+
+// All known permissions.
+var Permissions = []Permission{PermissionReadMasters, PermissionWriteMasters, PermissionManageUsers}
+
+// Roles ordered from the least to the most privileged one.
+var Roles = []Role{RoleViewer, RoleOperator, RoleAdmin}
+
+// Permissions granted by each role in addition to those of the lower roles.
+var rolePermissions = map[Role][]Permission{
+	RoleViewer:   {PermissionReadMasters},
+	RoleOperator: {PermissionWriteMasters},
+	RoleAdmin:    {PermissionManageUsers},
+}
+
+// Returns whether the role is one of the known roles.
+func (r Role) Valid() bool {
+	return r.rank() >= 0
+}
+
+// Returns whether the role is the given role or a more privileged one.
+func (r Role) Includes(other Role) bool {
+	return r.Valid() && r.rank() >= other.rank()
+}
+
+// Returns whether the role grants the given permission.
+func (r Role) Can(permission Permission) bool {
+	for _, role := range Roles {
+		if !r.Includes(role) {
+			break
+		}
+
+		for _, p := range rolePermissions[role] {
+			if p == permission {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Returns whether the permission is one of the known permissions.
+func (p Permission) Valid() bool {
+	for _, permission := range Permissions {
+		if permission == p {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r Role) rank() int {
+	for i, role := range Roles {
+		if role == r {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,49 @@
+package models
+
+type (
+	// The access a user has to a master. Masters are shared at read or write
+	// level, owners always have full access.
+	Access string
+
+	// Grants a user access to a master of another user.
+	MasterShare struct {
+		MasterId int    `json:"masterId"`
+		UserId   int    `json:"userId"`
+		Access   Access `json:"access"`
+	}
+
+	ShareRequest struct {
+		UserName string `json:"username"`
+		Access   Access `json:"access"`
+	}
+)
+
+const (
+	AccessNone  Access = ""
+	AccessRead  Access = "read"
+	AccessWrite Access = "write"
+	AccessOwner Access = "owner"
+)
+
+// Access levels ordered from the lowest to the highest one.
+var accessLevels = []Access{AccessNone, AccessRead, AccessWrite, AccessOwner}
+
+// Returns whether the access is a level masters can be shared at.
+func (a Access) Shareable() bool {
+	return a == AccessRead || a == AccessWrite
+}
+
+// Returns whether the access is the given level or a higher one.
+func (a Access) Includes(other Access) bool {
+	return a.level() >= other.level()
+}
+
+func (a Access) level() int {
+	for i, access := range accessLevels {
+		if access == a {
+			return i
+		}
+	}
+
+	return 0
+}
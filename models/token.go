@@ -0,0 +1,32 @@
+package models
+
+import (
+	"database/sql"
+)
+
+type (
+	// A pair of a short-lived access token and a long-lived refresh token.
+	TokenPair struct {
+		AccessToken  string `json:"accessToken"`
+		RefreshToken string `json:"refreshToken"`
+		TokenType    string `json:"tokenType"`
+		ExpiresIn    int64  `json:"expiresIn"`
+	}
+
+	RefreshRequest struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+
+	// A stored refresh token. Only the hash of the token is kept. All tokens
+	// replacing each other share the same family id.
+	RefreshToken struct {
+		Id        int
+		UserId    int
+		FamilyId  string
+		TokenHash string
+		ExpiresAt int64
+		CreatedAt int64
+		UsedAt    sql.NullInt64
+		RevokedAt sql.NullInt64
+	}
+)
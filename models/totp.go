@@ -0,0 +1,49 @@
+package models
+
+type (
+	// The TOTP secret of a user. The secret is only used for logins once the
+	// user confirmed it with a valid code. The last used time step prevents
+	// codes from being used twice.
+	Totp struct {
+		UserId       int
+		Secret       string
+		Confirmed    bool
+		LastUsedStep int64
+		CreatedAt    int64
+	}
+
+	// A new, not yet confirmed secret. The URI is the payload of the QR code
+	// scanned by authenticator apps.
+	TotpEnrollment struct {
+		Secret string `json:"secret"`
+		Uri    string `json:"uri"`
+
+		// PNG image of the QR code as data URI.
+		QrCode string `json:"qrCode"`
+	}
+
+	TotpRequest struct {
+		Code string `json:"code"`
+	}
+
+	// Recovery codes, which are shown only once and can be used instead of
+	// a TOTP code once each.
+	RecoveryCodes struct {
+		RecoveryCodes []string `json:"recoveryCodes"`
+	}
+
+	// Returned by the login instead of tokens if the user has to complete
+	// the login with a second factor.
+	LoginChallenge struct {
+		MfaRequired    bool   `json:"mfaRequired"`
+		ChallengeToken string `json:"challengeToken"`
+		ExpiresIn      int64  `json:"expiresIn"`
+	}
+
+	// Completes a login challenge with either a TOTP code or a recovery code.
+	ChallengeRequest struct {
+		ChallengeToken string `json:"challengeToken"`
+		Code           string `json:"code"`
+		RecoveryCode   string `json:"recoveryCode"`
+	}
+)
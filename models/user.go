@@ -14,6 +14,7 @@ type (
 		FirstName string `json:"firstName"`
 		LastName  string `json:"lastName"`
 		Email     string `json:"email"`
+		Role      Role   `json:"role,omitempty"`
 	}
 
 	UserDb struct {
@@ -23,6 +24,69 @@ type (
 		FirstName sql.NullString
 		LastName  sql.NullString
 		Email     sql.NullString
+		Role      Role
+
+		EmailVerified bool
+
+		// Disabled users cannot login and their tokens are rejected.
+		Disabled bool
+
+		// The password has to be reset before the next login.
+		PasswordResetRequired bool
+	}
+
+	// The account of a user as shown to the user, without password.
+	UserProfile struct {
+		Id            int    `json:"id"`
+		UserName      string `json:"username"`
+		FirstName     string `json:"firstName"`
+		LastName      string `json:"lastName"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"emailVerified"`
+		Role          Role   `json:"role"`
+
+		Disabled              bool `json:"disabled"`
+		PasswordResetRequired bool `json:"passwordResetRequired"`
+	}
+
+	// Filters and paginates the list of users.
+	UserQuery struct {
+		// Part of username, name or email, case-insensitive.
+		Search string
+		Limit  int
+		Offset int
+	}
+
+	// A page of users and the number of all users matching the query.
+	UserPage struct {
+		Users  []UserProfile `json:"users"`
+		Total  int           `json:"total"`
+		Limit  int           `json:"limit"`
+		Offset int           `json:"offset"`
+	}
+
+	UserStatusRequest struct {
+		Disabled bool `json:"disabled"`
+	}
+
+	// A short-lived access token of a user issued to an admin, which cannot
+	// be refreshed.
+	ImpersonationToken struct {
+		AccessToken string `json:"accessToken"`
+		TokenType   string `json:"tokenType"`
+		ExpiresIn   int64  `json:"expiresIn"`
+	}
+
+	// Changes of a profile. Fields which are not sent stay unchanged.
+	ProfileUpdate struct {
+		FirstName *string `json:"firstName"`
+		LastName  *string `json:"lastName"`
+		Email     *string `json:"email"`
+	}
+
+	PasswordChange struct {
+		CurrentPassword string `json:"currentPassword"`
+		NewPassword     string `json:"newPassword"`
 	}
 
 	LoginCredentials struct {
@@ -30,8 +94,67 @@ type (
 		Password string `json:"password"`
 	}
 
+	// Claims of issued access tokens. Every token carries a unique `jti`
+	// claim (StandardClaims.Id), so it can be revoked before it expires.
 	CustomClaims struct {
 		UserName string `json:"username"`
+		UserId   int    `json:"uid,omitempty"`
+		Role     Role   `json:"role,omitempty"`
+
+		// Set if an admin acts as the user, e.g. for a support case.
+		ImpersonatorId   int    `json:"impersonatorId,omitempty"`
+		ImpersonatorName string `json:"impersonator,omitempty"`
+
+		// Issue time in microseconds, so revoking the tokens of a user does
+		// not revoke tokens issued later within the same second.
+		IssuedAtMicro int64 `json:"iatMicro,omitempty"`
+
 		jwt.StandardClaims
+
+		// Set if the request is authenticated by an API key, whose scopes
+		// restrict the permissions of the role. Never part of a token.
+		ApiKeyId int          `json:"-"`
+		Scopes   []Permission `json:"-"`
 	}
 )
+
+// Returns the profile of the user.
+func (u UserDb) Profile() UserProfile {
+	return UserProfile{
+		Id:            u.Id,
+		UserName:      u.UserName,
+		FirstName:     u.FirstName.String,
+		LastName:      u.LastName.String,
+		Email:         u.Email.String,
+		EmailVerified: u.EmailVerified,
+		Role:          u.Role,
+
+		Disabled:              u.Disabled,
+		PasswordResetRequired: u.PasswordResetRequired,
+	}
+}
+
+// Returns whether an admin acts as the user.
+func (c *CustomClaims) Impersonated() bool {
+	return c.ImpersonatorId != 0
+}
+
+// Returns whether the role grants the permission and, for API keys, the
+// permission is within the key's scopes.
+func (c *CustomClaims) Can(permission Permission) bool {
+	if !c.Role.Can(permission) {
+		return false
+	}
+
+	if c.ApiKeyId == 0 {
+		return true
+	}
+
+	for _, scope := range c.Scopes {
+		if scope == permission {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,191 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/kluddizz/maintenance-rest-service/config"
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+type (
+	// Hides the differences between the supported database systems.
+	Dialect interface {
+		// Returns the name of the driver registered in database/sql.
+		DriverName() string
+
+		// Rewrites a query using `?` placeholders into the dialect's syntax.
+		Rebind(query string) string
+
+		// Maps driver specific errors to the errors of this package.
+		MapError(err error) error
+
+		// Executes an insert statement and returns the generated id.
+		Insert(db *sql.DB, query string, args ...interface{}) (int, error)
+	}
+
+	MySQLDialect    struct{}
+	SQLiteDialect   struct{}
+	PostgresDialect struct{}
+)
+
+const (
+	// MySQL error number of a duplicate entry in a unique index.
+	mysqlDuplicateEntry = 1062
+
+	// PostgreSQL error code of a unique constraint violation.
+	postgresUniqueViolation = "23505"
+)
+
+// Returns the dialect for the given driver name.
+func DialectFor(driver string) (Dialect, error) {
+	switch driver {
+	case config.DriverMySQL:
+		return MySQLDialect{}, nil
+	case config.DriverSQLite:
+		return SQLiteDialect{}, nil
+	case config.DriverPostgres:
+		return PostgresDialect{}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported database driver `%s`", driver)
+}
+
+// Opens the database described by the configuration and returns its dialect.
+func Open(dbConfig *config.DatabaseConfig) (*sql.DB, Dialect, error) {
+	dialect, err := DialectFor(dbConfig.DriverName())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	db, err := sql.Open(dialect.DriverName(), dbConfig.DataSourceName())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// SQLite only allows a single writer, so serialize access to the file.
+	if _, ok := dialect.(SQLiteDialect); ok {
+		db.SetMaxOpenConns(1)
+	}
+
+	return db, dialect, nil
+}
+
+func (MySQLDialect) DriverName() string {
+	return "mysql"
+}
+
+func (MySQLDialect) Rebind(query string) string {
+	return query
+}
+
+func (MySQLDialect) MapError(err error) error {
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == mysqlDuplicateEntry {
+		return ErrDuplicate
+	}
+
+	return err
+}
+
+func (MySQLDialect) Insert(db *sql.DB, query string, args ...interface{}) (int, error) {
+	return lastInsertId(db, query, args...)
+}
+
+func (SQLiteDialect) DriverName() string {
+	return "sqlite"
+}
+
+func (SQLiteDialect) Rebind(query string) string {
+	return query
+}
+
+func (SQLiteDialect) MapError(err error) error {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) &&
+		(sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE ||
+			sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY) {
+		return ErrDuplicate
+	}
+
+	return err
+}
+
+func (SQLiteDialect) Insert(db *sql.DB, query string, args ...interface{}) (int, error) {
+	return lastInsertId(db, query, args...)
+}
+
+func (PostgresDialect) DriverName() string {
+	return "postgres"
+}
+
+// Replaces every `?` placeholder outside of string literals with `$n`.
+func (PostgresDialect) Rebind(query string) string {
+	var builder strings.Builder
+	inString := false
+	n := 0
+
+	for _, c := range query {
+		switch {
+		case c == '\'':
+			inString = !inString
+		case c == '?' && !inString:
+			n++
+			builder.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+
+		builder.WriteRune(c)
+	}
+
+	return builder.String()
+}
+
+func (PostgresDialect) MapError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == postgresUniqueViolation {
+		return ErrDuplicate
+	}
+
+	return err
+}
+
+// PostgreSQL does not support LastInsertId, so the id is returned by the
+// statement itself.
+func (d PostgresDialect) Insert(db *sql.DB, query string, args ...interface{}) (int, error) {
+	var id int
+	err := db.QueryRow(d.Rebind(query)+" RETURNING id", args...).Scan(&id)
+
+	return id, err
+}
+
+// Executes the insert statement and reads the id generated by the database.
+func lastInsertId(db *sql.DB, query string, args ...interface{}) (int, error) {
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// Returns ErrNotFound if the statement did not touch any row.
+func expectAffected(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,13 @@
+package repositories
+
+import "testing"
+
+// Test if MySQL style placeholders are rewritten for PostgreSQL.
+func TestPostgresRebind(t *testing.T) {
+	query := PostgresDialect{}.Rebind("UPDATE masters SET name = ?, host = '?' WHERE id = ?")
+	expected := "UPDATE masters SET name = $1, host = '?' WHERE id = $2"
+
+	if query != expected {
+		t.Errorf("Expected query to be %s but received %s", expected, query)
+	}
+}
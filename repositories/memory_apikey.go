@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+type (
+	MemoryApiKeyRepository struct {
+		mutex  sync.Mutex
+		nextId int
+		keys   map[int]models.ApiKey
+	}
+)
+
+// Creates a new API key repository which keeps all keys in memory.
+func NewMemoryApiKeyRepository() *MemoryApiKeyRepository {
+	return &MemoryApiKeyRepository{
+		nextId: 1,
+		keys:   make(map[int]models.ApiKey),
+	}
+}
+
+// Stores a new key and sets its generated id.
+func (r *MemoryApiKeyRepository) Create(key *models.ApiKey) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.keys {
+		if existing.KeyHash == key.KeyHash {
+			return ErrDuplicate
+		}
+	}
+
+	key.Id = r.nextId
+	r.nextId++
+	r.keys[key.Id] = *key
+
+	return nil
+}
+
+// Returns the key with the given hash.
+func (r *MemoryApiKeyRepository) FindByHash(hash string) (models.ApiKey, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, key := range r.keys {
+		if key.KeyHash == hash {
+			return key, nil
+		}
+	}
+
+	return models.ApiKey{}, ErrNotFound
+}
+
+// Returns all keys of the given user ordered by their id.
+func (r *MemoryApiKeyRepository) ListByUser(userId int) ([]models.ApiKey, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var keys []models.ApiKey
+	for _, key := range r.keys {
+		if key.UserId == userId {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Id < keys[j].Id
+	})
+
+	return keys, nil
+}
+
+// Records the time the key was last used at.
+func (r *MemoryApiKeyRepository) Touch(id int, at int64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if key, ok := r.keys[id]; ok {
+		key.LastUsedAt = at
+		r.keys[id] = key
+	}
+
+	return nil
+}
+
+// Removes a key of the given user.
+func (r *MemoryApiKeyRepository) Delete(id int, userId int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if key, ok := r.keys[id]; !ok || key.UserId != userId {
+		return ErrNotFound
+	}
+
+	delete(r.keys, id)
+	return nil
+}
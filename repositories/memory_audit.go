@@ -0,0 +1,43 @@
+package repositories
+
+import (
+	"sync"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+type (
+	MemoryAuditRepository struct {
+		mutex  sync.Mutex
+		events []models.AuditEvent
+	}
+)
+
+// Creates a new audit repository which keeps all events in memory.
+func NewMemoryAuditRepository() *MemoryAuditRepository {
+	return &MemoryAuditRepository{}
+}
+
+// Stores a new event and sets its generated id.
+func (r *MemoryAuditRepository) Record(event *models.AuditEvent) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	event.Id = len(r.events) + 1
+	r.events = append(r.events, *event)
+
+	return nil
+}
+
+// Returns the latest events, newest first.
+func (r *MemoryAuditRepository) List(limit int) ([]models.AuditEvent, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var events []models.AuditEvent
+	for i := len(r.events) - 1; i >= 0 && len(events) < limit; i-- {
+		events = append(events, r.events[i])
+	}
+
+	return events, nil
+}
@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"sync"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+type (
+	MemoryIdentityRepository struct {
+		mutex      sync.Mutex
+		identities map[[2]string]models.Identity
+	}
+)
+
+// Creates a new identity repository which keeps all links in memory.
+func NewMemoryIdentityRepository() *MemoryIdentityRepository {
+	return &MemoryIdentityRepository{
+		identities: make(map[[2]string]models.Identity),
+	}
+}
+
+// Returns the link of the subject of the given issuer.
+func (r *MemoryIdentityRepository) Find(issuer, subject string) (models.Identity, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	identity, ok := r.identities[[2]string{issuer, subject}]
+	if !ok {
+		return identity, ErrNotFound
+	}
+
+	return identity, nil
+}
+
+// Links a subject to a user.
+func (r *MemoryIdentityRepository) Create(identity models.Identity) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := [2]string{identity.Issuer, identity.Subject}
+	if _, ok := r.identities[key]; ok {
+		return ErrDuplicate
+	}
+
+	r.identities[key] = identity
+	return nil
+}
+
+// Unlinks the subject of the given issuer.
+func (r *MemoryIdentityRepository) Delete(issuer, subject string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := [2]string{issuer, subject}
+	if _, ok := r.identities[key]; !ok {
+		return ErrNotFound
+	}
+
+	delete(r.identities, key)
+	return nil
+}
+
+// Returns whether any subject is linked to the user.
+func (r *MemoryIdentityRepository) Linked(userId int) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, identity := range r.identities {
+		if identity.UserId == userId {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"sync"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+type (
+	MemoryLoginAttemptRepository struct {
+		mutex    sync.Mutex
+		attempts map[string]models.LoginAttempt
+	}
+)
+
+// Creates a new login attempt repository which keeps all attempts in memory.
+func NewMemoryLoginAttemptRepository() *MemoryLoginAttemptRepository {
+	return &MemoryLoginAttemptRepository{
+		attempts: make(map[string]models.LoginAttempt),
+	}
+}
+
+// Returns the failed logins of the key.
+func (r *MemoryLoginAttemptRepository) Get(key string) (models.LoginAttempt, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	attempt, ok := r.attempts[key]
+	if !ok {
+		return attempt, ErrNotFound
+	}
+
+	return attempt, nil
+}
+
+// Counts a failed login of the key and returns the updated failures.
+func (r *MemoryLoginAttemptRepository) Fail(key string, at, resetBefore int64) (models.LoginAttempt, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	attempt, ok := r.attempts[key]
+	if !ok || (attempt.LastFailure < resetBefore && attempt.LockedUntil <= at) {
+		attempt = models.LoginAttempt{Key: key, LockedUntil: attempt.LockedUntil}
+	}
+
+	attempt.Failures++
+	attempt.LastFailure = at
+
+	r.attempts[key] = attempt
+	return attempt, nil
+}
+
+// Locks the key until the given time, unless it is still locked.
+func (r *MemoryLoginAttemptRepository) Lock(key string, at, until int64) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	attempt, ok := r.attempts[key]
+	if !ok || attempt.LockedUntil > at {
+		return false, nil
+	}
+
+	attempt.LockedUntil = until
+	r.attempts[key] = attempt
+	return true, nil
+}
+
+// Forgets the failed logins of the key.
+func (r *MemoryLoginAttemptRepository) Delete(key string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.attempts, key)
+	return nil
+}
@@ -0,0 +1,136 @@
+package repositories
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+type (
+	MemoryMasterRepository struct {
+		mutex   sync.Mutex
+		nextId  int
+		masters map[int]models.Master
+
+		// Access of users to masters by master id and user id.
+		shares map[int]map[int]models.Access
+	}
+)
+
+// Creates a new master repository which keeps all masters in memory.
+func NewMemoryMasterRepository() *MemoryMasterRepository {
+	return &MemoryMasterRepository{
+		nextId:  1,
+		masters: make(map[int]models.Master),
+		shares:  make(map[int]map[int]models.Access),
+	}
+}
+
+// Returns all stored masters ordered by their id.
+func (r *MemoryMasterRepository) List() ([]models.Master, error) {
+	return r.filter(func(master models.Master) bool {
+		return true
+	})
+}
+
+// Returns the masters owned by or shared with the given user.
+func (r *MemoryMasterRepository) ListAccessible(userId int) ([]models.Master, error) {
+	return r.filter(func(master models.Master) bool {
+		_, shared := r.shares[master.Id][userId]
+		return master.OwnerId == userId || shared
+	})
+}
+
+// Returns the masters matching the filter ordered by their id.
+func (r *MemoryMasterRepository) filter(matches func(models.Master) bool) ([]models.Master, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var masters []models.Master
+	for _, master := range r.masters {
+		if matches(master) {
+			masters = append(masters, master)
+		}
+	}
+
+	sort.Slice(masters, func(i, j int) bool {
+		return masters[i].Id < masters[j].Id
+	})
+
+	return masters, nil
+}
+
+// Returns the master identified by the given id.
+func (r *MemoryMasterRepository) Get(id int) (models.Master, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	master, ok := r.masters[id]
+	if !ok {
+		return master, ErrNotFound
+	}
+
+	return master, nil
+}
+
+// Stores a new master and sets its generated id.
+func (r *MemoryMasterRepository) Create(master *models.Master) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.nameTaken(master.Name, 0) {
+		return ErrDuplicate
+	}
+
+	master.Id = r.nextId
+	r.nextId++
+	r.masters[master.Id] = *master
+
+	return nil
+}
+
+// Updates the stored master with the same id.
+func (r *MemoryMasterRepository) Update(master models.Master) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	existing, ok := r.masters[master.Id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	if r.nameTaken(master.Name, master.Id) {
+		return ErrDuplicate
+	}
+
+	// The owner never changes
+	master.OwnerId = existing.OwnerId
+	r.masters[master.Id] = master
+	return nil
+}
+
+// Removes the master identified by the given id.
+func (r *MemoryMasterRepository) Delete(id int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.masters[id]; !ok {
+		return ErrNotFound
+	}
+
+	delete(r.masters, id)
+	delete(r.shares, id)
+	return nil
+}
+
+// Checks whether another master than the one with the given id uses the name.
+func (r *MemoryMasterRepository) nameTaken(name string, id int) bool {
+	for _, master := range r.masters {
+		if master.Name == name && master.Id != id {
+			return true
+		}
+	}
+
+	return false
+}
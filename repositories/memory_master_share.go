@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"sort"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+// Returns the access a master has been shared with the user at.
+func (r *MemoryMasterRepository) Access(masterId, userId int) (models.Access, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	access, ok := r.shares[masterId][userId]
+	if !ok {
+		return access, ErrNotFound
+	}
+
+	return access, nil
+}
+
+// Returns all users a master has been shared with.
+func (r *MemoryMasterRepository) ListShares(masterId int) ([]models.MasterShare, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var shares []models.MasterShare
+	for userId, access := range r.shares[masterId] {
+		shares = append(shares, models.MasterShare{MasterId: masterId, UserId: userId, Access: access})
+	}
+
+	sort.Slice(shares, func(i, j int) bool {
+		return shares[i].UserId < shares[j].UserId
+	})
+
+	return shares, nil
+}
+
+// Shares a master with a user or changes the access of an existing share.
+func (r *MemoryMasterRepository) Share(share models.MasterShare) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.masters[share.MasterId]; !ok {
+		return ErrNotFound
+	}
+
+	if r.shares[share.MasterId] == nil {
+		r.shares[share.MasterId] = make(map[int]models.Access)
+	}
+
+	r.shares[share.MasterId][share.UserId] = share.Access
+	return nil
+}
+
+// Removes the share of a master with a user.
+func (r *MemoryMasterRepository) Unshare(masterId, userId int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.shares[masterId][userId]; !ok {
+		return ErrNotFound
+	}
+
+	delete(r.shares[masterId], userId)
+	return nil
+}
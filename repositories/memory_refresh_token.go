@@ -0,0 +1,100 @@
+package repositories
+
+import (
+	"database/sql"
+	"sync"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+type (
+	MemoryRefreshTokenRepository struct {
+		mutex  sync.Mutex
+		nextId int
+		tokens map[int]models.RefreshToken
+	}
+)
+
+// Creates a new refresh token repository which keeps all tokens in memory.
+func NewMemoryRefreshTokenRepository() *MemoryRefreshTokenRepository {
+	return &MemoryRefreshTokenRepository{
+		nextId: 1,
+		tokens: make(map[int]models.RefreshToken),
+	}
+}
+
+// Stores a new refresh token and sets its generated id.
+func (r *MemoryRefreshTokenRepository) Create(token *models.RefreshToken) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.tokens {
+		if existing.TokenHash == token.TokenHash {
+			return ErrDuplicate
+		}
+	}
+
+	token.Id = r.nextId
+	r.nextId++
+	r.tokens[token.Id] = *token
+
+	return nil
+}
+
+// Returns the refresh token with the given hash.
+func (r *MemoryRefreshTokenRepository) FindByHash(hash string) (models.RefreshToken, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, token := range r.tokens {
+		if token.TokenHash == hash {
+			return token, nil
+		}
+	}
+
+	return models.RefreshToken{}, ErrNotFound
+}
+
+// Marks an unused token as used.
+func (r *MemoryRefreshTokenRepository) MarkUsed(id int, at int64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	token, ok := r.tokens[id]
+	if !ok || token.UsedAt.Valid {
+		return ErrNotFound
+	}
+
+	token.UsedAt = sql.NullInt64{Int64: at, Valid: true}
+	r.tokens[id] = token
+
+	return nil
+}
+
+// Revokes all tokens of a family.
+func (r *MemoryRefreshTokenRepository) RevokeFamily(familyId string, at int64) error {
+	return r.revoke(func(token models.RefreshToken) bool {
+		return token.FamilyId == familyId
+	}, at)
+}
+
+// Revokes all tokens of a user.
+func (r *MemoryRefreshTokenRepository) RevokeUser(userId int, at int64) error {
+	return r.revoke(func(token models.RefreshToken) bool {
+		return token.UserId == userId
+	}, at)
+}
+
+func (r *MemoryRefreshTokenRepository) revoke(matches func(models.RefreshToken) bool, at int64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for id, token := range r.tokens {
+		if matches(token) && !token.RevokedAt.Valid {
+			token.RevokedAt = sql.NullInt64{Int64: at, Valid: true}
+			r.tokens[id] = token
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package repositories
+
+import (
+	"sync"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+type (
+	MemoryRevocationRepository struct {
+		mutex  sync.Mutex
+		tokens map[string]int64
+		users  map[int]int64
+	}
+)
+
+// Creates a new revocation repository which keeps all revocations in memory.
+func NewMemoryRevocationRepository() *MemoryRevocationRepository {
+	return &MemoryRevocationRepository{
+		tokens: make(map[string]int64),
+		users:  make(map[int]int64),
+	}
+}
+
+// Stores a revoked token. Revoking a token twice is not an error.
+func (r *MemoryRevocationRepository) RevokeToken(token models.RevokedToken) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.tokens[token.TokenId]; !ok {
+		r.tokens[token.TokenId] = token.ExpiresAt
+	}
+
+	return nil
+}
+
+// Stores or replaces the revocation of a user's tokens.
+func (r *MemoryRevocationRepository) RevokeUser(revocation models.UserRevocation) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.users[revocation.UserId] = revocation.RevokedBefore
+	return nil
+}
+
+// Returns all revoked tokens which have not expired at the given time.
+func (r *MemoryRevocationRepository) ListTokens(now int64) ([]models.RevokedToken, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var tokens []models.RevokedToken
+	for id, expiresAt := range r.tokens {
+		if expiresAt > now {
+			tokens = append(tokens, models.RevokedToken{TokenId: id, ExpiresAt: expiresAt})
+		}
+	}
+
+	return tokens, nil
+}
+
+// Returns all user revocations.
+func (r *MemoryRevocationRepository) ListUsers() ([]models.UserRevocation, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var revocations []models.UserRevocation
+	for id, revokedBefore := range r.users {
+		revocations = append(revocations, models.UserRevocation{UserId: id, RevokedBefore: revokedBefore})
+	}
+
+	return revocations, nil
+}
+
+// Removes revoked tokens expired at the given time and user revocations older
+// than the given time.
+func (r *MemoryRevocationRepository) DeleteExpired(now int64, revokedBefore int64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for id, expiresAt := range r.tokens {
+		if expiresAt <= now {
+			delete(r.tokens, id)
+		}
+	}
+
+	for id, before := range r.users {
+		if before <= revokedBefore {
+			delete(r.users, id)
+		}
+	}
+
+	return nil
+}
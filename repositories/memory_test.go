@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"testing"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+// Test if the in-memory master repository behaves like the database one.
+func TestMemoryMasterRepository(t *testing.T) {
+	repo := NewMemoryMasterRepository()
+	master := models.Master{Name: "Test Master", Host: "127.0.0.1", Port: 5050}
+
+	if err := repo.Create(&master); err != nil {
+		t.Fatalf("Could not create master: %s", err.Error())
+	}
+
+	if master.Id == 0 {
+		t.Errorf("Expected master id to be set but received %d", master.Id)
+	}
+
+	// Names have to be unique.
+	duplicate := models.Master{Name: master.Name}
+	if err := repo.Create(&duplicate); err != ErrDuplicate {
+		t.Errorf("Expected error to be %v but received %v", ErrDuplicate, err)
+	}
+
+	master.Name = "Updated Master"
+	if err := repo.Update(master); err != nil {
+		t.Fatalf("Could not update master: %s", err.Error())
+	}
+
+	stored, err := repo.Get(master.Id)
+	if err != nil || stored != master {
+		t.Errorf("Expected master to be %v but received %v (%v)", master, stored, err)
+	}
+
+	if err := repo.Delete(master.Id); err != nil {
+		t.Fatalf("Could not delete master: %s", err.Error())
+	}
+
+	if err := repo.Delete(master.Id); err != ErrNotFound {
+		t.Errorf("Expected error to be %v but received %v", ErrNotFound, err)
+	}
+}
+
+// Test if users can be found by their username after creation.
+func TestMemoryUserRepository(t *testing.T) {
+	repo := NewMemoryUserRepository()
+	user := models.User{UserName: "testuser", Email: "test@example.com"}
+
+	if err := repo.Create(&user, []byte("hash")); err != nil {
+		t.Fatalf("Could not create user: %s", err.Error())
+	}
+
+	if err := repo.Create(&models.User{UserName: "testuser"}, nil); err != ErrDuplicate {
+		t.Errorf("Expected error to be %v but received %v", ErrDuplicate, err)
+	}
+
+	stored, err := repo.FindByUsername("testuser")
+	if err != nil {
+		t.Fatalf("Could not find user: %s", err.Error())
+	}
+
+	if stored.Id != user.Id || string(stored.Password) != "hash" || stored.Email.String != user.Email {
+		t.Errorf("Expected user to be %v but received %v", user, stored)
+	}
+
+	if _, err := repo.FindByUsername("unknown"); err != ErrNotFound {
+		t.Errorf("Expected error to be %v but received %v", ErrNotFound, err)
+	}
+}
@@ -0,0 +1,120 @@
+package repositories
+
+import (
+	"sync"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+type (
+	MemoryTotpRepository struct {
+		mutex         sync.Mutex
+		secrets       map[int]models.Totp
+		recoveryCodes map[int]map[string]bool
+	}
+)
+
+// Creates a new TOTP repository which keeps all secrets in memory.
+func NewMemoryTotpRepository() *MemoryTotpRepository {
+	return &MemoryTotpRepository{
+		secrets:       make(map[int]models.Totp),
+		recoveryCodes: make(map[int]map[string]bool),
+	}
+}
+
+// Returns the secret of the user.
+func (r *MemoryTotpRepository) Get(userId int) (models.Totp, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	totp, ok := r.secrets[userId]
+	if !ok {
+		return totp, ErrNotFound
+	}
+
+	return totp, nil
+}
+
+// Stores or replaces the secret of the user.
+func (r *MemoryTotpRepository) Save(totp models.Totp) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.secrets[totp.UserId] = totp
+	return nil
+}
+
+// Enables the secret of the user for logins.
+func (r *MemoryTotpRepository) Confirm(userId int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	totp, ok := r.secrets[userId]
+	if !ok || totp.Confirmed {
+		return ErrNotFound
+	}
+
+	totp.Confirmed = true
+	r.secrets[userId] = totp
+
+	return nil
+}
+
+// Records the time step of a used code unless a later one was used before.
+func (r *MemoryTotpRepository) UseStep(userId int, step int64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	totp, ok := r.secrets[userId]
+	if !ok || totp.LastUsedStep >= step {
+		return ErrNotFound
+	}
+
+	totp.LastUsedStep = step
+	r.secrets[userId] = totp
+
+	return nil
+}
+
+// Removes the secret and the recovery codes of the user.
+func (r *MemoryTotpRepository) Delete(userId int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.secrets[userId]; !ok {
+		return ErrNotFound
+	}
+
+	delete(r.secrets, userId)
+	delete(r.recoveryCodes, userId)
+
+	return nil
+}
+
+// Replaces all recovery codes of the user.
+func (r *MemoryTotpRepository) ReplaceRecoveryCodes(userId int, hashes []string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	codes := make(map[string]bool)
+	for _, hash := range hashes {
+		codes[hash] = false
+	}
+
+	r.recoveryCodes[userId] = codes
+	return nil
+}
+
+// Marks an unused recovery code as used.
+func (r *MemoryTotpRepository) UseRecoveryCode(userId int, hash string, at int64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	used, ok := r.recoveryCodes[userId][hash]
+	if !ok || used {
+		return ErrNotFound
+	}
+
+	r.recoveryCodes[userId][hash] = true
+	return nil
+}
@@ -0,0 +1,273 @@
+package repositories
+
+import (
+	"bytes"
+	"database/sql"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+type (
+	MemoryUserRepository struct {
+		mutex  sync.Mutex
+		nextId int
+		users  map[int]models.UserDb
+	}
+)
+
+// Creates a new user repository which keeps all users in memory.
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{
+		nextId: 1,
+		users:  make(map[int]models.UserDb),
+	}
+}
+
+// Returns the user with the given username.
+func (r *MemoryUserRepository) FindByUsername(username string) (models.UserDb, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, user := range r.users {
+		if user.UserName == username {
+			return user, nil
+		}
+	}
+
+	return models.UserDb{}, ErrNotFound
+}
+
+// Returns the user with the given id.
+func (r *MemoryUserRepository) FindById(id int) (models.UserDb, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return user, ErrNotFound
+	}
+
+	return user, nil
+}
+
+// Returns all users with the given email ordered by their id.
+func (r *MemoryUserRepository) ListByEmail(email string) ([]models.UserDb, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var users []models.UserDb
+	for _, user := range r.users {
+		if user.Email.Valid && user.Email.String == email {
+			users = append(users, user)
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Id < users[j].Id
+	})
+
+	return users, nil
+}
+
+// Returns a page of users whose username, name or email contains the search.
+func (r *MemoryUserRepository) List(query models.UserQuery) ([]models.UserDb, int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	search := strings.ToLower(query.Search)
+
+	var users []models.UserDb
+	for _, user := range r.users {
+		fields := []string{user.UserName, user.FirstName.String, user.LastName.String, user.Email.String}
+
+		for _, field := range fields {
+			if strings.Contains(strings.ToLower(field), search) {
+				users = append(users, user)
+				break
+			}
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Id < users[j].Id
+	})
+
+	total := len(users)
+	start := min(query.Offset, total)
+	end := min(start+query.Limit, total)
+
+	return append([]models.UserDb{}, users[start:end]...), total, nil
+}
+
+// Returns the number of enabled users with the role.
+func (r *MemoryUserRepository) CountRole(role models.Role) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	count := 0
+	for _, user := range r.users {
+		if user.Role == role && !user.Disabled {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// Stores a new user with an already hashed password and sets its generated id.
+func (r *MemoryUserRepository) Create(user *models.User, hashedPassword []byte) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.users {
+		if existing.UserName == user.UserName {
+			return ErrDuplicate
+		}
+	}
+
+	user.Id = r.nextId
+	r.nextId++
+	r.users[user.Id] = models.UserDb{
+		Id:        user.Id,
+		UserName:  user.UserName,
+		Password:  hashedPassword,
+		FirstName: nullString(user.FirstName),
+		LastName:  nullString(user.LastName),
+		Email:     nullString(user.Email),
+		Role:      user.Role,
+	}
+
+	return nil
+}
+
+// Changes the role of the user identified by the given id.
+func (r *MemoryUserRepository) SetRole(id int, role models.Role) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	user.Role = role
+	r.users[id] = user
+
+	return nil
+}
+
+// Enables or disables the user identified by the given id.
+func (r *MemoryUserRepository) SetDisabled(id int, disabled bool) error {
+	return r.update(id, func(user *models.UserDb) {
+		user.Disabled = disabled
+	})
+}
+
+// Replaces the password hash of the user identified by the given id and
+// clears a required password reset.
+func (r *MemoryUserRepository) SetPassword(id int, hashedPassword []byte) error {
+	return r.update(id, func(user *models.UserDb) {
+		user.Password = hashedPassword
+		user.PasswordResetRequired = false
+	})
+}
+
+// Replaces the password hash of the user identified by the given id if it
+// still equals the previous one.
+func (r *MemoryUserRepository) ReplacePassword(id int, previous, hashedPassword []byte) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	user, ok := r.users[id]
+	if !ok || !bytes.Equal(user.Password, previous) {
+		return ErrNotFound
+	}
+
+	user.Password = hashedPassword
+	user.PasswordResetRequired = false
+	r.users[id] = user
+
+	return nil
+}
+
+// Requires the user identified by the given id to reset the password.
+func (r *MemoryUserRepository) RequirePasswordReset(id int) error {
+	return r.update(id, func(user *models.UserDb) {
+		user.PasswordResetRequired = true
+	})
+}
+
+// Changes the stored user identified by the given id.
+func (r *MemoryUserRepository) update(id int, change func(*models.UserDb)) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	change(&user)
+	r.users[id] = user
+
+	return nil
+}
+
+// Marks the email of the user as verified if it is still the given one.
+func (r *MemoryUserRepository) VerifyEmail(id int, email string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	user, ok := r.users[id]
+	if !ok || user.Email.String != email {
+		return ErrNotFound
+	}
+
+	user.EmailVerified = true
+	r.users[id] = user
+
+	return nil
+}
+
+// Replaces the profile fields of the user identified by its id.
+func (r *MemoryUserRepository) UpdateProfile(user *models.User) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored, ok := r.users[user.Id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	if stored.Email.String != user.Email {
+		stored.EmailVerified = false
+	}
+
+	stored.FirstName = nullString(user.FirstName)
+	stored.LastName = nullString(user.LastName)
+	stored.Email = nullString(user.Email)
+	r.users[user.Id] = stored
+
+	return nil
+}
+
+// Removes the user identified by the given id.
+func (r *MemoryUserRepository) Delete(id int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.users[id]; !ok {
+		return ErrNotFound
+	}
+
+	delete(r.users, id)
+	return nil
+}
+
+// Converts a string into a nullable string, treating empty strings as NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
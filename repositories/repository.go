@@ -0,0 +1,179 @@
+package repositories
+
+import (
+	"errors"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+var (
+	// Returned when the requested record does not exist.
+	ErrNotFound = errors.New("record not found")
+
+	// Returned when a record violates a uniqueness constraint.
+	ErrDuplicate = errors.New("record already exists")
+)
+
+type (
+	// Describes the storage of master endpoints.
+	MasterRepository interface {
+		List() ([]models.Master, error)
+		ListAccessible(userId int) ([]models.Master, error)
+		Get(id int) (models.Master, error)
+		Create(master *models.Master) error
+		Update(master models.Master) error
+		Delete(id int) error
+	}
+
+	// Describes the storage of masters shared with other users than their
+	// owners.
+	MasterShareRepository interface {
+		// Returns ErrNotFound if the master is not shared with the user.
+		Access(masterId, userId int) (models.Access, error)
+		ListShares(masterId int) ([]models.MasterShare, error)
+		Share(share models.MasterShare) error
+		Unshare(masterId, userId int) error
+	}
+
+	// Describes the storage of users and their password hashes.
+	UserRepository interface {
+		FindByUsername(username string) (models.UserDb, error)
+		FindById(id int) (models.UserDb, error)
+		ListByEmail(email string) ([]models.UserDb, error)
+
+		// Returns a page of users ordered by id and the number of all users
+		// matching the search.
+		List(query models.UserQuery) ([]models.UserDb, int, error)
+
+		// Returns the number of enabled users with the role.
+		CountRole(role models.Role) (int, error)
+
+		Create(user *models.User, hashedPassword []byte) error
+		SetRole(id int, role models.Role) error
+		SetDisabled(id int, disabled bool) error
+
+		// Replaces the password hash and clears a required password reset.
+		SetPassword(id int, hashedPassword []byte) error
+
+		// Replaces the password hash like SetPassword if it still equals the
+		// previous one. Returns ErrNotFound if the password changed.
+		ReplacePassword(id int, previous, hashedPassword []byte) error
+
+		// Requires the user to reset the password before the next login.
+		RequirePasswordReset(id int) error
+
+		// Replaces first name, last name and email of the user. A changed
+		// email is no longer verified.
+		UpdateProfile(user *models.User) error
+
+		// Marks the email of the user as verified. Returns ErrNotFound if the
+		// user changed the email in the meantime.
+		VerifyEmail(id int, email string) error
+		Delete(id int) error
+	}
+
+	// Describes the storage of hashed refresh tokens, grouped in families of
+	// tokens which replaced each other.
+	RefreshTokenRepository interface {
+		Create(token *models.RefreshToken) error
+		FindByHash(hash string) (models.RefreshToken, error)
+
+		// Marks an unused token as used. Returns ErrNotFound if the token was
+		// already used.
+		MarkUsed(id int, at int64) error
+
+		RevokeFamily(familyId string, at int64) error
+		RevokeUser(userId int, at int64) error
+	}
+
+	// Describes the storage of revoked access tokens. Revocations are kept
+	// until the revoked tokens would have expired anyway.
+	RevocationRepository interface {
+		RevokeToken(token models.RevokedToken) error
+		RevokeUser(revocation models.UserRevocation) error
+
+		// Returns all revoked tokens which have not expired at the given time.
+		ListTokens(now int64) ([]models.RevokedToken, error)
+		ListUsers() ([]models.UserRevocation, error)
+
+		// Removes revoked tokens expired at the given time and user
+		// revocations older than the given time in microseconds.
+		DeleteExpired(now int64, revokedBefore int64) error
+	}
+
+	// Describes the storage of hashed API keys.
+	ApiKeyRepository interface {
+		Create(key *models.ApiKey) error
+		FindByHash(hash string) (models.ApiKey, error)
+		ListByUser(userId int) ([]models.ApiKey, error)
+
+		// Records the time the key was last used at.
+		Touch(id int, at int64) error
+
+		// Removes a key of the given user.
+		Delete(id int, userId int) error
+	}
+
+	// Describes the storage of failed logins by username or client address.
+	LoginAttemptRepository interface {
+		// Returns ErrNotFound if there are no failed logins for the key.
+		Get(key string) (models.LoginAttempt, error)
+
+		// Atomically counts a failed login of the key at the given unix
+		// time and returns the updated failures. Failures of an unlocked key
+		// whose last failure was before resetBefore are forgotten first.
+		Fail(key string, at, resetBefore int64) (models.LoginAttempt, error)
+
+		// Locks the key until the given unix time, unless it is still
+		// locked at the given time. Returns whether the key was locked.
+		Lock(key string, at, until int64) (bool, error)
+
+		Delete(key string) error
+	}
+
+	// Describes the storage of TOTP secrets and hashed recovery codes.
+	TotpRepository interface {
+		// Returns ErrNotFound if the user has no secret.
+		Get(userId int) (models.Totp, error)
+
+		// Stores or replaces the secret of the user.
+		Save(totp models.Totp) error
+		Confirm(userId int) error
+
+		// Records the time step of a used code. Returns ErrNotFound if the
+		// step is not later than the last used one.
+		UseStep(userId int, step int64) error
+
+		// Removes the secret and the recovery codes of the user.
+		Delete(userId int) error
+
+		// Replaces all recovery codes of the user.
+		ReplaceRecoveryCodes(userId int, hashes []string) error
+
+		// Marks an unused recovery code as used. Returns ErrNotFound if the
+		// user has no such unused code.
+		UseRecoveryCode(userId int, hash string, at int64) error
+	}
+
+	// Describes the storage of links between users and the subjects of
+	// external identity providers.
+	IdentityRepository interface {
+		// Returns ErrNotFound if the subject is not linked to a user.
+		Find(issuer, subject string) (models.Identity, error)
+		Create(identity models.Identity) error
+
+		// Unlinks the subject. Returns ErrNotFound if it is not linked.
+		Delete(issuer, subject string) error
+
+		// Returns whether any subject is linked to the user.
+		Linked(userId int) (bool, error)
+	}
+
+	// Describes the storage of audit events.
+	AuditRepository interface {
+		Record(event *models.AuditEvent) error
+
+		// Returns the latest events, newest first.
+		List(limit int) ([]models.AuditEvent, error)
+	}
+)
@@ -0,0 +1,137 @@
+package repositories
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+type (
+	SqlApiKeyRepository struct {
+		Db      *sql.DB
+		Dialect Dialect
+	}
+)
+
+// Creates a new API key repository backed by an SQL database of the given dialect.
+func NewSqlApiKeyRepository(db *sql.DB, dialect Dialect) *SqlApiKeyRepository {
+	return &SqlApiKeyRepository{
+		Db:      db,
+		Dialect: dialect,
+	}
+}
+
+// Columns selected for every key, in the order scanned by scanApiKey.
+const apiKeyColumns = "id, user_id, name, prefix, key_hash, scopes, created_at, expires_at, last_used_at"
+
+// Stores a new key and sets its generated id.
+func (r *SqlApiKeyRepository) Create(key *models.ApiKey) error {
+	id, err := r.Dialect.Insert(
+		r.Db,
+		"INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		key.UserId, key.Name, key.Prefix, key.KeyHash, joinScopes(key.Scopes), key.CreatedAt,
+		sql.NullInt64{Int64: key.ExpiresAt, Valid: key.ExpiresAt != 0},
+	)
+
+	if err != nil {
+		return r.Dialect.MapError(err)
+	}
+
+	key.Id = id
+	return nil
+}
+
+// Returns the key with the given hash.
+func (r *SqlApiKeyRepository) FindByHash(hash string) (models.ApiKey, error) {
+	row := r.Db.QueryRow(
+		r.Dialect.Rebind("SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash = ?"),
+		hash,
+	)
+
+	key, err := scanApiKey(row)
+	if err == sql.ErrNoRows {
+		return key, ErrNotFound
+	}
+
+	return key, err
+}
+
+// Returns all keys of the given user.
+func (r *SqlApiKeyRepository) ListByUser(userId int) ([]models.ApiKey, error) {
+	rows, err := r.Db.Query(
+		r.Dialect.Rebind("SELECT "+apiKeyColumns+" FROM api_keys WHERE user_id = ? ORDER BY id"),
+		userId,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var keys []models.ApiKey
+	for rows.Next() {
+		key, err := scanApiKey(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// Records the time the key was last used at.
+func (r *SqlApiKeyRepository) Touch(id int, at int64) error {
+	_, err := r.Db.Exec(r.Dialect.Rebind("UPDATE api_keys SET last_used_at = ? WHERE id = ?"), at, id)
+	return err
+}
+
+// Removes a key of the given user.
+func (r *SqlApiKeyRepository) Delete(id int, userId int) error {
+	result, err := r.Db.Exec(r.Dialect.Rebind("DELETE FROM api_keys WHERE id = ? AND user_id = ?"), id, userId)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result)
+}
+
+// Reads a key selected with apiKeyColumns.
+func scanApiKey(row interface{ Scan(...interface{}) error }) (models.ApiKey, error) {
+	var key models.ApiKey
+	var scopes string
+	var expiresAt, lastUsedAt sql.NullInt64
+
+	err := row.Scan(
+		&key.Id, &key.UserId, &key.Name, &key.Prefix, &key.KeyHash, &scopes,
+		&key.CreatedAt, &expiresAt, &lastUsedAt,
+	)
+
+	key.Scopes = splitScopes(scopes)
+	key.ExpiresAt = expiresAt.Int64
+	key.LastUsedAt = lastUsedAt.Int64
+
+	return key, err
+}
+
+// Stores scopes as space separated list.
+func joinScopes(scopes []models.Permission) string {
+	values := make([]string, len(scopes))
+	for i, scope := range scopes {
+		values[i] = string(scope)
+	}
+
+	return strings.Join(values, " ")
+}
+
+func splitScopes(scopes string) []models.Permission {
+	var permissions []models.Permission
+	for _, scope := range strings.Fields(scopes) {
+		permissions = append(permissions, models.Permission(scope))
+	}
+
+	return permissions
+}
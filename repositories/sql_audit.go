@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"database/sql"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+type (
+	SqlAuditRepository struct {
+		Db      *sql.DB
+		Dialect Dialect
+	}
+)
+
+// Creates a new audit repository backed by an SQL database of the given dialect.
+func NewSqlAuditRepository(db *sql.DB, dialect Dialect) *SqlAuditRepository {
+	return &SqlAuditRepository{
+		Db:      db,
+		Dialect: dialect,
+	}
+}
+
+// Stores a new event and sets its generated id.
+func (r *SqlAuditRepository) Record(event *models.AuditEvent) error {
+	id, err := r.Dialect.Insert(
+		r.Db,
+		"INSERT INTO audit_events (event, subject, actor, details, created_at) VALUES (?, ?, ?, ?, ?)",
+		event.Event, event.Subject, nullString(event.Actor), nullString(event.Details), event.CreatedAt,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	event.Id = id
+	return nil
+}
+
+// Returns the latest events, newest first.
+func (r *SqlAuditRepository) List(limit int) ([]models.AuditEvent, error) {
+	rows, err := r.Db.Query(
+		r.Dialect.Rebind("SELECT id, event, subject, actor, details, created_at FROM audit_events ORDER BY id DESC LIMIT ?"),
+		limit,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var events []models.AuditEvent
+	for rows.Next() {
+		var event models.AuditEvent
+		var actor, details sql.NullString
+
+		err := rows.Scan(&event.Id, &event.Event, &event.Subject, &actor, &details, &event.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		event.Actor = actor.String
+		event.Details = details.String
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
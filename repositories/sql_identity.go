@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"database/sql"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+type (
+	SqlIdentityRepository struct {
+		Db      *sql.DB
+		Dialect Dialect
+	}
+)
+
+// Creates a new identity repository backed by an SQL database of the given dialect.
+func NewSqlIdentityRepository(db *sql.DB, dialect Dialect) *SqlIdentityRepository {
+	return &SqlIdentityRepository{
+		Db:      db,
+		Dialect: dialect,
+	}
+}
+
+// Returns the link of the subject of the given issuer.
+func (r *SqlIdentityRepository) Find(issuer, subject string) (models.Identity, error) {
+	var identity models.Identity
+
+	err := r.Db.QueryRow(
+		r.Dialect.Rebind("SELECT issuer, subject, user_id FROM user_identities WHERE issuer = ? AND subject = ?"),
+		issuer, subject,
+	).Scan(
+		&identity.Issuer, &identity.Subject, &identity.UserId,
+	)
+
+	if err == sql.ErrNoRows {
+		return identity, ErrNotFound
+	}
+
+	return identity, err
+}
+
+// Links a subject to a user.
+func (r *SqlIdentityRepository) Create(identity models.Identity) error {
+	_, err := r.Db.Exec(
+		r.Dialect.Rebind("INSERT INTO user_identities (issuer, subject, user_id) VALUES (?, ?, ?)"),
+		identity.Issuer, identity.Subject, identity.UserId,
+	)
+
+	return r.Dialect.MapError(err)
+}
+
+// Unlinks the subject of the given issuer.
+func (r *SqlIdentityRepository) Delete(issuer, subject string) error {
+	result, err := r.Db.Exec(
+		r.Dialect.Rebind("DELETE FROM user_identities WHERE issuer = ? AND subject = ?"),
+		issuer, subject,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result)
+}
+
+// Returns whether any subject is linked to the user.
+func (r *SqlIdentityRepository) Linked(userId int) (bool, error) {
+	var count int
+
+	err := r.Db.QueryRow(
+		r.Dialect.Rebind("SELECT COUNT(*) FROM user_identities WHERE user_id = ?"),
+		userId,
+	).Scan(&count)
+
+	return count > 0, err
+}
@@ -0,0 +1,108 @@
+package repositories
+
+import (
+	"database/sql"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+type (
+	SqlLoginAttemptRepository struct {
+		Db      *sql.DB
+		Dialect Dialect
+	}
+)
+
+// Creates a new login attempt repository backed by an SQL database of the given dialect.
+func NewSqlLoginAttemptRepository(db *sql.DB, dialect Dialect) *SqlLoginAttemptRepository {
+	return &SqlLoginAttemptRepository{
+		Db:      db,
+		Dialect: dialect,
+	}
+}
+
+// Returns the failed logins of the key.
+func (r *SqlLoginAttemptRepository) Get(key string) (models.LoginAttempt, error) {
+	var attempt models.LoginAttempt
+
+	err := r.Db.QueryRow(
+		r.Dialect.Rebind("SELECT attempt_key, failures, last_failure, locked_until FROM login_attempts WHERE attempt_key = ?"),
+		key,
+	).Scan(
+		&attempt.Key, &attempt.Failures, &attempt.LastFailure, &attempt.LockedUntil,
+	)
+
+	if err == sql.ErrNoRows {
+		return attempt, ErrNotFound
+	}
+
+	return attempt, err
+}
+
+// Counts a failed login of the key and returns the updated failures. The
+// counter is incremented by the database, so concurrent failures are counted
+// each.
+func (r *SqlLoginAttemptRepository) Fail(key string, at, resetBefore int64) (models.LoginAttempt, error) {
+	err := r.increment(key, at, resetBefore)
+
+	if err == ErrNotFound {
+		_, err = r.Db.Exec(
+			r.Dialect.Rebind("INSERT INTO login_attempts (attempt_key, failures, last_failure, locked_until) VALUES (?, 1, ?, 0)"),
+			key, at,
+		)
+
+		// Another failure inserted the key in the meantime.
+		if err = r.Dialect.MapError(err); err == ErrDuplicate {
+			err = r.increment(key, at, resetBefore)
+		}
+	}
+
+	if err != nil {
+		return models.LoginAttempt{}, err
+	}
+
+	return r.Get(key)
+}
+
+// Locks the key until the given time, unless it is still locked.
+func (r *SqlLoginAttemptRepository) Lock(key string, at, until int64) (bool, error) {
+	result, err := r.Db.Exec(
+		r.Dialect.Rebind("UPDATE login_attempts SET locked_until = ? WHERE attempt_key = ? AND locked_until <= ?"),
+		until, key, at,
+	)
+
+	if err != nil {
+		return false, err
+	}
+
+	if err := expectAffected(result); err == ErrNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Increments the failures of an existing key. Returns ErrNotFound if the key
+// has no failures yet. The failures are updated first, since MySQL evaluates
+// later assignments with the updated values. They always change, so MySQL
+// reports the row as affected.
+func (r *SqlLoginAttemptRepository) increment(key string, at, resetBefore int64) error {
+	result, err := r.Db.Exec(
+		r.Dialect.Rebind("UPDATE login_attempts SET failures = CASE WHEN last_failure < ? AND locked_until <= ? THEN 1 ELSE failures + 1 END, last_failure = ? WHERE attempt_key = ?"),
+		resetBefore, at, at, key,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result)
+}
+
+// Forgets the failed logins of the key.
+func (r *SqlLoginAttemptRepository) Delete(key string) error {
+	_, err := r.Db.Exec(r.Dialect.Rebind("DELETE FROM login_attempts WHERE attempt_key = ?"), key)
+	return err
+}
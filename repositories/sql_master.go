@@ -0,0 +1,132 @@
+package repositories
+
+import (
+	"database/sql"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+type (
+	SqlMasterRepository struct {
+		Db      *sql.DB
+		Dialect Dialect
+	}
+)
+
+// Creates a new master repository backed by an SQL database of the given dialect.
+func NewSqlMasterRepository(db *sql.DB, dialect Dialect) *SqlMasterRepository {
+	return &SqlMasterRepository{
+		Db:      db,
+		Dialect: dialect,
+	}
+}
+
+// Columns selected for every master, in the order scanned by scanMaster.
+const masterColumns = "id, name, host, port, owner_id"
+
+// Returns all masters stored in the database.
+func (r *SqlMasterRepository) List() ([]models.Master, error) {
+	return r.query("SELECT " + masterColumns + " FROM masters ORDER BY id")
+}
+
+// Returns the masters owned by or shared with the given user.
+func (r *SqlMasterRepository) ListAccessible(userId int) ([]models.Master, error) {
+	return r.query(
+		"SELECT "+masterColumns+" FROM masters WHERE owner_id = ? "+
+			"OR id IN (SELECT master_id FROM master_shares WHERE user_id = ?) ORDER BY id",
+		userId, userId,
+	)
+}
+
+// Returns the master identified by the given id.
+func (r *SqlMasterRepository) Get(id int) (models.Master, error) {
+	row := r.Db.QueryRow(
+		r.Dialect.Rebind("SELECT "+masterColumns+" FROM masters WHERE id = ?"),
+		id,
+	)
+
+	master, err := scanMaster(row)
+	if err == sql.ErrNoRows {
+		return master, ErrNotFound
+	}
+
+	return master, err
+}
+
+// Stores a new master and sets its generated id.
+func (r *SqlMasterRepository) Create(master *models.Master) error {
+	id, err := r.Dialect.Insert(
+		r.Db,
+		"INSERT INTO masters (name, host, port, owner_id) VALUES (?, ?, ?, ?)",
+		master.Name, master.Host, master.Port, nullInt(master.OwnerId),
+	)
+
+	if err != nil {
+		return r.Dialect.MapError(err)
+	}
+
+	master.Id = id
+	return nil
+}
+
+// Updates the stored master with the same id.
+func (r *SqlMasterRepository) Update(master models.Master) error {
+	result, err := r.Db.Exec(
+		r.Dialect.Rebind("UPDATE masters SET name = ?, host = ?, port = ? WHERE id = ?"),
+		master.Name, master.Host, master.Port, master.Id,
+	)
+
+	if err != nil {
+		return r.Dialect.MapError(err)
+	}
+
+	return expectAffected(result)
+}
+
+// Removes the master identified by the given id.
+func (r *SqlMasterRepository) Delete(id int) error {
+	result, err := r.Db.Exec(r.Dialect.Rebind("DELETE FROM masters WHERE id = ?"), id)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result)
+}
+
+// Returns all masters selected by the query.
+func (r *SqlMasterRepository) query(query string, args ...interface{}) ([]models.Master, error) {
+	rows, err := r.Db.Query(r.Dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var masters []models.Master
+	for rows.Next() {
+		master, err := scanMaster(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		masters = append(masters, master)
+	}
+
+	return masters, rows.Err()
+}
+
+// Reads a master selected with masterColumns.
+func scanMaster(row interface{ Scan(...interface{}) error }) (models.Master, error) {
+	var master models.Master
+	var ownerId sql.NullInt64
+
+	err := row.Scan(&master.Id, &master.Name, &master.Host, &master.Port, &ownerId)
+	master.OwnerId = int(ownerId.Int64)
+
+	return master, err
+}
+
+// Converts an id into a nullable integer, treating 0 as NULL.
+func nullInt(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
+}
@@ -0,0 +1,92 @@
+package repositories
+
+import (
+	"database/sql"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+// Returns the access a master has been shared with the user at.
+func (r *SqlMasterRepository) Access(masterId, userId int) (models.Access, error) {
+	var access models.Access
+
+	err := r.Db.QueryRow(
+		r.Dialect.Rebind("SELECT access FROM master_shares WHERE master_id = ? AND user_id = ?"),
+		masterId, userId,
+	).Scan(&access)
+
+	if err == sql.ErrNoRows {
+		return access, ErrNotFound
+	}
+
+	return access, err
+}
+
+// Returns all users a master has been shared with.
+func (r *SqlMasterRepository) ListShares(masterId int) ([]models.MasterShare, error) {
+	rows, err := r.Db.Query(
+		r.Dialect.Rebind("SELECT master_id, user_id, access FROM master_shares WHERE master_id = ? ORDER BY user_id"),
+		masterId,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var shares []models.MasterShare
+	for rows.Next() {
+		var share models.MasterShare
+		if err := rows.Scan(&share.MasterId, &share.UserId, &share.Access); err != nil {
+			return nil, err
+		}
+
+		shares = append(shares, share)
+	}
+
+	return shares, rows.Err()
+}
+
+// Shares a master with a user or changes the access of an existing share.
+func (r *SqlMasterRepository) Share(share models.MasterShare) error {
+	result, err := r.Db.Exec(
+		r.Dialect.Rebind("UPDATE master_shares SET access = ? WHERE master_id = ? AND user_id = ?"),
+		share.Access, share.MasterId, share.UserId,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if expectAffected(result) == nil {
+		return nil
+	}
+
+	// MySQL reports no affected rows if the value did not change, in which
+	// case the insert fails as duplicate.
+	_, err = r.Db.Exec(
+		r.Dialect.Rebind("INSERT INTO master_shares (master_id, user_id, access) VALUES (?, ?, ?)"),
+		share.MasterId, share.UserId, share.Access,
+	)
+
+	if err = r.Dialect.MapError(err); err == ErrDuplicate {
+		return nil
+	}
+
+	return err
+}
+
+// Removes the share of a master with a user.
+func (r *SqlMasterRepository) Unshare(masterId, userId int) error {
+	result, err := r.Db.Exec(
+		r.Dialect.Rebind("DELETE FROM master_shares WHERE master_id = ? AND user_id = ?"),
+		masterId, userId,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result)
+}
@@ -0,0 +1,91 @@
+package repositories
+
+import (
+	"database/sql"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+type (
+	SqlRefreshTokenRepository struct {
+		Db      *sql.DB
+		Dialect Dialect
+	}
+)
+
+// Creates a new refresh token repository backed by an SQL database of the given dialect.
+func NewSqlRefreshTokenRepository(db *sql.DB, dialect Dialect) *SqlRefreshTokenRepository {
+	return &SqlRefreshTokenRepository{
+		Db:      db,
+		Dialect: dialect,
+	}
+}
+
+// Stores a new refresh token and sets its generated id.
+func (r *SqlRefreshTokenRepository) Create(token *models.RefreshToken) error {
+	id, err := r.Dialect.Insert(
+		r.Db,
+		"INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at, created_at) VALUES (?, ?, ?, ?, ?)",
+		token.UserId, token.FamilyId, token.TokenHash, token.ExpiresAt, token.CreatedAt,
+	)
+
+	if err != nil {
+		return r.Dialect.MapError(err)
+	}
+
+	token.Id = id
+	return nil
+}
+
+// Returns the refresh token with the given hash.
+func (r *SqlRefreshTokenRepository) FindByHash(hash string) (models.RefreshToken, error) {
+	var token models.RefreshToken
+
+	err := r.Db.QueryRow(
+		r.Dialect.Rebind("SELECT id, user_id, family_id, token_hash, expires_at, created_at, used_at, revoked_at FROM refresh_tokens WHERE token_hash = ?"),
+		hash,
+	).Scan(
+		&token.Id, &token.UserId, &token.FamilyId, &token.TokenHash,
+		&token.ExpiresAt, &token.CreatedAt, &token.UsedAt, &token.RevokedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return token, ErrNotFound
+	}
+
+	return token, err
+}
+
+// Marks an unused token as used.
+func (r *SqlRefreshTokenRepository) MarkUsed(id int, at int64) error {
+	result, err := r.Db.Exec(
+		r.Dialect.Rebind("UPDATE refresh_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL"),
+		at, id,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result)
+}
+
+// Revokes all tokens of a family.
+func (r *SqlRefreshTokenRepository) RevokeFamily(familyId string, at int64) error {
+	_, err := r.Db.Exec(
+		r.Dialect.Rebind("UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL"),
+		at, familyId,
+	)
+
+	return err
+}
+
+// Revokes all tokens of a user.
+func (r *SqlRefreshTokenRepository) RevokeUser(userId int, at int64) error {
+	_, err := r.Db.Exec(
+		r.Dialect.Rebind("UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL"),
+		at, userId,
+	)
+
+	return err
+}
@@ -0,0 +1,125 @@
+package repositories
+
+import (
+	"database/sql"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+type (
+	SqlRevocationRepository struct {
+		Db      *sql.DB
+		Dialect Dialect
+	}
+)
+
+// Creates a new revocation repository backed by an SQL database of the given dialect.
+func NewSqlRevocationRepository(db *sql.DB, dialect Dialect) *SqlRevocationRepository {
+	return &SqlRevocationRepository{
+		Db:      db,
+		Dialect: dialect,
+	}
+}
+
+// Stores a revoked token. Revoking a token twice is not an error.
+func (r *SqlRevocationRepository) RevokeToken(token models.RevokedToken) error {
+	_, err := r.Db.Exec(
+		r.Dialect.Rebind("INSERT INTO revoked_tokens (token_id, expires_at) VALUES (?, ?)"),
+		token.TokenId, token.ExpiresAt,
+	)
+
+	if err = r.Dialect.MapError(err); err == ErrDuplicate {
+		return nil
+	}
+
+	return err
+}
+
+// Stores or replaces the revocation of a user's tokens.
+func (r *SqlRevocationRepository) RevokeUser(revocation models.UserRevocation) error {
+	result, err := r.Db.Exec(
+		r.Dialect.Rebind("UPDATE user_revocations SET revoked_before = ? WHERE user_id = ?"),
+		revocation.RevokedBefore, revocation.UserId,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if expectAffected(result) == nil {
+		return nil
+	}
+
+	// MySQL reports no affected rows if the value did not change, in which
+	// case the insert fails as duplicate.
+	_, err = r.Db.Exec(
+		r.Dialect.Rebind("INSERT INTO user_revocations (user_id, revoked_before) VALUES (?, ?)"),
+		revocation.UserId, revocation.RevokedBefore,
+	)
+
+	if err = r.Dialect.MapError(err); err == ErrDuplicate {
+		return nil
+	}
+
+	return err
+}
+
+// Returns all revoked tokens which have not expired at the given time.
+func (r *SqlRevocationRepository) ListTokens(now int64) ([]models.RevokedToken, error) {
+	rows, err := r.Db.Query(
+		r.Dialect.Rebind("SELECT token_id, expires_at FROM revoked_tokens WHERE expires_at > ?"),
+		now,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var tokens []models.RevokedToken
+	for rows.Next() {
+		var token models.RevokedToken
+		if err := rows.Scan(&token.TokenId, &token.ExpiresAt); err != nil {
+			return nil, err
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// Returns all user revocations.
+func (r *SqlRevocationRepository) ListUsers() ([]models.UserRevocation, error) {
+	rows, err := r.Db.Query("SELECT user_id, revoked_before FROM user_revocations")
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var revocations []models.UserRevocation
+	for rows.Next() {
+		var revocation models.UserRevocation
+		if err := rows.Scan(&revocation.UserId, &revocation.RevokedBefore); err != nil {
+			return nil, err
+		}
+
+		revocations = append(revocations, revocation)
+	}
+
+	return revocations, rows.Err()
+}
+
+// Removes revoked tokens expired at the given time and user revocations older
+// than the given time.
+func (r *SqlRevocationRepository) DeleteExpired(now int64, revokedBefore int64) error {
+	_, err := r.Db.Exec(r.Dialect.Rebind("DELETE FROM revoked_tokens WHERE expires_at <= ?"), now)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Db.Exec(r.Dialect.Rebind("DELETE FROM user_revocations WHERE revoked_before <= ?"), revokedBefore)
+	return err
+}
@@ -0,0 +1,144 @@
+package repositories
+
+import (
+	"database/sql"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+type (
+	SqlTotpRepository struct {
+		Db      *sql.DB
+		Dialect Dialect
+	}
+)
+
+// Creates a new TOTP repository backed by an SQL database of the given dialect.
+func NewSqlTotpRepository(db *sql.DB, dialect Dialect) *SqlTotpRepository {
+	return &SqlTotpRepository{
+		Db:      db,
+		Dialect: dialect,
+	}
+}
+
+// Returns the secret of the user.
+func (r *SqlTotpRepository) Get(userId int) (models.Totp, error) {
+	var totp models.Totp
+
+	err := r.Db.QueryRow(
+		r.Dialect.Rebind("SELECT user_id, secret, confirmed, last_used_step, created_at FROM user_totp WHERE user_id = ?"),
+		userId,
+	).Scan(
+		&totp.UserId, &totp.Secret, &totp.Confirmed, &totp.LastUsedStep, &totp.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return totp, ErrNotFound
+	}
+
+	return totp, err
+}
+
+// Stores or replaces the secret of the user.
+func (r *SqlTotpRepository) Save(totp models.Totp) error {
+	result, err := r.Db.Exec(
+		r.Dialect.Rebind("UPDATE user_totp SET secret = ?, confirmed = ?, last_used_step = ?, created_at = ? WHERE user_id = ?"),
+		totp.Secret, totp.Confirmed, totp.LastUsedStep, totp.CreatedAt, totp.UserId,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if expectAffected(result) == nil {
+		return nil
+	}
+
+	// MySQL reports no affected rows if the values did not change, in which
+	// case the insert fails as duplicate.
+	_, err = r.Db.Exec(
+		r.Dialect.Rebind("INSERT INTO user_totp (user_id, secret, confirmed, last_used_step, created_at) VALUES (?, ?, ?, ?, ?)"),
+		totp.UserId, totp.Secret, totp.Confirmed, totp.LastUsedStep, totp.CreatedAt,
+	)
+
+	if err = r.Dialect.MapError(err); err == ErrDuplicate {
+		return nil
+	}
+
+	return err
+}
+
+// Enables the secret of the user for logins.
+func (r *SqlTotpRepository) Confirm(userId int) error {
+	result, err := r.Db.Exec(r.Dialect.Rebind("UPDATE user_totp SET confirmed = ? WHERE user_id = ? AND confirmed = ?"), true, userId, false)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result)
+}
+
+// Records the time step of a used code unless a later one was used before.
+func (r *SqlTotpRepository) UseStep(userId int, step int64) error {
+	result, err := r.Db.Exec(
+		r.Dialect.Rebind("UPDATE user_totp SET last_used_step = ? WHERE user_id = ? AND last_used_step < ?"),
+		step, userId, step,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result)
+}
+
+// Removes the secret and the recovery codes of the user.
+func (r *SqlTotpRepository) Delete(userId int) error {
+	if _, err := r.Db.Exec(r.Dialect.Rebind("DELETE FROM recovery_codes WHERE user_id = ?"), userId); err != nil {
+		return err
+	}
+
+	result, err := r.Db.Exec(r.Dialect.Rebind("DELETE FROM user_totp WHERE user_id = ?"), userId)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result)
+}
+
+// Replaces all recovery codes of the user within one transaction.
+func (r *SqlTotpRepository) ReplaceRecoveryCodes(userId int, hashes []string) error {
+	tx, err := r.Db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(r.Dialect.Rebind("DELETE FROM recovery_codes WHERE user_id = ?"), userId); err != nil {
+		return err
+	}
+
+	for _, hash := range hashes {
+		_, err := tx.Exec(r.Dialect.Rebind("INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)"), userId, hash)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Marks an unused recovery code as used.
+func (r *SqlTotpRepository) UseRecoveryCode(userId int, hash string, at int64) error {
+	result, err := r.Db.Exec(
+		r.Dialect.Rebind("UPDATE recovery_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL"),
+		at, userId, hash,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result)
+}
@@ -0,0 +1,292 @@
+package repositories
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/kluddizz/maintenance-rest-service/models"
+)
+
+type (
+	SqlUserRepository struct {
+		Db      *sql.DB
+		Dialect Dialect
+	}
+)
+
+// Creates a new user repository backed by an SQL database of the given dialect.
+func NewSqlUserRepository(db *sql.DB, dialect Dialect) *SqlUserRepository {
+	return &SqlUserRepository{
+		Db:      db,
+		Dialect: dialect,
+	}
+}
+
+// Columns selected for every user, in the order scanned by scanUser.
+const userColumns = "id, username, password, firstname, lastname, email, role, email_verified, disabled, password_reset_required"
+
+// Returns the user with the given username.
+func (r *SqlUserRepository) FindByUsername(username string) (models.UserDb, error) {
+	return r.findBy("username", username)
+}
+
+// Returns the user with the given id.
+func (r *SqlUserRepository) FindById(id int) (models.UserDb, error) {
+	return r.findBy("id", id)
+}
+
+// Returns all users with the given email.
+func (r *SqlUserRepository) ListByEmail(email string) ([]models.UserDb, error) {
+	rows, err := r.Db.Query(
+		r.Dialect.Rebind("SELECT "+userColumns+" FROM users WHERE email = ? ORDER BY id"),
+		email,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var users []models.UserDb
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// Returns a page of users whose username, name or email contains the search.
+func (r *SqlUserRepository) List(query models.UserQuery) ([]models.UserDb, int, error) {
+	where := ""
+	var args []interface{}
+
+	if query.Search != "" {
+		// ! escapes wildcards in the search, it is no escape character in any
+		// dialect by default.
+		pattern := "%" + likeEscaper.Replace(strings.ToLower(query.Search)) + "%"
+		where = " WHERE LOWER(username) LIKE ? ESCAPE '!' OR LOWER(firstName) LIKE ? ESCAPE '!'" +
+			" OR LOWER(lastName) LIKE ? ESCAPE '!' OR LOWER(email) LIKE ? ESCAPE '!'"
+		args = []interface{}{pattern, pattern, pattern, pattern}
+	}
+
+	var total int
+	err := r.Db.QueryRow(r.Dialect.Rebind("SELECT COUNT(*) FROM users"+where), args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.Db.Query(
+		r.Dialect.Rebind("SELECT "+userColumns+" FROM users"+where+" ORDER BY id LIMIT ? OFFSET ?"),
+		append(args, query.Limit, query.Offset)...,
+	)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	defer rows.Close()
+
+	users := []models.UserDb{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		users = append(users, user)
+	}
+
+	return users, total, rows.Err()
+}
+
+// Returns the number of enabled users with the role.
+func (r *SqlUserRepository) CountRole(role models.Role) (int, error) {
+	var count int
+
+	err := r.Db.QueryRow(
+		r.Dialect.Rebind("SELECT COUNT(*) FROM users WHERE role = ? AND disabled = ?"),
+		role, false,
+	).Scan(&count)
+
+	return count, err
+}
+
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// Returns the single user whose column has the given value.
+func (r *SqlUserRepository) findBy(column string, value interface{}) (models.UserDb, error) {
+	row := r.Db.QueryRow(
+		r.Dialect.Rebind("SELECT "+userColumns+" FROM users WHERE "+column+" = ?"),
+		value,
+	)
+
+	user, err := scanUser(row)
+	if err == sql.ErrNoRows {
+		return user, ErrNotFound
+	}
+
+	return user, err
+}
+
+// Stores a new user with an already hashed password and sets its generated id.
+func (r *SqlUserRepository) Create(user *models.User, hashedPassword []byte) error {
+	id, err := r.Dialect.Insert(
+		r.Db,
+		"INSERT INTO users (username, password, firstName, lastName, email, role) VALUES (?, ?, ?, ?, ?, ?)",
+		user.UserName, hashedPassword, user.FirstName, user.LastName, user.Email, user.Role,
+	)
+
+	if err != nil {
+		return r.Dialect.MapError(err)
+	}
+
+	user.Id = id
+	return nil
+}
+
+// Changes the role of the user identified by the given id.
+func (r *SqlUserRepository) SetRole(id int, role models.Role) error {
+	result, err := r.Db.Exec(r.Dialect.Rebind("UPDATE users SET role = ? WHERE id = ?"), role, id)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result)
+}
+
+// Enables or disables the user identified by the given id.
+func (r *SqlUserRepository) SetDisabled(id int, disabled bool) error {
+	return r.setFlag("disabled", disabled, id)
+}
+
+// Replaces the password hash of the user identified by the given id and
+// clears a required password reset.
+func (r *SqlUserRepository) SetPassword(id int, hashedPassword []byte) error {
+	result, err := r.Db.Exec(
+		r.Dialect.Rebind("UPDATE users SET password = ?, password_reset_required = ? WHERE id = ?"),
+		hashedPassword, false, id,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result)
+}
+
+// Replaces the password hash of the user identified by the given id if it
+// still equals the previous one.
+func (r *SqlUserRepository) ReplacePassword(id int, previous, hashedPassword []byte) error {
+	result, err := r.Db.Exec(
+		r.Dialect.Rebind("UPDATE users SET password = ?, password_reset_required = ? WHERE id = ? AND password = ?"),
+		hashedPassword, false, id, previous,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result)
+}
+
+// Requires the user identified by the given id to reset the password.
+func (r *SqlUserRepository) RequirePasswordReset(id int) error {
+	return r.setFlag("password_reset_required", true, id)
+}
+
+// Sets a boolean column of the user identified by the given id.
+func (r *SqlUserRepository) setFlag(column string, value bool, id int) error {
+	result, err := r.Db.Exec(r.Dialect.Rebind("UPDATE users SET "+column+" = ? WHERE id = ?"), value, id)
+	if err != nil {
+		return err
+	}
+
+	// Setting a flag twice does not change the row, so MySQL reports it as
+	// unaffected.
+	if err := expectAffected(result); err != nil {
+		if _, findErr := r.FindById(id); findErr == nil {
+			return nil
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// Replaces the profile fields of the user identified by its id.
+func (r *SqlUserRepository) UpdateProfile(user *models.User) error {
+	// MySQL assigns from left to right, so email_verified has to compare the
+	// email before it is replaced.
+	result, err := r.Db.Exec(
+		r.Dialect.Rebind("UPDATE users SET email_verified = CASE WHEN email = ? THEN email_verified ELSE ? END, firstName = ?, lastName = ?, email = ? WHERE id = ?"),
+		user.Email, false, user.FirstName, user.LastName, user.Email, user.Id,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	// An unchanged profile is reported as unaffected by MySQL.
+	if err := expectAffected(result); err != nil {
+		if _, findErr := r.FindById(user.Id); findErr == nil {
+			return nil
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// Marks the email of the user as verified if it is still the given one.
+func (r *SqlUserRepository) VerifyEmail(id int, email string) error {
+	result, err := r.Db.Exec(
+		r.Dialect.Rebind("UPDATE users SET email_verified = ? WHERE id = ? AND email = ?"),
+		true, id, email,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	// Verifying twice does not change the row, so MySQL reports it as
+	// unaffected.
+	if err := expectAffected(result); err != nil {
+		if user, findErr := r.FindById(id); findErr == nil && user.Email.String == email && user.EmailVerified {
+			return nil
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// Removes the user identified by the given id.
+func (r *SqlUserRepository) Delete(id int) error {
+	result, err := r.Db.Exec(r.Dialect.Rebind("DELETE FROM users WHERE id = ?"), id)
+	if err != nil {
+		return err
+	}
+
+	return expectAffected(result)
+}
+
+// Reads a user selected with userColumns.
+func scanUser(row interface{ Scan(...interface{}) error }) (models.UserDb, error) {
+	var user models.UserDb
+
+	err := row.Scan(
+		&user.Id, &user.UserName, &user.Password, &user.FirstName, &user.LastName, &user.Email,
+		&user.Role, &user.EmailVerified, &user.Disabled, &user.PasswordResetRequired,
+	)
+
+	return user, err
+}
@@ -0,0 +1,308 @@
+package repositories_test
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/kluddizz/maintenance-rest-service/config"
+	"github.com/kluddizz/maintenance-rest-service/migrations"
+	"github.com/kluddizz/maintenance-rest-service/models"
+	"github.com/kluddizz/maintenance-rest-service/repositories"
+)
+
+// Test if the SQL repositories work on top of a local SQLite file.
+func TestSQLiteRepositories(t *testing.T) {
+	db, dialect, err := repositories.Open(&config.DatabaseConfig{
+		Driver:   config.DriverSQLite,
+		Database: filepath.Join(t.TempDir(), "test.db"),
+	})
+
+	if err != nil {
+		t.Fatalf("Could not open database: %s", err.Error())
+	}
+
+	defer db.Close()
+
+	migrator, err := migrations.New(db, dialect)
+	if err == nil {
+		_, err = migrator.Up(0)
+	}
+
+	if err != nil {
+		t.Fatalf("Could not migrate database: %s", err.Error())
+	}
+
+	masters := repositories.NewSqlMasterRepository(db, dialect)
+	master := models.Master{Name: "Test Master", Host: "127.0.0.1", Port: 5050}
+
+	if err := masters.Create(&master); err != nil {
+		t.Fatalf("Could not create master: %s", err.Error())
+	}
+
+	if err := masters.Create(&models.Master{Name: master.Name}); err != repositories.ErrDuplicate {
+		t.Errorf("Expected error to be %v but received %v", repositories.ErrDuplicate, err)
+	}
+
+	stored, err := masters.Get(master.Id)
+	if err != nil || stored != master {
+		t.Errorf("Expected master to be %v but received %v (%v)", master, stored, err)
+	}
+
+	if err := masters.Delete(-1); err != repositories.ErrNotFound {
+		t.Errorf("Expected error to be %v but received %v", repositories.ErrNotFound, err)
+	}
+
+	users := repositories.NewSqlUserRepository(db, dialect)
+	user := models.User{UserName: "testuser"}
+
+	if err := users.Create(&user, []byte("hash")); err != nil {
+		t.Fatalf("Could not create user: %s", err.Error())
+	}
+
+	storedUser, err := users.FindByUsername(user.UserName)
+	if err != nil || storedUser.Id != user.Id || string(storedUser.Password) != "hash" {
+		t.Errorf("Expected user to be %v but received %v (%v)", user, storedUser, err)
+	}
+
+	if err := users.VerifyEmail(user.Id, "other@example.com"); err != repositories.ErrNotFound {
+		t.Errorf("Expected error to be %v but received %v", repositories.ErrNotFound, err)
+	}
+
+	if err := users.SetPassword(user.Id, []byte("changed")); err != nil {
+		t.Errorf("Could not set password: %s", err.Error())
+	}
+
+	if byEmail, err := users.ListByEmail(""); err != nil || len(byEmail) != 1 || string(byEmail[0].Password) != "changed" {
+		t.Errorf("Expected user with changed password but received %v (%v)", byEmail, err)
+	}
+
+	user.Email = "test@example.com"
+	if err := users.UpdateProfile(&user); err != nil || users.VerifyEmail(user.Id, user.Email) != nil {
+		t.Errorf("Could not update and verify email: %v", err)
+	}
+
+	// Only a changed email has to be verified again.
+	for _, email := range []string{user.Email, "changed@example.com"} {
+		user.Email = email
+		if err := users.UpdateProfile(&user); err != nil {
+			t.Errorf("Could not update profile: %s", err.Error())
+		}
+
+		storedUser, _ = users.FindById(user.Id)
+		if storedUser.Email.String != email || storedUser.EmailVerified != (email != "changed@example.com") {
+			t.Errorf("Expected email %s to be verified only if unchanged but received %v", email, storedUser)
+		}
+	}
+
+	if err := users.SetDisabled(user.Id, true); err != nil || users.RequirePasswordReset(user.Id) != nil {
+		t.Errorf("Could not disable user: %v", err)
+	}
+
+	// Wildcards in the search match literally.
+	for search, total := range map[string]int{"TEST": 1, "test_": 0, "%": 0} {
+		found, count, err := users.List(models.UserQuery{Search: search, Limit: 10})
+		if err != nil || count != total || len(found) != total {
+			t.Errorf("Expected %d users matching %s but received %d %v (%v)", total, search, count, found, err)
+		}
+
+		if total == 1 && (!found[0].Disabled || !found[0].PasswordResetRequired) {
+			t.Errorf("Expected disabled user requiring a password reset but received %v", found[0])
+		}
+	}
+
+	if err := users.ReplacePassword(user.Id, []byte("changed"), []byte("reset")); err != nil {
+		t.Errorf("Could not replace password: %s", err.Error())
+	}
+
+	// Only the current password can be replaced.
+	if err := users.ReplacePassword(user.Id, []byte("changed"), []byte("other")); err != repositories.ErrNotFound {
+		t.Errorf("Expected error to be %v but received %v", repositories.ErrNotFound, err)
+	}
+
+	if storedUser, _ = users.FindById(user.Id); storedUser.PasswordResetRequired {
+		t.Errorf("Expected password reset to be cleared but received %v", storedUser)
+	}
+
+	refreshTokens := repositories.NewSqlRefreshTokenRepository(db, dialect)
+	token := models.RefreshToken{UserId: user.Id, FamilyId: "family", TokenHash: "hash", ExpiresAt: 2, CreatedAt: 1}
+
+	if err := refreshTokens.Create(&token); err != nil {
+		t.Fatalf("Could not create refresh token: %s", err.Error())
+	}
+
+	if err := refreshTokens.MarkUsed(token.Id, 1); err != nil {
+		t.Errorf("Could not mark refresh token as used: %s", err.Error())
+	}
+
+	if err := refreshTokens.MarkUsed(token.Id, 1); err != repositories.ErrNotFound {
+		t.Errorf("Expected error to be %v but received %v", repositories.ErrNotFound, err)
+	}
+
+	if err := refreshTokens.RevokeFamily(token.FamilyId, 1); err != nil {
+		t.Errorf("Could not revoke refresh tokens: %s", err.Error())
+	}
+
+	storedToken, err := refreshTokens.FindByHash(token.TokenHash)
+	if err != nil || !storedToken.UsedAt.Valid || !storedToken.RevokedAt.Valid {
+		t.Errorf("Expected refresh token to be used and revoked but received %v (%v)", storedToken, err)
+	}
+
+	revocations := repositories.NewSqlRevocationRepository(db, dialect)
+
+	for _, before := range []int64{1, 2, 2} {
+		if err := revocations.RevokeUser(models.UserRevocation{UserId: user.Id, RevokedBefore: before}); err != nil {
+			t.Errorf("Could not revoke user: %s", err.Error())
+		}
+	}
+
+	revokedUsers, err := revocations.ListUsers()
+	if err != nil || len(revokedUsers) != 1 || revokedUsers[0].RevokedBefore != 2 {
+		t.Errorf("Expected one user revocation but received %v (%v)", revokedUsers, err)
+	}
+
+	owned := models.Master{Name: "Owned Master", Host: "127.0.0.1", Port: 5051, OwnerId: user.Id}
+	if err := masters.Create(&owned); err != nil {
+		t.Fatalf("Could not create master: %s", err.Error())
+	}
+
+	if err := masters.Share(models.MasterShare{MasterId: master.Id, UserId: user.Id, Access: models.AccessRead}); err != nil {
+		t.Errorf("Could not share master: %s", err.Error())
+	}
+
+	if access, err := masters.Access(master.Id, user.Id); access != models.AccessRead {
+		t.Errorf("Expected access to be %s but received %s (%v)", models.AccessRead, access, err)
+	}
+
+	accessible, err := masters.ListAccessible(user.Id)
+	if err != nil || len(accessible) != 2 || accessible[1] != owned {
+		t.Errorf("Expected shared and owned master but received %v (%v)", accessible, err)
+	}
+
+	apiKeys := repositories.NewSqlApiKeyRepository(db, dialect)
+	apiKey := models.ApiKey{
+		UserId:    user.Id,
+		Name:      "monitoring",
+		KeyHash:   "hash",
+		Scopes:    []models.Permission{models.PermissionReadMasters, models.PermissionWriteMasters},
+		CreatedAt: 1,
+	}
+
+	if err := apiKeys.Create(&apiKey); err != nil {
+		t.Fatalf("Could not create API key: %s", err.Error())
+	}
+
+	apiKeys.Touch(apiKey.Id, 2)
+
+	storedKey, err := apiKeys.FindByHash(apiKey.KeyHash)
+	if err != nil || len(storedKey.Scopes) != 2 || storedKey.LastUsedAt != 2 || storedKey.ExpiresAt != 0 {
+		t.Errorf("Expected API key to be %v but received %v (%v)", apiKey, storedKey, err)
+	}
+
+	if err := apiKeys.Delete(apiKey.Id, user.Id+1); err != repositories.ErrNotFound {
+		t.Errorf("Expected error to be %v but received %v", repositories.ErrNotFound, err)
+	}
+
+	attempts := repositories.NewSqlLoginAttemptRepository(db, dialect)
+
+	// Concurrent failures are counted each.
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if _, err := attempts.Fail("user:testuser", 10, 0); err != nil {
+				t.Errorf("Could not count login attempt: %s", err.Error())
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	attempt, err := attempts.Get("user:testuser")
+	if err != nil || attempt.Failures != 20 {
+		t.Errorf("Expected %d failures but received %v (%v)", 20, attempt, err)
+	}
+
+	for _, expected := range []bool{true, false} {
+		if locked, err := attempts.Lock(attempt.Key, 10, 20); err != nil || locked != expected {
+			t.Errorf("Expected locked to be %v but received %v (%v)", expected, locked, err)
+		}
+	}
+
+	// Failures before the reset time are forgotten once the lock ended.
+	if attempt, err := attempts.Fail(attempt.Key, 30, 15); err != nil || attempt.Failures != 1 {
+		t.Errorf("Expected one failure but received %v (%v)", attempt, err)
+	}
+
+	if err := attempts.Delete(attempt.Key); err != nil {
+		t.Errorf("Could not delete login attempt: %s", err.Error())
+	}
+
+	if _, err := attempts.Get(attempt.Key); err != repositories.ErrNotFound {
+		t.Errorf("Expected error to be %v but received %v", repositories.ErrNotFound, err)
+	}
+
+	audit := repositories.NewSqlAuditRepository(db, dialect)
+
+	for _, event := range []string{models.AuditLockout, models.AuditUnlock} {
+		if err := audit.Record(&models.AuditEvent{Event: event, Subject: attempt.Key, CreatedAt: 1}); err != nil {
+			t.Errorf("Could not record audit event: %s", err.Error())
+		}
+	}
+
+	events, err := audit.List(1)
+	if err != nil || len(events) != 1 || events[0].Event != models.AuditUnlock {
+		t.Errorf("Expected latest audit event but received %v (%v)", events, err)
+	}
+
+	secrets := repositories.NewSqlTotpRepository(db, dialect)
+
+	if err := secrets.Save(models.Totp{UserId: user.Id, Secret: "secret", CreatedAt: 1}); err != nil {
+		t.Fatalf("Could not save TOTP secret: %s", err.Error())
+	}
+
+	if err := secrets.Confirm(user.Id); err != nil {
+		t.Errorf("Could not confirm TOTP secret: %s", err.Error())
+	}
+
+	if err := secrets.UseStep(user.Id, 2); err != nil {
+		t.Errorf("Could not use TOTP step: %s", err.Error())
+	}
+
+	if err := secrets.UseStep(user.Id, 1); err != repositories.ErrNotFound {
+		t.Errorf("Expected error to be %v but received %v", repositories.ErrNotFound, err)
+	}
+
+	if stored, err := secrets.Get(user.Id); err != nil || !stored.Confirmed || stored.LastUsedStep != 2 {
+		t.Errorf("Expected confirmed TOTP secret but received %v (%v)", stored, err)
+	}
+
+	if err := secrets.ReplaceRecoveryCodes(user.Id, []string{"a", "b"}); err != nil {
+		t.Errorf("Could not store recovery codes: %s", err.Error())
+	}
+
+	for _, expected := range []error{nil, repositories.ErrNotFound} {
+		if err := secrets.UseRecoveryCode(user.Id, "a", 1); err != expected {
+			t.Errorf("Expected error to be %v but received %v", expected, err)
+		}
+	}
+
+	identities := repositories.NewSqlIdentityRepository(db, dialect)
+	identity := models.Identity{Issuer: "https://idp.example.com", Subject: "1234", UserId: user.Id}
+
+	for _, expected := range []error{nil, repositories.ErrDuplicate} {
+		if err := identities.Create(identity); err != expected {
+			t.Errorf("Expected error to be %v but received %v", expected, err)
+		}
+	}
+
+	if stored, err := identities.Find(identity.Issuer, identity.Subject); err != nil || stored != identity {
+		t.Errorf("Expected identity to be %v but received %v (%v)", identity, stored, err)
+	}
+
+	if linked, err := identities.Linked(user.Id); err != nil || !linked {
+		t.Errorf("Expected user to be linked (%v)", err)
+	}
+}
@@ -0,0 +1,57 @@
+package repositories
+
+import (
+	"database/sql"
+)
+
+type (
+	// Bundles all repositories of the service.
+	Store struct {
+		Users         UserRepository
+		Masters       MasterRepository
+		Shares        MasterShareRepository
+		RefreshTokens RefreshTokenRepository
+		Revocations   RevocationRepository
+		ApiKeys       ApiKeyRepository
+		LoginAttempts LoginAttemptRepository
+		Audit         AuditRepository
+		Totp          TotpRepository
+		Identities    IdentityRepository
+	}
+)
+
+// Creates a store whose repositories share the given database.
+func NewSqlStore(db *sql.DB, dialect Dialect) *Store {
+	masters := NewSqlMasterRepository(db, dialect)
+
+	return &Store{
+		Users:         NewSqlUserRepository(db, dialect),
+		Masters:       masters,
+		Shares:        masters,
+		RefreshTokens: NewSqlRefreshTokenRepository(db, dialect),
+		Revocations:   NewSqlRevocationRepository(db, dialect),
+		ApiKeys:       NewSqlApiKeyRepository(db, dialect),
+		LoginAttempts: NewSqlLoginAttemptRepository(db, dialect),
+		Audit:         NewSqlAuditRepository(db, dialect),
+		Totp:          NewSqlTotpRepository(db, dialect),
+		Identities:    NewSqlIdentityRepository(db, dialect),
+	}
+}
+
+// Creates a store keeping everything in memory.
+func NewMemoryStore() *Store {
+	masters := NewMemoryMasterRepository()
+
+	return &Store{
+		Users:         NewMemoryUserRepository(),
+		Masters:       masters,
+		Shares:        masters,
+		RefreshTokens: NewMemoryRefreshTokenRepository(),
+		Revocations:   NewMemoryRevocationRepository(),
+		ApiKeys:       NewMemoryApiKeyRepository(),
+		LoginAttempts: NewMemoryLoginAttemptRepository(),
+		Audit:         NewMemoryAuditRepository(),
+		Totp:          NewMemoryTotpRepository(),
+		Identities:    NewMemoryIdentityRepository(),
+	}
+}
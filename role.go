@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kluddizz/maintenance-rest-service/auth"
+	"github.com/kluddizz/maintenance-rest-service/config"
+	"github.com/kluddizz/maintenance-rest-service/models"
+	"github.com/kluddizz/maintenance-rest-service/repositories"
+)
+
+// Handles `role <username> <role>`, which assigns a role directly in the
+// database, e.g. to promote the first admin. The tokens of the user are
+// revoked, so the previous role is not kept until they expire.
+func role(dbConfig *config.DatabaseConfig, args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: role <username> <viewer|operator|admin>")
+	}
+
+	username, newRole := args[0], models.Role(args[1])
+	if !newRole.Valid() {
+		return fmt.Errorf("unknown role `%s`", newRole)
+	}
+
+	db, dialect, err := repositories.Open(dbConfig)
+	if err != nil {
+		return err
+	}
+
+	defer db.Close()
+
+	store := repositories.NewSqlStore(db, dialect)
+	user, err := store.Users.FindByUsername(username)
+	if err == repositories.ErrNotFound {
+		return fmt.Errorf("unknown user `%s`", username)
+	} else if err != nil {
+		return err
+	}
+
+	if err := store.Users.SetRole(user.Id, newRole); err != nil {
+		return err
+	}
+
+	if err := store.RefreshTokens.RevokeUser(user.Id, time.Now().Unix()); err != nil {
+		return err
+	}
+
+	// Running instances pick the revocation up with their next reload.
+	revocations := auth.NewRevocationList(store.Revocations, 0, 0)
+	if err := revocations.RevokeUser(user.Id); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s is now %s\n", username, newRole)
+	return nil
+}
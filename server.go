@@ -1,44 +1,74 @@
 package main
 
 import (
-	"database/sql"
-	"net/http"
+	"context"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
 
 	_ "github.com/go-sql-driver/mysql"
-	"github.com/julienschmidt/httprouter"
+	"github.com/kluddizz/maintenance-rest-service/app"
 	"github.com/kluddizz/maintenance-rest-service/config"
-	"github.com/kluddizz/maintenance-rest-service/controllers"
-	"github.com/kluddizz/maintenance-rest-service/middlewares"
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
 )
 
 func main() {
-	// Initialize the database object using a configuration file.
-	dbConfig, _ := config.ReadDatabaseConfig("./database.json")
-	db, err := sql.Open("mysql", dbConfig.DataSourceName())
-
+	cfg, args, err := config.Load(os.Args[1:])
 	if err != nil {
-		panic(err.Error())
+		log.Fatal(err.Error())
 	}
 
-	defer db.Close()
+	app.SetupLogging(cfg.Log)
+
+	// Run a subcommand instead of the server if requested.
+	commands := map[string]func(*config.DatabaseConfig, []string) error{
+		"migrate": migrate,
+		"role":    role,
+	}
 
-	// Create new router and controllers for handling routing.
-	r := httprouter.New()
-	uc := controllers.NewUserController(db)
-	mc := controllers.NewMasterController(db)
+	if len(args) > 0 && commands[args[0]] != nil {
+		if err := cfg.Database.Validate(); err != nil {
+			log.Fatal(err.Error())
+		}
 
-	// Define the routes of the REST service.
-	r.POST("/register", uc.CreateUser)
-	r.POST("/login", uc.LoginUser)
-	r.DELETE("/users", middlewares.AuthMiddleWare(uc.DeleteUser))
+		if err := commands[args[0]](&cfg.Database, args[1:]); err != nil {
+			log.Fatal(err.Error())
+		}
 
-	r.GET("/masters", middlewares.AuthMiddleWare(mc.GetMasters))
-	r.POST("/masters", middlewares.AuthMiddleWare(mc.CreateMaster))
+		return
+	}
 
-	r.GET("/masters/:id", middlewares.AuthMiddleWare(mc.GetMaster))
-	r.PUT("/masters/:id", middlewares.AuthMiddleWare(mc.UpdateMaster))
-	r.DELETE("/masters/:id", middlewares.AuthMiddleWare(mc.DeleteMaster))
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %s", err.Error())
+	}
+
+	server, err := app.NewServer(cfg)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	// Stop gracefully on SIGINT and SIGTERM.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Reload the token keys on SIGHUP.
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		for range hangup {
+			if err := server.ReloadKeys(); err != nil {
+				slog.Error("Could not reload keys", "error", err)
+			} else {
+				slog.Info("Reloaded keys")
+			}
+		}
+	}()
 
 	// Start listening to clients.
-	http.ListenAndServe("localhost:3000", r)
+	if err := server.Run(ctx); err != nil {
+		log.Fatal(err.Error())
+	}
 }
@@ -0,0 +1,12 @@
+// Package version holds build metadata, which is injected at link time:
+//
+//	go build -ldflags "-X github.com/kluddizz/maintenance-rest-service/version.Version=v1.2.0 \
+//	  -X github.com/kluddizz/maintenance-rest-service/version.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/kluddizz/maintenance-rest-service/version.BuildDate=$(date -u +%FT%TZ)"
+package version
+
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)